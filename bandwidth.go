@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// SizeResolver returns the size in bytes of a segment without a byterange
+type SizeResolver func(uri string) (int64, error)
+
+// FileSizes resolves segment URIs relative to fsys and returns the sizes
+// of the files
+func FileSizes(fsys fs.FS) SizeResolver {
+	return func(uri string) (int64, error) {
+		name, err := segmentPath(uri)
+		if err != nil {
+			return 0, err
+		}
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+}
+
+// Bandwidth is the bit rate of a media track in bit/s
+type Bandwidth struct {
+	// Peak is the peak segment bit rate, the BANDWIDTH of the variant
+	Peak int64
+	// Average is the AVERAGE-BANDWIDTH of the variant
+	Average int64
+}
+
+// ComputeBandwidth measures the bit rate of a decoded media track from the
+// sizes of its segments: byterange counts when present, or else the sizes
+// returned by resolve, which may be nil when every segment has a byterange.
+// Initialization sections and gaps are not counted.
+//
+// The peak is the largest bit rate of any run of segments lasting between
+// half and one and a half target durations. The results are stored as the
+// track's bandwidth and average_bandwidth streaming metadata, and each
+// segment without a byterange gets an EXT-X-BITRATE hint.
+func ComputeBandwidth(ctx context.Context, track *gotio.Track, resolve SizeResolver) (*Bandwidth, error) {
+	bw, hints, err := measureBandwidth(ctx, track, resolve)
+	if err != nil {
+		return nil, err
+	}
+	md := track.Metadata()
+	if md == nil {
+		md = make(gotio.AnyDictionary)
+	}
+	streamingMD := namespace(md, streamingMetadataNamespace)
+	streamingMD["bandwidth"] = bw.Peak
+	streamingMD["average_bandwidth"] = bw.Average
+	md[streamingMetadataNamespace] = streamingMD
+	track.SetMetadata(md)
+
+	for clip, hint := range hints {
+		clipMD := clip.Metadata()
+		if clipMD == nil {
+			clipMD = make(gotio.AnyDictionary)
+		}
+		hlsMD := namespace(clipMD, metadataNamespace)
+		hlsMD["bitrate"] = hint
+		clipMD[metadataNamespace] = hlsMD
+		clip.SetMetadata(clipMD)
+	}
+	return bw, nil
+}
+
+// measureBandwidth is ComputeBandwidth without changing the track: it
+// returns the EXT-X-BITRATE hints, in kbit/s, by clip
+func measureBandwidth(ctx context.Context, track *gotio.Track, resolve SizeResolver) (*Bandwidth, map[*gotio.Clip]int64, error) {
+	type sized struct {
+		clip     *gotio.Clip
+		bits     float64
+		duration float64
+		ranged   bool
+	}
+
+	var segments []sized
+	var maxDuration float64
+	for _, child := range track.Children() {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		clip, ok := child.(*gotio.Clip)
+		if !ok {
+			continue
+		}
+		seg := clipSegment(clip)
+		if seg.Gap || seg.Duration <= 0 {
+			// A gap ends the run of segments
+			segments = append(segments, sized{})
+			continue
+		}
+
+		var size int64
+		if seg.Byterange != nil {
+			size = seg.Byterange.Count
+		} else {
+			if resolve == nil {
+				return nil, nil, fmt.Errorf("segment %q: no byterange and no size resolver", seg.URI)
+			}
+			var err error
+			if size, err = resolve(seg.URI); err != nil {
+				return nil, nil, fmt.Errorf("segment %q: %w", seg.URI, err)
+			}
+		}
+		segments = append(segments, sized{
+			clip:     clip,
+			bits:     float64(size * 8),
+			duration: seg.Duration,
+			ranged:   seg.Byterange != nil,
+		})
+		maxDuration = math.Max(maxDuration, seg.Duration)
+	}
+
+	td, _ := asInt64(namespace(track.Metadata(), metadataNamespace)["target_duration"])
+	targetDuration := float64(td)
+	if targetDuration <= 0 {
+		targetDuration = math.Ceil(maxDuration)
+	}
+
+	var peak, singlePeak, totalBits, totalDuration float64
+	for i, s := range segments {
+		if s.clip == nil {
+			continue
+		}
+		totalBits += s.bits
+		totalDuration += s.duration
+		singlePeak = math.Max(singlePeak, s.bits/s.duration)
+
+		var bits, duration float64
+		for _, run := range segments[i:] {
+			if run.clip == nil {
+				break
+			}
+			bits += run.bits
+			duration += run.duration
+			if duration > 1.5*targetDuration {
+				break
+			}
+			if duration >= 0.5*targetDuration {
+				peak = math.Max(peak, bits/duration)
+			}
+		}
+	}
+	if totalDuration == 0 {
+		return nil, nil, ErrNoSegments
+	}
+	// Too few segments to fill half a target duration
+	if peak == 0 {
+		peak = singlePeak
+	}
+
+	bw := &Bandwidth{
+		Peak:    int64(math.Ceil(peak)),
+		Average: int64(math.Ceil(totalBits / totalDuration)),
+	}
+	// A hint covers the segments after it that are within 10% of its
+	// value, so it only changes when the bit rate does
+	hints := make(map[*gotio.Clip]int64)
+	var hint int64
+	for _, s := range segments {
+		if s.clip == nil || s.ranged {
+			continue
+		}
+		kbps := s.bits / s.duration / 1000
+		if hint == 0 || math.Abs(kbps-float64(hint)) > 0.1*float64(hint) {
+			hint = int64(math.Max(1, math.Round(kbps)))
+		}
+		hints[s.clip] = hint
+	}
+	return bw, hints, nil
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+func TestComputeBandwidthFromFiles(t *testing.T) {
+	// Four second segments of 500, 520 and 1000 kB
+	src := fstest.MapFS{
+		"a.ts": &fstest.MapFile{Data: make([]byte, 500000)},
+		"b.ts": &fstest.MapFile{Data: make([]byte, 520000)},
+		"c.ts": &fstest.MapFile{Data: make([]byte, 1000000)},
+	}
+	track := segmentTrack("video", gotio.TrackKindVideo, gotio.AnyDictionary{
+		metadataNamespace: map[string]interface{}{"target_duration": 4},
+	}, "a.ts", "b.ts", "c.ts")
+
+	bw, err := ComputeBandwidth(context.Background(), track, FileSizes(src))
+	if err != nil {
+		t.Fatalf("ComputeBandwidth failed: %v", err)
+	}
+	// The peak run is c.ts alone; runs of two last 8s, over 1.5 x 4s
+	if bw.Peak != 2000000 || bw.Average != 1346667 {
+		t.Errorf("Expected peak 2000000 and average 1346667, got %+v", bw)
+	}
+	streamingMD := namespace(track.Metadata(), streamingMetadataNamespace)
+	if streamingMD["bandwidth"] != int64(2000000) || streamingMD["average_bandwidth"] != int64(1346667) {
+		t.Errorf("Unexpected streaming metadata %v", streamingMD)
+	}
+
+	// b.ts is within 10% of the hint before it
+	var hints []interface{}
+	for _, child := range track.Children() {
+		hints = append(hints, namespace(child.(*gotio.Clip).Metadata(), metadataNamespace)["bitrate"])
+	}
+	if hints[0] != int64(1000) || hints[1] != int64(1000) || hints[2] != int64(2000) {
+		t.Errorf("Unexpected EXT-X-BITRATE hints %v", hints)
+	}
+
+	media, err := NewEncoder(nil).encodeMediaPlaylist(context.Background(), track)
+	if err != nil {
+		t.Fatalf("encodeMediaPlaylist failed: %v", err)
+	}
+	out, _ := media.Marshal()
+	if n := strings.Count(string(out), "#EXT-X-BITRATE:"); n != 2 {
+		t.Errorf("Expected 2 EXT-X-BITRATE tags, got %d:\n%s", n, out)
+	}
+}
+
+func TestComputeBandwidthFromByteranges(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXTINF:2.0,
+#EXT-X-BYTERANGE:250000@0
+main.mp4
+#EXTINF:1.0,
+#EXT-X-BYTERANGE:250000@250000
+main.mp4
+#EXTINF:2.0,
+#EXT-X-BYTERANGE:100000@500000
+main.mp4
+#EXT-X-ENDLIST
+`
+	timeline, err := NewDecoder(strings.NewReader(playlist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	track := firstTrack(t, timeline)
+
+	bw, err := ComputeBandwidth(context.Background(), track, nil)
+	if err != nil {
+		t.Fatalf("ComputeBandwidth failed: %v", err)
+	}
+	// The one second segment alone is too short; with its neighbour it
+	// runs 3s at 500 kB
+	if bw.Peak != 1333334 || bw.Average != 960000 {
+		t.Errorf("Expected peak 1333334 and average 960000, got %+v", bw)
+	}
+	for _, child := range track.Children() {
+		if _, ok := namespace(child.(*gotio.Clip).Metadata(), metadataNamespace)["bitrate"]; ok {
+			t.Errorf("Expected no EXT-X-BITRATE hint on byterange segments")
+		}
+	}
+}
+
+func TestComputeBandwidthErrors(t *testing.T) {
+	track := segmentTrack("video", gotio.TrackKindVideo, nil, "missing.ts")
+	if _, err := ComputeBandwidth(context.Background(), track, nil); err == nil {
+		t.Error("Expected an error without a size resolver")
+	}
+	if _, err := ComputeBandwidth(context.Background(), track, FileSizes(fstest.MapFS{})); err == nil {
+		t.Error("Expected an error for a missing segment")
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// runConvert translates an M3U8 playlist to an OTIO timeline or back
+func runConvert(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("convert", "[input]")
+	output := fs.String("o", "", "write to `file` instead of stdout")
+	to := fs.String("to", "", "output `format`, otio or m3u8; by default the extension of -o, or the other format than the input")
+	version := fs.Int("version", 0, "write EXT-X-VERSION `n`, down-leveling the playlist to it")
+	master := fs.Bool("master", false, "write a multivariant playlist even for a single track")
+	normalize := fs.Bool("normalize", false, "write playlists in canonical form")
+	baseURL := fs.String("base", "", "resolve segment URIs against `url`")
+	strict := fs.Bool("strict", false, "fail on malformed tags")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	input := fs.Arg(0)
+
+	var decOpts []hls.DecoderOption
+	if *baseURL != "" {
+		decOpts = append(decOpts, hls.WithBaseURL(*baseURL))
+	}
+	if *strict {
+		decOpts = append(decOpts, hls.Strict())
+	}
+	data, err := readInput(input, stdin)
+	if err != nil {
+		return err
+	}
+	fromOTIO := looksLikeOTIO(input, data)
+	timeline, err := decodeTimeline(input, data, decOpts...)
+	if err != nil {
+		return err
+	}
+
+	var asOTIO bool
+	switch *to {
+	case "otio":
+		asOTIO = true
+	case "m3u8":
+	case "":
+		if !isStdin(*output) {
+			asOTIO = isOTIO(*output)
+		} else {
+			asOTIO = !fromOTIO
+		}
+	default:
+		return fmt.Errorf("unknown format %q, want otio or m3u8", *to)
+	}
+
+	var encOpts []hls.EncoderOption
+	if *version > 0 {
+		encOpts = append(encOpts, hls.WithVersion(*version))
+	}
+	if *master {
+		encOpts = append(encOpts, hls.WithMasterPlaylist(true))
+	}
+	if *normalize {
+		encOpts = append(encOpts, hls.WithNormalize())
+	}
+	return writeTimeline(timeline, *output, asOTIO, stdout, encOpts...)
+}
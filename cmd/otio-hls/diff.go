@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Avalanche-io/otio-hls/diff"
+)
+
+// runDiff reports the semantic differences between two playlists,
+// packages or timelines, and fails when there are any
+func runDiff(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("diff", "<old> <new>")
+	asJSON := fs.Bool("json", false, "write the changes as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || (isStdin(fs.Arg(0)) && isStdin(fs.Arg(1))) {
+		fs.Usage()
+		return errUsage
+	}
+	oldPath, newPath := fs.Arg(0), fs.Arg(1)
+
+	oldData, err := readInput(oldPath, stdin)
+	if err != nil {
+		return err
+	}
+	newData, err := readInput(newPath, stdin)
+	if err != nil {
+		return err
+	}
+
+	var result *diff.Result
+	switch {
+	case looksLikeOTIO(oldPath, oldData) || looksLikeOTIO(newPath, newData):
+		oldTimeline, err := decodeTimeline(oldPath, oldData)
+		if err != nil {
+			return err
+		}
+		newTimeline, err := decodeTimeline(newPath, newData)
+		if err != nil {
+			return err
+		}
+		if result, err = diff.Timelines(oldTimeline, newTimeline); err != nil {
+			return err
+		}
+	case !isStdin(oldPath) && !isStdin(newPath):
+		// Files are compared with the media playlists next to them
+		oldDir, oldName := filepath.Split(oldPath)
+		newDir, newName := filepath.Split(newPath)
+		result, err = diff.Packages(os.DirFS(dirOrDot(oldDir)), oldName, os.DirFS(dirOrDot(newDir)), newName)
+		if err != nil {
+			return err
+		}
+	default:
+		if result, err = diff.Bytes(oldData, newData); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		if result.Changes == nil {
+			result.Changes = []diff.Change{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range result.Changes {
+			if _, err := io.WriteString(stdout, c.String()+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	if !result.Equal() {
+		return errFindings
+	}
+	return nil
+}
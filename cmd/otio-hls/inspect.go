@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// runInspect prints a summary of a media playlist, or the ladder and
+// renditions of a multivariant playlist with the media playlists it refers
+// to when they are local files
+func runInspect(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("inspect", "[playlist]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	input := fs.Arg(0)
+
+	data, err := readInput(input, stdin)
+	if err != nil {
+		return err
+	}
+	p, err := hls.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	switch p := p.(type) {
+	case *hls.MediaPlaylist:
+		inspectMedia(tw, p)
+	case *hls.MultivariantPlaylist:
+		var media mediaReader
+		if !isStdin(input) {
+			dir, name := filepath.Split(input)
+			media = mediaReader{hls.NewResolver(os.DirFS(dirOrDot(dir))), name}
+		}
+		inspectMultivariant(tw, p, media)
+	}
+	return tw.Flush()
+}
+
+// segmentStats summarizes the segments of a media playlist
+type segmentStats struct {
+	count               int
+	total, short, long  float64
+	discontinuities     int
+	byteranges, partial int
+	methods             []string
+}
+
+func mediaStats(p *hls.MediaPlaylist) segmentStats {
+	var s segmentStats
+	seen := make(map[string]bool)
+	for i, seg := range p.Segments {
+		s.count++
+		s.total += seg.Duration
+		if i == 0 || seg.Duration < s.short {
+			s.short = seg.Duration
+		}
+		s.long = max(s.long, seg.Duration)
+		if seg.Discontinuity {
+			s.discontinuities++
+		}
+		if seg.Byterange != nil {
+			s.byteranges++
+		}
+		s.partial += len(seg.Parts)
+		if seg.Key != nil && !seen[seg.Key.Method] {
+			seen[seg.Key.Method] = true
+			s.methods = append(s.methods, seg.Key.Method)
+		}
+	}
+	return s
+}
+
+func inspectMedia(w io.Writer, p *hls.MediaPlaylist) {
+	kind := "media playlist"
+	if p.IFramesOnly {
+		kind = "I-frame playlist"
+	}
+	fmt.Fprintf(w, "%s, version %d", kind, max(p.Version, 1))
+	if p.PlaylistType != "" {
+		fmt.Fprintf(w, ", %s", p.PlaylistType)
+	}
+	if !p.EndList {
+		fmt.Fprint(w, ", live")
+	}
+	fmt.Fprintln(w)
+
+	s := mediaStats(p)
+	fmt.Fprintf(w, "duration\t%s\n", formatSeconds(s.total))
+	fmt.Fprintf(w, "target duration\t%ds\n", p.TargetDuration)
+	if s.count > 0 {
+		fmt.Fprintf(w, "segments\t%d (shortest %.3fs, longest %.3fs, average %.3fs)\n",
+			s.count, s.short, s.long, s.total/float64(s.count))
+	} else {
+		fmt.Fprintf(w, "segments\t0\n")
+	}
+	if p.MediaSequence > 0 {
+		fmt.Fprintf(w, "media sequence\t%d\n", p.MediaSequence)
+	}
+	if s.discontinuities > 0 {
+		fmt.Fprintf(w, "discontinuities\t%d\n", s.discontinuities)
+	}
+	if s.byteranges > 0 {
+		fmt.Fprintf(w, "byte ranges\t%d\n", s.byteranges)
+	}
+	if s.partial > 0 {
+		fmt.Fprintf(w, "partial segments\t%d\n", s.partial)
+	}
+	for _, method := range s.methods {
+		fmt.Fprintf(w, "encryption\t%s\n", method)
+	}
+}
+
+func inspectMultivariant(w io.Writer, p *hls.MultivariantPlaylist, media mediaReader) {
+	fmt.Fprintf(w, "multivariant playlist, version %d\n", max(p.Version, 1))
+
+	// The ladder goes from the lowest bandwidth up
+	variants := append([]*hls.Variant(nil), p.Variants...)
+	sort.SliceStable(variants, func(a, b int) bool { return variants[a].Bandwidth < variants[b].Bandwidth })
+	if len(variants) > 0 {
+		fmt.Fprintln(w, "\nvariants:")
+		fmt.Fprintln(w, "BANDWIDTH\tAVERAGE\tRESOLUTION\tCODECS\tAUDIO\tDURATION\tSEGMENTS\tURI")
+		for _, v := range variants {
+			inspectVariant(w, v, media)
+		}
+	}
+	if len(p.IFrameVariants) > 0 {
+		fmt.Fprintln(w, "\nI-frame variants:")
+		fmt.Fprintln(w, "BANDWIDTH\tAVERAGE\tRESOLUTION\tCODECS\tAUDIO\tDURATION\tSEGMENTS\tURI")
+		for _, v := range p.IFrameVariants {
+			inspectVariant(w, v, media)
+		}
+	}
+	if len(p.Renditions) > 0 {
+		fmt.Fprintln(w, "\nrenditions:")
+		fmt.Fprintln(w, "TYPE\tGROUP\tNAME\tLANGUAGE\tDEFAULT\tURI")
+		for _, r := range p.Renditions {
+			uri := r.URI
+			if uri == "" {
+				uri = r.InstreamID
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Type, r.GroupID, r.Name, dash(r.Language), yesNo(r.Default), uri)
+		}
+	}
+}
+
+func inspectVariant(w io.Writer, v *hls.Variant, media mediaReader) {
+	resolution := "-"
+	if v.Resolution != nil {
+		resolution = v.Resolution.String()
+	}
+	average := "-"
+	if v.AverageBandwidth > 0 {
+		average = fmt.Sprint(v.AverageBandwidth)
+	}
+	duration, segments := "-", "-"
+	if p := media.read(v.URI); p != nil {
+		s := mediaStats(p)
+		duration, segments = formatSeconds(s.total), fmt.Sprint(s.count)
+	}
+	fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+		v.Bandwidth, average, resolution, dash(v.Codecs), dash(v.Audio), duration, segments, v.URI)
+}
+
+// mediaReader reads the media playlists a multivariant playlist file
+// refers to; the zero value reads none
+type mediaReader struct {
+	resolver *hls.Resolver
+	master   string
+}
+
+// read reads the media playlist at a relative URI, or returns nil
+func (m mediaReader) read(uri string) *hls.MediaPlaylist {
+	if m.resolver == nil {
+		return nil
+	}
+	if u, err := url.Parse(uri); err != nil || u.Scheme != "" || u.Host != "" {
+		return nil
+	}
+	p, _, err := m.resolver.Reference(context.Background(), m.master, uri)
+	if err != nil {
+		return nil
+	}
+	media, _ := p.(*hls.MediaPlaylist)
+	return media
+}
+
+// formatSeconds formats a duration as [h:]mm:ss.sss
+func formatSeconds(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	h, m, s := ms/3600000, ms/60000%60, float64(ms%60000)/1000
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%06.3f", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%06.3f", m, s)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
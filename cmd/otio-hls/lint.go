@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	hls "github.com/Avalanche-io/otio-hls"
+	"github.com/Avalanche-io/otio-hls/validate"
+)
+
+// runLint validates a playlist, or a multivariant playlist file with the
+// media playlists it refers to, and fails when there are findings of at
+// least the given severity
+func runLint(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("lint", "[playlist]")
+	profile := fs.String("profile", string(validate.RFC8216), "rule `profile` for packages, rfc8216 or apple; apple needs a multivariant playlist file")
+	failOn := fs.String("fail-on", "error", "exit 1 on findings of at least `severity`: info, warning or error")
+	quiet := fs.Bool("q", false, "print nothing, only set the exit status")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	input := fs.Arg(0)
+
+	var min validate.Severity
+	switch *failOn {
+	case "info":
+		min = validate.Info
+	case "warning":
+		min = validate.Warning
+	case "error":
+		min = validate.Error
+	default:
+		return fmt.Errorf("unknown severity %q", *failOn)
+	}
+	switch validate.Profile(*profile) {
+	case validate.RFC8216, validate.AppleAuthoring:
+	default:
+		return fmt.Errorf("unknown profile %q", *profile)
+	}
+
+	data, err := readInput(input, stdin)
+	if err != nil {
+		return err
+	}
+	p, err := hls.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, master := p.(*hls.MultivariantPlaylist)
+	master = master && !isStdin(input)
+	if !master && validate.Profile(*profile) != validate.RFC8216 {
+		return fmt.Errorf("profile %s checks packages: lint a multivariant playlist file", *profile)
+	}
+
+	var report *validate.Report
+	if master {
+		dir, name := filepath.Split(input)
+		report, err = validate.Package(os.DirFS(dirOrDot(dir)), name, validate.Profile(*profile))
+		if err != nil {
+			return err
+		}
+	} else {
+		report = validate.Playlist(p)
+	}
+
+	if !*quiet {
+		for _, f := range report.Findings {
+			fmt.Fprintln(stdout, f)
+		}
+	}
+	if report.Err(min) != nil {
+		return errFindings
+	}
+	return nil
+}
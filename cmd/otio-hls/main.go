@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+// Command otio-hls works with HLS playlists and OpenTimelineIO timelines.
+//
+// Usage:
+//
+//	otio-hls <command> [flags] [args]
+//
+// Run "otio-hls help" for the list of commands. The exit status is 0 on
+// success, 1 when lint has findings or diff finds differences, and 2 on bad
+// arguments or when a command fails.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Avalanche-io/gotio"
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// command is a subcommand of otio-hls
+type command struct {
+	summary string
+	run     func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"convert": {"convert between M3U8 playlists and OTIO timelines", runConvert},
+	"diff":    {"report semantic differences between playlists or timelines", runDiff},
+	"inspect": {"summarize a playlist or package", runInspect},
+	"lint":    {"validate a playlist or package", runLint},
+	"package": {"write an HLS package for a timeline", runPackage},
+	"scan":    {"build a playlist from a directory of segments", runScan},
+}
+
+// errUsage reports bad arguments; the flag set has already printed why
+var errUsage = errors.New("usage")
+
+// errFindings fails a command without another message, as lint findings
+// or diff changes have already been printed
+var errFindings = errors.New("findings")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "otio-hls: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdin, stdout); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		if errors.Is(err, errFindings) {
+			return 1
+		}
+		fmt.Fprintf(stderr, "otio-hls %s: %v\n", args[0], err)
+		return 2
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: otio-hls <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet returns a flag set that reports errors instead of exiting
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: otio-hls %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// isOTIO reports whether a path names an OTIO JSON file
+func isOTIO(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".otio")
+}
+
+// isStdin reports whether a path argument stands for stdin or stdout
+func isStdin(path string) bool {
+	return path == "" || path == "-"
+}
+
+// dirOrDot returns the directory part of a split path, or "."
+func dirOrDot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+// readInput reads the file at path, or stdin when path is empty or "-"
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if isStdin(path) {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// looksLikeOTIO reports whether input read from path holds OTIO JSON
+// rather than an M3U8 playlist
+func looksLikeOTIO(path string, data []byte) bool {
+	if isOTIO(path) {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// readTimeline reads a timeline from OTIO JSON or decodes it from an M3U8
+// playlist, from path or stdin
+func readTimeline(path string, stdin io.Reader, opts ...hls.DecoderOption) (*gotio.Timeline, error) {
+	data, err := readInput(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTimeline(path, data, opts...)
+}
+
+// decodeTimeline is readTimeline for input already read
+func decodeTimeline(path string, data []byte, opts ...hls.DecoderOption) (*gotio.Timeline, error) {
+	if !looksLikeOTIO(path, data) {
+		return hls.NewDecoder(bytes.NewReader(data), opts...).Decode()
+	}
+	obj, err := gotio.FromJSONString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	t, ok := obj.(*gotio.Timeline)
+	if !ok {
+		return nil, fmt.Errorf("expected a Timeline, got %s", obj.SchemaName())
+	}
+	return t, nil
+}
+
+// writeTimeline writes t to path, or to stdout when path is empty or "-",
+// as OTIO JSON when asOTIO is set and as an HLS playlist otherwise. A file
+// that cannot be written completely is removed.
+func writeTimeline(t *gotio.Timeline, path string, asOTIO bool, stdout io.Writer, opts ...hls.EncoderOption) error {
+	if isStdin(path) {
+		return encodeTimeline(stdout, t, asOTIO, opts...)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = encodeTimeline(f, t, asOTIO, opts...)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// encodeTimeline is writeTimeline for a writer
+func encodeTimeline(w io.Writer, t *gotio.Timeline, asOTIO bool, opts ...hls.EncoderOption) error {
+	if asOTIO {
+		data, err := gotio.ToJSONString(t, "    ")
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, data+"\n")
+		return err
+	}
+	return hls.NewEncoder(w, opts...).Encode(t)
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMedia = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:4.0,
+seg0.ts
+#EXTINF:4.0,
+seg1.ts
+#EXT-X-ENDLIST
+`
+
+// runWith runs otio-hls with stdin, returning the exit status and output
+func runWith(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunConvert(t *testing.T) {
+	code, out, _ := runWith(t, testMedia, "convert", "-to", "m3u8", "-version", "2")
+	if code != 0 || !strings.Contains(out, "#EXT-X-VERSION:2\n") {
+		t.Fatalf("Expected a playlist and status 0, got %d:\n%s", code, out)
+	}
+
+	dir := t.TempDir()
+	m3u8 := filepath.Join(dir, "index.m3u8")
+	if code, _, stderr := runWith(t, testMedia, "convert", "-o", m3u8, "-"); code != 0 {
+		t.Fatalf("Expected status 0, got %d: %s", code, stderr)
+	}
+	data, err := os.ReadFile(m3u8)
+	if err != nil || !strings.Contains(string(data), "\nseg1.ts\n") {
+		t.Errorf("Expected the playlist written, got %v:\n%s", err, data)
+	}
+
+	if code, _, stderr := runWith(t, "", "convert", filepath.Join(dir, "missing.m3u8")); code != 2 || stderr == "" {
+		t.Errorf("Expected status 2 and a message for a missing input, got %d %q", code, stderr)
+	}
+	if code, _, _ := runWith(t, testMedia, "convert", "-o", filepath.Join(dir, "no", "such", "dir.otio")); code != 2 {
+		t.Errorf("Expected status 2 for an unwritable output, got %d", code)
+	}
+	if code, _, _ := runWith(t, testMedia, "convert", "a", "b"); code != 2 {
+		t.Errorf("Expected status 2 for bad arguments, got %d", code)
+	}
+}
+
+func TestRunInspect(t *testing.T) {
+	code, out, _ := runWith(t, testMedia, "inspect", "-")
+	if code != 0 || !strings.Contains(out, "segments         2 (") {
+		t.Errorf("Expected a summary and status 0, got %d:\n%s", code, out)
+	}
+	if code, _, _ := runWith(t, "not a playlist", "inspect"); code != 2 {
+		t.Errorf("Expected status 2 for a parse failure, got %d", code)
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	if code, out, _ := runWith(t, testMedia, "lint"); code != 0 || out != "" {
+		t.Errorf("Expected a clean playlist, got %d:\n%s", code, out)
+	}
+
+	// A VOD playlist without EXT-X-ENDLIST is a warning
+	unended := strings.TrimSuffix(testMedia, "#EXT-X-ENDLIST\n")
+	code, out, _ := runWith(t, unended, "lint")
+	if code != 0 || !strings.Contains(out, "EXT-X-ENDLIST") {
+		t.Errorf("Expected a warning and status 0, got %d:\n%s", code, out)
+	}
+	if code, _, _ := runWith(t, unended, "lint", "-fail-on", "warning"); code != 1 {
+		t.Errorf("Expected status 1 failing on warnings, got %d", code)
+	}
+	if code, out, _ := runWith(t, unended, "lint", "-q", "-fail-on", "info"); code != 1 || out != "" {
+		t.Errorf("Expected status 1 and no output, got %d:\n%s", code, out)
+	}
+
+	if code, _, _ := runWith(t, testMedia, "lint", "-fail-on", "fatal"); code != 2 {
+		t.Errorf("Expected status 2 for an unknown severity, got %d", code)
+	}
+	if code, _, stderr := runWith(t, testMedia, "lint", "-profile", "apple"); code != 2 || !strings.Contains(stderr, "multivariant") {
+		t.Errorf("Expected status 2 for the apple profile on stdin, got %d %q", code, stderr)
+	}
+	if code, _, _ := runWith(t, "not a playlist", "lint"); code != 2 {
+		t.Errorf("Expected status 2 for a parse failure, got %d", code)
+	}
+}
+
+func TestRunPackage(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	if code, _, stderr := runWith(t, testMedia, "package", "-o", out); code != 0 {
+		t.Fatalf("Expected status 0, got %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(out, "master.m3u8")); err != nil {
+		t.Errorf("Expected a playlist in the package: %v", err)
+	}
+
+	if code, _, _ := runWith(t, testMedia, "package"); code != 2 {
+		t.Errorf("Expected status 2 without -o, got %d", code)
+	}
+	code, _, _ := runWith(t, testMedia, "package", "-segments", filepath.Join(dir, "missing"), "-o", filepath.Join(dir, "partial"))
+	if code != 2 {
+		t.Errorf("Expected status 2 for missing segments, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partial", "master.m3u8")); err == nil {
+		t.Error("Expected no playlist left behind")
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// runPackage writes the multivariant playlist, media playlists and
+// optionally the segments of a timeline into a directory
+func runPackage(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("package", "-o <dir> [timeline]")
+	output := fs.String("o", "", "write the package into `dir`")
+	segments := fs.String("segments", "", "copy the segments the clips refer to from `dir`")
+	link := fs.Bool("link", false, "hard link the segments from -segments instead of copying them")
+	iframes := fs.Bool("iframes", false, "generate I-frame playlists from the segments")
+	bandwidth := fs.Bool("bandwidth", false, "measure BANDWIDTH and AVERAGE-BANDWIDTH from the segments")
+	version := fs.Int("version", 0, "write EXT-X-VERSION `n`, down-leveling the playlists to it")
+	layout := hls.DefaultPackageLayout()
+	fs.StringVar(&layout.Multivariant, "multivariant", layout.Multivariant, "multivariant playlist `path`")
+	fs.StringVar(&layout.Media, "media", layout.Media, "media playlist path `template`")
+	fs.StringVar(&layout.IFrame, "iframe", layout.IFrame, "I-frame playlist path `template`")
+	fs.StringVar(&layout.Segment, "segment", layout.Segment, "segment path `template`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" || fs.NArg() > 1 || (*link && *segments == "") {
+		fs.Usage()
+		return errUsage
+	}
+
+	timeline, err := readTimeline(fs.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*output, 0o755); err != nil {
+		return err
+	}
+
+	opts := []hls.PackageOption{hls.WithLayout(layout)}
+	switch {
+	case *segments != "" && *link:
+		opts = append(opts, hls.WithSegmentLinks(*segments))
+	case *segments != "":
+		opts = append(opts, hls.WithSegmentCopy(os.DirFS(*segments)))
+	}
+	if *iframes {
+		opts = append(opts, hls.WithIFramePlaylists())
+	}
+	if *bandwidth {
+		opts = append(opts, hls.WithComputedBandwidth())
+	}
+	if *version > 0 {
+		opts = append(opts, hls.WithEncoderOptions(hls.WithVersion(*version)))
+	}
+	return hls.Package(context.Background(), timeline, hls.DirFS(*output), opts...)
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// runScan builds a VOD playlist from a directory of media segments
+func runScan(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("scan", "<dir>")
+	glob := fs.String("glob", "", "only scan files matching `pattern`, e.g. \"segment_*.ts\"")
+	output := fs.String("o", "", "write to `file` instead of stdout; a .otio file gets the timeline")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var opts []hls.ScanOption
+	if *glob != "" {
+		opts = append(opts, hls.ScanGlob(*glob))
+	}
+	timeline, err := hls.TimelineFromDir(context.Background(), os.DirFS(fs.Arg(0)), ".", opts...)
+	if err != nil {
+		return err
+	}
+	return writeTimeline(timeline, *output, isOTIO(*output), stdout)
+}
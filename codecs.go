@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codec is one RFC 6381 entry of a CODECS attribute, such as "avc1.640028"
+// or "mp4a.40.2". Fields a codec does not use are zero.
+type Codec struct {
+	// FourCC is the sample entry type, as in "avc1", "hvc1" or "mp4a"
+	FourCC string
+	// Profile is the profile_idc of AVC and HEVC, the profile of AV1, VP9
+	// and Dolby Vision, or the presentation version of AC-4
+	Profile int
+	// Constraints are the AVC constraint flags or the HEVC profile
+	// compatibility flags
+	Constraints uint32
+	// Level is the level_idc of AVC and HEVC, the seq_level_idx of AV1,
+	// the level of VP9 and Dolby Vision, or the mdcompat of AC-4
+	Level int
+	// HighTier is the HEVC or AV1 tier
+	HighTier bool
+	// BitDepth is given by AV1 and VP9 codec strings
+	BitDepth int
+	// ObjectType is the MPEG-4 object type indication of mp4a, as in 0x40
+	ObjectType int
+	// AudioObjectType is the MPEG-4 audio object type, as in 2 for AAC-LC
+	AudioObjectType int
+	// Version is the AC-4 bitstream version
+	Version int
+	// Extra holds trailing fields the type does not model, such as HEVC
+	// constraint bytes or AV1 colour information
+	Extra []string
+}
+
+// codecFamilies names the codec of each sample entry type
+var codecFamilies = map[string]string{
+	"avc1": "AVC", "avc3": "AVC",
+	"hvc1": "HEVC", "hev1": "HEVC", "mvc1": "MV-HEVC",
+	"dvh1": "Dolby Vision", "dvhe": "Dolby Vision", "dva1": "Dolby Vision",
+	"dvav": "Dolby Vision", "dav1": "Dolby Vision",
+	"av01": "AV1",
+	"vp09": "VP9",
+	"mp4a": "AAC",
+	"ac-3": "AC-3", "ec-3": "E-AC-3", "ac-4": "AC-4",
+	"alac": "ALAC", "fLaC": "FLAC", "Opus": "Opus",
+	"wvtt": "WebVTT", "stpp": "IMSC",
+}
+
+// ParseCodec parses a single codec string. Sample entry types it does not
+// know are accepted with their fields kept in Extra.
+func ParseCodec(s string) (Codec, error) {
+	fields := strings.Split(strings.TrimSpace(s), ".")
+	c := Codec{FourCC: fields[0]}
+	if c.FourCC == "" {
+		return c, fmt.Errorf("invalid codec %q", s)
+	}
+	p := codecParser{fields: fields[1:]}
+
+	switch codecFamilies[c.FourCC] {
+	case "AVC":
+		if len(p.fields) > 0 && len(p.fields[0]) == 6 {
+			v := p.hex(p.next())
+			c.Profile, c.Constraints, c.Level = int(v>>16), uint32(v>>8&0xff), int(v&0xff)
+		} else {
+			p.bad = true
+		}
+	case "HEVC", "MV-HEVC":
+		c.Profile = p.int(p.next())
+		c.Constraints = uint32(p.hex(p.next()))
+		level := p.next()
+		if len(level) < 2 || (level[0] != 'L' && level[0] != 'H') {
+			p.bad = true
+		} else {
+			c.HighTier = level[0] == 'H'
+			c.Level = p.int(level[1:])
+		}
+	case "Dolby Vision":
+		c.Profile = p.int(p.next())
+		c.Level = p.int(p.next())
+	case "AV1":
+		c.Profile = p.int(p.next())
+		level := p.next()
+		if len(level) != 3 || (level[2] != 'M' && level[2] != 'H') {
+			p.bad = true
+		} else {
+			c.HighTier = level[2] == 'H'
+			c.Level = p.int(level[:2])
+		}
+		c.BitDepth = p.int(p.next())
+	case "VP9":
+		c.Profile = p.int(p.next())
+		c.Level = p.int(p.next())
+		c.BitDepth = p.int(p.next())
+	case "AAC":
+		if len(p.fields) > 0 {
+			c.ObjectType = int(p.hex(p.next()))
+			if len(p.fields) > 0 {
+				c.AudioObjectType = p.int(p.next())
+			}
+		}
+	case "AC-4":
+		c.Version = p.int(p.next())
+		c.Profile = p.int(p.next())
+		c.Level = p.int(p.next())
+	}
+	if p.bad {
+		return c, fmt.Errorf("invalid codec %q", s)
+	}
+	if len(p.fields) > 0 {
+		c.Extra = p.fields
+	}
+	return c, nil
+}
+
+// ParseCodecs parses a comma-separated CODECS value
+func ParseCodecs(s string) ([]Codec, error) {
+	var codecs []Codec
+	for _, entry := range splitCodecs(s) {
+		c, err := ParseCodec(entry)
+		if err != nil {
+			return nil, err
+		}
+		codecs = append(codecs, c)
+	}
+	return codecs, nil
+}
+
+// codecParser consumes the dot-separated fields of a codec string and
+// remembers the first malformed one
+type codecParser struct {
+	fields []string
+	bad    bool
+}
+
+func (p *codecParser) next() string {
+	if len(p.fields) == 0 {
+		p.bad = true
+		return ""
+	}
+	field := p.fields[0]
+	p.fields = p.fields[1:]
+	return field
+}
+
+func (p *codecParser) int(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		p.bad = true
+	}
+	return n
+}
+
+func (p *codecParser) hex(s string) uint64 {
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		p.bad = true
+	}
+	return n
+}
+
+// Family names the codec, as in "HEVC" or "E-AC-3", or is empty for
+// sample entry types the package does not know
+func (c Codec) Family() string {
+	family := codecFamilies[c.FourCC]
+	if family == "AAC" && (c.ObjectType == 0x69 || c.ObjectType == 0x6b || c.AudioObjectType == 34) {
+		return "MP3"
+	}
+	return family
+}
+
+// MediaType is the kind of media the codec carries: MediaTypeVideo,
+// MediaTypeAudio or MediaTypeSubtitles, or empty when unknown
+func (c Codec) MediaType() MediaType {
+	switch c.Family() {
+	case "":
+		return ""
+	case "WebVTT", "IMSC":
+		return MediaTypeSubtitles
+	case "AAC", "MP3", "AC-3", "E-AC-3", "AC-4", "ALAC", "FLAC", "Opus":
+		return MediaTypeAudio
+	}
+	return MediaTypeVideo
+}
+
+// String builds the codec string
+func (c Codec) String() string {
+	fields := []string{c.FourCC}
+	tier := func(low, high string) string {
+		if c.HighTier {
+			return high
+		}
+		return low
+	}
+	switch codecFamilies[c.FourCC] {
+	case "AVC":
+		fields = append(fields, fmt.Sprintf("%02x%02x%02x", c.Profile, c.Constraints, c.Level))
+	case "HEVC", "MV-HEVC":
+		fields = append(fields, strconv.Itoa(c.Profile), strconv.FormatUint(uint64(c.Constraints), 16),
+			tier("L", "H")+strconv.Itoa(c.Level))
+	case "Dolby Vision":
+		fields = append(fields, fmt.Sprintf("%02d", c.Profile), fmt.Sprintf("%02d", c.Level))
+	case "AV1":
+		fields = append(fields, strconv.Itoa(c.Profile), fmt.Sprintf("%02d", c.Level)+tier("M", "H"),
+			fmt.Sprintf("%02d", c.BitDepth))
+	case "VP9":
+		fields = append(fields, fmt.Sprintf("%02d", c.Profile), fmt.Sprintf("%02d", c.Level),
+			fmt.Sprintf("%02d", c.BitDepth))
+	case "AAC":
+		if c.ObjectType != 0 {
+			fields = append(fields, strconv.FormatUint(uint64(c.ObjectType), 16))
+			if c.AudioObjectType != 0 {
+				fields = append(fields, strconv.Itoa(c.AudioObjectType))
+			}
+		}
+	case "AC-4":
+		fields = append(fields, fmt.Sprintf("%02d", c.Version), fmt.Sprintf("%02d", c.Profile),
+			fmt.Sprintf("%02d", c.Level))
+	}
+	return strings.Join(append(fields, c.Extra...), ".")
+}
+
+// FormatCodecs builds a CODECS value with duplicates removed and video
+// codecs before audio, then subtitles, then codecs of unknown type
+func FormatCodecs(codecs []Codec) string {
+	entries := make([]string, len(codecs))
+	types := make([]MediaType, len(codecs))
+	for i, c := range codecs {
+		entries[i], types[i] = c.String(), c.MediaType()
+	}
+	return orderCodecs(entries, types)
+}
+
+// mergeCodecs adds codecs to a CODECS value, dropping duplicates and
+// ordering the result like FormatCodecs. Entries are kept as written;
+// those that do not parse are compared as text and go last.
+func mergeCodecs(codecs string, add []string) string {
+	var entries []string
+	var types []MediaType
+	seen := make(map[string]bool)
+	for _, entry := range append(splitCodecs(codecs), add...) {
+		key := entry
+		var mediaType MediaType
+		if c, err := ParseCodec(entry); err == nil {
+			key, mediaType = c.String(), c.MediaType()
+		}
+		if !seen[key] {
+			seen[key] = true
+			entries = append(entries, entry)
+			types = append(types, mediaType)
+		}
+	}
+	return orderCodecs(entries, types)
+}
+
+// codecOrder ranks media types in a CODECS value
+var codecOrder = map[MediaType]int{
+	MediaTypeVideo:     0,
+	MediaTypeAudio:     1,
+	MediaTypeSubtitles: 2,
+	"":                 3,
+}
+
+// orderCodecs sorts entries by their media types, keeping the order within
+// each type, drops duplicates and joins them
+func orderCodecs(entries []string, types []MediaType) string {
+	index := make([]int, len(entries))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		return codecOrder[types[index[a]]] < codecOrder[types[index[b]]]
+	})
+	var out []string
+	for _, i := range index {
+		if !containsString(out, entries[i]) {
+			out = append(out, entries[i])
+		}
+	}
+	return strings.Join(out, ",")
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCodec(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   Codec
+		family string
+		media  MediaType
+	}{
+		{"avc1.640028", Codec{FourCC: "avc1", Profile: 100, Level: 40}, "AVC", MediaTypeVideo},
+		{"avc1.4d401f", Codec{FourCC: "avc1", Profile: 77, Constraints: 0x40, Level: 31}, "AVC", MediaTypeVideo},
+		{"hvc1.2.4.L153.B0", Codec{FourCC: "hvc1", Profile: 2, Constraints: 4, Level: 153, Extra: []string{"B0"}}, "HEVC", MediaTypeVideo},
+		{"hev1.1.6.H150.90", Codec{FourCC: "hev1", Profile: 1, Constraints: 6, Level: 150, HighTier: true, Extra: []string{"90"}}, "HEVC", MediaTypeVideo},
+		{"dvh1.08.07", Codec{FourCC: "dvh1", Profile: 8, Level: 7}, "Dolby Vision", MediaTypeVideo},
+		{"av01.0.08M.10", Codec{FourCC: "av01", Level: 8, BitDepth: 10}, "AV1", MediaTypeVideo},
+		{"av01.0.13H.10.0.110.09.16.09.0", Codec{FourCC: "av01", Level: 13, HighTier: true, BitDepth: 10,
+			Extra: []string{"0", "110", "09", "16", "09", "0"}}, "AV1", MediaTypeVideo},
+		{"vp09.00.10.08", Codec{FourCC: "vp09", Level: 10, BitDepth: 8}, "VP9", MediaTypeVideo},
+		{"mp4a.40.2", Codec{FourCC: "mp4a", ObjectType: 0x40, AudioObjectType: 2}, "AAC", MediaTypeAudio},
+		{"mp4a.40.34", Codec{FourCC: "mp4a", ObjectType: 0x40, AudioObjectType: 34}, "MP3", MediaTypeAudio},
+		{"ec-3", Codec{FourCC: "ec-3"}, "E-AC-3", MediaTypeAudio},
+		{"ac-4.02.01.03", Codec{FourCC: "ac-4", Version: 2, Profile: 1, Level: 3}, "AC-4", MediaTypeAudio},
+		{"wvtt", Codec{FourCC: "wvtt"}, "WebVTT", MediaTypeSubtitles},
+		{"stpp.ttml.im1t", Codec{FourCC: "stpp", Extra: []string{"ttml", "im1t"}}, "IMSC", MediaTypeSubtitles},
+		{"xyz1.2.3", Codec{FourCC: "xyz1", Extra: []string{"2", "3"}}, "", ""},
+	}
+	for _, tt := range tests {
+		c, err := ParseCodec(tt.in)
+		if err != nil {
+			t.Errorf("ParseCodec(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(c, tt.want) {
+			t.Errorf("ParseCodec(%q) = %+v, want %+v", tt.in, c, tt.want)
+		}
+		if c.Family() != tt.family || c.MediaType() != tt.media {
+			t.Errorf("%q: expected %q %q, got %q %q", tt.in, tt.family, tt.media, c.Family(), c.MediaType())
+		}
+		if c.String() != tt.in {
+			t.Errorf("Expected %q to build back unchanged, got %q", tt.in, c.String())
+		}
+	}
+
+	for _, in := range []string{"", "avc1", "avc1.64002", "hvc1.2.4.X153", "av01.0.08", "dvh1.xx.07", "ac-4.02"} {
+		if _, err := ParseCodec(in); err == nil {
+			t.Errorf("Expected ParseCodec(%q) to fail", in)
+		}
+	}
+}
+
+func TestFormatCodecs(t *testing.T) {
+	codecs, err := ParseCodecs("mp4a.40.2, wvtt,hvc1.2.4.L153.B0,mp4a.40.2,ec-3")
+	if err != nil {
+		t.Fatalf("ParseCodecs failed: %v", err)
+	}
+	if got := FormatCodecs(codecs); got != "hvc1.2.4.L153.B0,mp4a.40.2,ec-3,wvtt" {
+		t.Errorf("Unexpected CODECS %q", got)
+	}
+
+	// Merged entries keep their spelling and match regardless of case
+	if got := mergeCodecs("mp4a.40.2,avc1.4D401F", []string{"avc1.4d401f", "ec-3"}); got != "avc1.4D401F,mp4a.40.2,ec-3" {
+		t.Errorf("Unexpected merged CODECS %q", got)
+	}
+}
@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// Concat joins single-track timelines, such as a pre-roll, a feature and a
+// post-roll decoded from media playlists, into one timeline whose track
+// holds copies of all their clips in order. The inputs are not changed.
+//
+// Every input after the first starts with a discontinuity: clip
+// discontinuity_sequence metadata is renumbered to rise at each join. The
+// track keeps the header metadata of the first input, with the highest
+// target duration and version of all of them. Clips keep their own keys
+// and initialization sections. Once an input carries program date-times,
+// those of the inputs after it are shifted to carry on from where it ends,
+// together with their date ranges. AES-128 keys without an IV get the
+// explicit IV of their original media sequence number when a clip moves to
+// another one.
+func Concat(timelines ...*gotio.Timeline) (*gotio.Timeline, error) {
+	if len(timelines) == 0 {
+		return nil, fmt.Errorf("nothing to concatenate")
+	}
+
+	var tracks []*gotio.Track
+	for i, t := range timelines {
+		track, err := singleTrack(t)
+		if err != nil {
+			return nil, fmt.Errorf("timeline %d: %w", i, err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	header, err := concatHeader(tracks)
+	if err != nil {
+		return nil, err
+	}
+	out := derivedTrack(tracks[0], header)
+	mediaSequence, _ := asInt64(header["media_sequence"])
+
+	var (
+		// seq is the discontinuity sequence number of the last clip
+		seq     int
+		hasMap  bool
+		nextPDT time.Time
+	)
+	for i, track := range tracks {
+		base, _ := asInt64(namespace(track.Metadata(), metadataNamespace)["discontinuity_sequence"])
+		from, _ := asInt64(namespace(track.Metadata(), metadataNamespace)["media_sequence"])
+		// The first input keeps its numbers, the others continue after
+		// the last with a discontinuity
+		offset := int(base)
+		if i > 0 {
+			offset = seq + 1
+		}
+		// shift moves the date-times of this input to carry on from the
+		// inputs before it
+		var shift time.Duration
+		shiftKnown := i == 0 || nextPDT.IsZero()
+
+		first := true
+		for _, child := range track.Children() {
+			clip, ok := child.(*gotio.Clip)
+			if !ok {
+				continue
+			}
+			hlsMD := namespace(clip.Metadata(), metadataNamespace)
+			clipSeq := int(base)
+			if v, ok := asInt64(hlsMD["discontinuity_sequence"]); ok {
+				clipSeq = int(v)
+			}
+			seq = clipSeq - int(base) + offset
+
+			_, clipHasMap := namespace(clip.Metadata(), streamingMetadataNamespace)["init_uri"].(string)
+			if hasMap && !clipHasMap {
+				return nil, fmt.Errorf("timeline %d: clip %q has no initialization section but follows clips with one", i, clip.Name())
+			}
+			hasMap = hasMap || clipHasMap
+
+			var pdt time.Time
+			if value, ok := hlsMD["EXT-X-PROGRAM-DATE-TIME"].(string); ok {
+				pdt, _ = parseDateTime(value)
+			}
+			if !pdt.IsZero() && !shiftKnown {
+				shift = nextPDT.Sub(pdt)
+				shiftKnown = true
+			}
+			setPDT := time.Time{}
+			switch {
+			case !pdt.IsZero():
+				setPDT = pdt.Add(shift)
+			case first && i > 0 && !nextPDT.IsZero():
+				// A discontinuity needs a date-time when the stream has them
+				setPDT = nextPDT
+			}
+
+			to := mediaSequence + int64(len(out.Children()))
+			copied := concatClip(clip, seq, setPDT, shift, from, to)
+			if err := out.AppendChild(copied); err != nil {
+				return nil, err
+			}
+			from++
+
+			if duration, err := clip.Duration(); err == nil {
+				switch {
+				case !setPDT.IsZero():
+					nextPDT = setPDT.Add(seconds(duration.ToSeconds()))
+				case !nextPDT.IsZero():
+					nextPDT = nextPDT.Add(seconds(duration.ToSeconds()))
+				}
+			}
+			first = false
+		}
+	}
+
+	return derivedTimeline(timelines[0], out)
+}
+
+// ConcatPlaylists is Concat for media playlists
+func ConcatPlaylists(playlists ...*MediaPlaylist) (*gotio.Timeline, error) {
+	d := NewDecoder(nil)
+	timelines := make([]*gotio.Timeline, len(playlists))
+	for i, p := range playlists {
+		timelines[i] = d.decodeMediaPlaylist(p)
+	}
+	return Concat(timelines...)
+}
+
+// singleTrack returns the only track of a timeline
+func singleTrack(t *gotio.Timeline) (*gotio.Track, error) {
+	if t == nil || t.Tracks() == nil {
+		return nil, fmt.Errorf("timeline has no tracks")
+	}
+	children := t.Tracks().Children()
+	if len(children) != 1 {
+		return nil, fmt.Errorf("expected one track, got %d", len(children))
+	}
+	track, ok := children[0].(*gotio.Track)
+	if !ok {
+		return nil, fmt.Errorf("expected Track, got %T", children[0])
+	}
+	return track, nil
+}
+
+// derivedTrack returns an empty track named like track, with a copy of its
+// metadata holding header as the HLS metadata
+func derivedTrack(track *gotio.Track, header map[string]interface{}) *gotio.Track {
+	out := gotio.NewTrack(track.Name(), nil, track.Kind(), nil, nil)
+	metadata, _ := copyMetadata(track.Metadata()).(gotio.AnyDictionary)
+	if metadata == nil {
+		metadata = make(gotio.AnyDictionary)
+	}
+	metadata[metadataNamespace] = header
+	out.SetMetadata(metadata)
+	return out
+}
+
+// derivedTimeline returns a timeline named like t, with a copy of its
+// metadata, holding track
+func derivedTimeline(t *gotio.Timeline, track *gotio.Track) (*gotio.Timeline, error) {
+	timeline := gotio.NewTimeline(t.Name(), nil, nil)
+	if md := t.Metadata(); md != nil {
+		timeline.SetMetadata(copyMetadata(md).(gotio.AnyDictionary))
+	}
+	if err := timeline.Tracks().AppendChild(track); err != nil {
+		return nil, err
+	}
+	return timeline, nil
+}
+
+// concatHeader builds the track HLS metadata of the joined track
+func concatHeader(tracks []*gotio.Track) (map[string]interface{}, error) {
+	header := copyMetadata(namespace(tracks[0].Metadata(), metadataNamespace)).(map[string]interface{})
+	iframesOnly, _ := header["iframes_only"].(bool)
+	independent, _ := header["independent_segments"].(bool)
+	playlistType, _ := header["playlist_type"].(string)
+
+	for i, track := range tracks {
+		md := namespace(track.Metadata(), metadataNamespace)
+		if only, _ := md["iframes_only"].(bool); only != iframesOnly {
+			return nil, fmt.Errorf("timeline %d: cannot join I-frame and media playlists", i)
+		}
+		if v, _ := md["independent_segments"].(bool); !v {
+			independent = false
+		}
+		if v, _ := md["playlist_type"].(string); v != playlistType {
+			playlistType = ""
+		}
+	}
+
+	raiseHeader(header, tracks)
+	delete(header, "independent_segments")
+	if independent {
+		header["independent_segments"] = true
+	}
+	delete(header, "playlist_type")
+	if playlistType != "" {
+		header["playlist_type"] = playlistType
+	}
+	last := namespace(tracks[len(tracks)-1].Metadata(), metadataNamespace)
+	if endList, ok := last["end_list"].(bool); ok {
+		header["end_list"] = endList
+	}
+
+	// Parts after the last segment belong to the end of the last input,
+	// and the part target must cover the parts of every input
+	delete(header, "EXT-X-PART")
+	if parts, ok := last["EXT-X-PART"]; ok {
+		header["EXT-X-PART"] = copyMetadata(parts)
+	}
+	delete(header, "part_target")
+	var partTarget float64
+	for _, track := range tracks {
+		if v, ok := asFloat(namespace(track.Metadata(), metadataNamespace)["part_target"]); ok {
+			partTarget = max(partTarget, v)
+		}
+	}
+	if partTarget > 0 {
+		header["part_target"] = partTarget
+	}
+	return header, nil
+}
+
+// raiseHeader raises the target duration and version in header to the
+// highest of tracks and their clips
+func raiseHeader(header map[string]interface{}, tracks []*gotio.Track) {
+	targetDuration, _ := asInt64(header["target_duration"])
+	version, _ := asInt64(header["version"])
+	for _, track := range tracks {
+		md := namespace(track.Metadata(), metadataNamespace)
+		if v, ok := asInt64(md["version"]); ok {
+			version = max(version, v)
+		}
+		if v, ok := asInt64(md["target_duration"]); ok {
+			targetDuration = max(targetDuration, v)
+		}
+		for _, child := range track.Children() {
+			if clip, ok := child.(*gotio.Clip); ok {
+				if d, err := clip.Duration(); err == nil {
+					targetDuration = max(targetDuration, int64(math.Round(d.ToSeconds())))
+				}
+			}
+		}
+	}
+	if targetDuration > 0 {
+		header["target_duration"] = targetDuration
+	}
+	if version > 0 {
+		header["version"] = version
+	}
+}
+
+// concatClip copies a clip with a new discontinuity sequence number, its
+// date-time set to pdt, its date ranges moved by shift and its IV pinned
+// for the move from media sequence number from to to
+func concatClip(clip *gotio.Clip, seq int, pdt time.Time, shift time.Duration, from, to int64) *gotio.Clip {
+	return copyClip(clip, func(hlsMD map[string]interface{}) {
+		pinIV(hlsMD, from, to)
+		delete(hlsMD, "discontinuity_sequence")
+		if seq > 0 {
+			hlsMD["discontinuity_sequence"] = seq
+		}
+		if !pdt.IsZero() {
+			hlsMD["EXT-X-PROGRAM-DATE-TIME"] = formatDateTime(pdt)
+		}
+		shiftDateRanges(hlsMD, shift)
+	})
+}
+
+// pinIV gives an AES-128 key without an IV in clip HLS metadata the IV it
+// has implicitly as media sequence number from, for a clip moving to
+// number to
+func pinIV(hlsMD map[string]interface{}, from, to int64) {
+	value, ok := hlsMD["EXT-X-KEY"].(string)
+	if !ok || from == to {
+		return
+	}
+	key, err := parseKey(ParseAttributeList(value))
+	if err != nil || key.Method != "AES-128" || key.IV != "" {
+		return
+	}
+	key.IV = fmt.Sprintf("0x%032x", from)
+	hlsMD["EXT-X-KEY"] = key.String()
+}
+
+// shiftDateRanges moves the EXT-X-DATERANGE dates in clip HLS metadata
+func shiftDateRanges(hlsMD map[string]interface{}, shift time.Duration) {
+	if shift == 0 {
+		return
+	}
+	ranges := asStrings(hlsMD["EXT-X-DATERANGE"])
+	for i, value := range ranges {
+		dr, err := parseDateRange(ParseAttributeList(value))
+		if err != nil {
+			continue
+		}
+		if !dr.StartDate.IsZero() {
+			dr.StartDate = dr.StartDate.Add(shift)
+		}
+		if !dr.EndDate.IsZero() {
+			dr.EndDate = dr.EndDate.Add(shift)
+		}
+		ranges[i] = dr.String()
+	}
+	if len(ranges) > 0 {
+		hlsMD["EXT-X-DATERANGE"] = stringList(ranges)
+	}
+}
+
+// seconds converts seconds to a time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
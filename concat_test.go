@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+const concatPreRoll = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z
+#EXTINF:4.0,
+ad0.ts
+#EXTINF:2.0,
+ad1.ts
+#EXT-X-ENDLIST
+`
+
+const concatFeature = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:10
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-DISCONTINUITY-SEQUENCE:7
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin"
+#EXT-X-PROGRAM-DATE-TIME:2030-06-01T12:00:00.000Z
+#EXT-X-DATERANGE:ID="chapter",START-DATE="2030-06-01T12:00:05.000Z",DURATION=5
+#EXTINF:10.0,
+#EXT-X-BYTERANGE:1000@0
+main.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:9.5,
+#EXT-X-BYTERANGE:900@1000
+main.ts
+#EXT-X-ENDLIST
+`
+
+func mustUnmarshalMedia(t *testing.T, data string) *MediaPlaylist {
+	t.Helper()
+	p, err := Unmarshal([]byte(data))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	media, ok := p.(*MediaPlaylist)
+	if !ok {
+		t.Fatalf("Expected media playlist, got %T", p)
+	}
+	return media
+}
+
+func TestConcatPlaylists(t *testing.T) {
+	timeline, err := ConcatPlaylists(
+		mustUnmarshalMedia(t, concatPreRoll),
+		mustUnmarshalMedia(t, concatFeature),
+		mustUnmarshalMedia(t, concatPreRoll),
+	)
+	if err != nil {
+		t.Fatalf("ConcatPlaylists failed: %v", err)
+	}
+	if n := len(firstTrack(t, timeline).Children()); n != 6 {
+		t.Fatalf("Expected 6 clips, got %d", n)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	p := mustUnmarshalMedia(t, buf.String())
+
+	if p.TargetDuration != 10 || p.Version != 4 || p.PlaylistType != "VOD" || !p.EndList {
+		t.Errorf("Expected reconciled header, got:\n%s", buf.String())
+	}
+	wantDiscontinuity := []bool{false, false, true, true, true, false}
+	for i, seg := range p.Segments {
+		if seg.Discontinuity != wantDiscontinuity[i] {
+			t.Errorf("Segment %d: expected discontinuity %v, got %v", i, wantDiscontinuity[i], seg.Discontinuity)
+		}
+	}
+
+	// Keys stay with the feature's segments
+	for i, seg := range p.Segments {
+		encrypted := seg.Key != nil && seg.Key.Method == "AES-128"
+		if want := i == 2 || i == 3; encrypted != want {
+			t.Errorf("Segment %d: expected encrypted %v, got key %v", i, want, seg.Key)
+		}
+	}
+
+	// Date-times carry on from the pre-roll, with the date range shifted
+	// by the same amount
+	wantPDT := []string{
+		"2024-01-01T00:00:00.000Z",
+		"2024-01-01T00:00:06.000Z",
+		"2024-01-01T00:00:25.500Z",
+	}
+	for i, index := range []int{0, 2, 4} {
+		if got := formatDateTime(p.Segments[index].ProgramDateTime); got != wantPDT[i] {
+			t.Errorf("Segment %d: expected date-time %s, got %s", index, wantPDT[i], got)
+		}
+	}
+	if ranges := p.Segments[2].DateRanges; len(ranges) != 1 || formatDateTime(ranges[0].StartDate) != "2024-01-01T00:00:11.000Z" {
+		t.Errorf("Expected date range shifted to 00:00:11, got %v", ranges)
+	}
+}
+
+func TestConcatLowLatency(t *testing.T) {
+	first := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-PART-INF:PART-TARGET=1
+#EXTINF:4,
+a0.mp4
+#EXT-X-PART:DURATION=1,URI="a1.0.mp4"
+`
+	second := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-PART-INF:PART-TARGET=0.5
+#EXTINF:4,
+b0.mp4
+#EXT-X-PART:DURATION=0.5,URI="b1.0.mp4"
+`
+	timeline, err := ConcatPlaylists(mustUnmarshalMedia(t, first), mustUnmarshalMedia(t, second))
+	if err != nil {
+		t.Fatalf("ConcatPlaylists failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	p := mustUnmarshalMedia(t, buf.String())
+	if p.PartTarget != 1 || len(p.Parts) != 1 || p.Parts[0].URI != "b1.0.mp4" {
+		t.Errorf("Expected the trailing part of the last input, got:\n%s", buf.String())
+	}
+}
+
+func TestConcatLeavesInputs(t *testing.T) {
+	feature, err := NewDecoder(strings.NewReader(concatFeature)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	preRoll, err := NewDecoder(strings.NewReader(concatPreRoll)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, err := Concat(preRoll, feature); err != nil {
+		t.Fatalf("Concat failed: %v", err)
+	}
+	clip := firstTrack(t, feature).Children()[0]
+	md := namespace(clip.(*gotio.Clip).Metadata(), metadataNamespace)
+	if md["EXT-X-PROGRAM-DATE-TIME"] != "2030-06-01T12:00:00.000Z" {
+		t.Errorf("Expected input clip unchanged, got %v", md)
+	}
+}
+
+func TestConcatPinsImplicitIVs(t *testing.T) {
+	preRoll := mustUnmarshalMedia(t, `#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.0,
+ad0.ts
+#EXT-X-ENDLIST
+`)
+	feature := mustUnmarshalMedia(t, `#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-KEY:METHOD=AES-128,URI="k.bin"
+#EXTINF:4.0,
+main0.ts
+#EXTINF:4.0,
+main1.ts
+#EXT-X-ENDLIST
+`)
+	timeline, err := ConcatPlaylists(preRoll, feature)
+	if err != nil {
+		t.Fatalf("ConcatPlaylists failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	p := mustUnmarshalMedia(t, buf.String())
+	if p.Version < 2 {
+		t.Errorf("Expected version 2 for explicit IVs, got %d", p.Version)
+	}
+	for i, want := range []string{"", "0x00000000000000000000000000000064", "0x00000000000000000000000000000065"} {
+		var iv string
+		if key := p.Segments[i].Key; key != nil {
+			iv = key.IV
+		}
+		if iv != want {
+			t.Errorf("Segment %d: expected IV %q, got %q", i, want, iv)
+		}
+	}
+}
+
+func TestConcatErrors(t *testing.T) {
+	if _, err := Concat(); err == nil {
+		t.Error("Expected error for no timelines")
+	}
+
+	fmp4 := mustUnmarshalMedia(t, `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4.0,
+seg0.m4s
+#EXT-X-ENDLIST
+`)
+	if _, err := ConcatPlaylists(fmp4, mustUnmarshalMedia(t, concatPreRoll)); err == nil {
+		t.Error("Expected error for segments without a map after segments with one")
+	}
+
+	iframes := mustUnmarshalMedia(t, `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXT-X-I-FRAMES-ONLY
+#EXTINF:4.0,
+#EXT-X-BYTERANGE:100@0
+main.ts
+#EXT-X-ENDLIST
+`)
+	if _, err := ConcatPlaylists(mustUnmarshalMedia(t, concatPreRoll), iframes); err == nil {
+		t.Error("Expected error for joining I-frame and media playlists")
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// cutKey is the clip HLS metadata key recording the edit clip a segment
+// was cut for
+const cutKey = "cut"
+
+// Cut turns an edit into one playlist. The edit is a single-track timeline
+// of clips whose external references name HLS sources, and whose source
+// ranges pick the part of each source to use, measured from its first
+// segment. sources holds the decoded source timelines by target URL.
+//
+// Each clip becomes the run of source segments that covers its source
+// range, with a discontinuity between runs. Segments are whole, so the
+// first and last of each run record where the clip begins and ends inside
+// them as in_offset and out_offset metadata, in seconds, for a player or
+// re-encoder to trim exactly. Every segment also records cut metadata: the
+// index and name of its edit clip and the frame rate of its source range,
+// with the offsets as in_frames and out_frames at that rate. The playlist
+// starts on the first frame with EXT-X-START:TIME-OFFSET=...,PRECISE=YES.
+// It numbers its segments from media sequence 0, so AES-128 keys without
+// an IV get the explicit IV of their source media sequence number.
+//
+// The result is a VOD playlist with the header metadata of the first
+// source, the highest target duration and version of all of them, and
+// neither program date-times nor the date ranges that need them. Sources
+// are not changed.
+func Cut(edit *gotio.Timeline, sources map[string]*gotio.Timeline) (*gotio.Timeline, error) {
+	track, err := singleTrack(edit)
+	if err != nil {
+		return nil, err
+	}
+
+	type cut struct {
+		clip          *gotio.Clip
+		source        *gotio.Track
+		clips         []trimClip
+		in, out, rate float64
+	}
+	var cuts []cut
+	var used []*gotio.Track
+	for i, child := range track.Children() {
+		clip, ok := child.(*gotio.Clip)
+		if !ok {
+			return nil, fmt.Errorf("item %d: expected Clip, got %T", i, child)
+		}
+		ref, ok := clip.MediaReference().(*gotio.ExternalReference)
+		if !ok {
+			return nil, fmt.Errorf("clip %q: expected ExternalReference, got %T", clip.Name(), clip.MediaReference())
+		}
+		source, ok := sources[ref.TargetURL()]
+		if !ok {
+			return nil, fmt.Errorf("clip %q: no source playlist for %s", clip.Name(), ref.TargetURL())
+		}
+		sourceTrack, err := singleTrack(source)
+		if err != nil {
+			return nil, fmt.Errorf("clip %q: source %s: %w", clip.Name(), ref.TargetURL(), err)
+		}
+		clips := trimClips(sourceTrack)
+
+		c := cut{clip: clip, source: sourceTrack}
+		start, end := 0.0, 0.0
+		for _, sc := range clips {
+			end += sc.duration
+		}
+		if r := clip.SourceRange(); r != nil {
+			start, end = r.StartTime().ToSeconds(), r.EndTimeExclusive().ToSeconds()
+			c.rate = r.Duration().Rate()
+		}
+		first, last, err := overlapping(clips, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("clip %q: %w", clip.Name(), err)
+		}
+		c.clips = clips[first : last+1]
+		c.in = roundOffset(max(0, start-clips[first].start))
+		c.out = roundOffset(end - clips[last].start)
+		cuts = append(cuts, c)
+		used = append(used, sourceTrack)
+	}
+	if len(cuts) == 0 {
+		return nil, fmt.Errorf("edit has no clips")
+	}
+
+	header, err := concatHeader(used)
+	if err != nil {
+		return nil, err
+	}
+	header["media_sequence"] = 0
+	delete(header, "discontinuity_sequence")
+	delete(header, "EXT-X-PART")
+	header["EXT-X-START"] = startString(&Start{TimeOffset: cuts[0].in, Precise: true})
+	header["playlist_type"] = string(PlaylistTypeVOD)
+	header["end_list"] = true
+
+	s := &splicer{out: derivedTrack(track, header)}
+	for n, c := range cuts {
+		last := len(c.clips) - 1
+		err := s.run(c.clips, c.source, func(i int, hlsMD map[string]interface{}) {
+			delete(hlsMD, "EXT-X-DATERANGE")
+			delete(hlsMD, inOffsetKey)
+			delete(hlsMD, outOffsetKey)
+			cutMD := map[string]interface{}{"index": n, "name": c.clip.Name()}
+			if c.rate > 0 {
+				cutMD["rate"] = c.rate
+			}
+			if i == 0 && c.in > 0 {
+				hlsMD[inOffsetKey] = c.in
+				if c.rate > 0 {
+					cutMD["in_frames"] = int64(math.Round(c.in * c.rate))
+				}
+			}
+			if i == last && c.out < c.clips[i].duration {
+				hlsMD[outOffsetKey] = c.out
+				if c.rate > 0 {
+					cutMD["out_frames"] = int64(math.Round(c.out * c.rate))
+				}
+			}
+			hlsMD[cutKey] = cutMD
+		})
+		if err != nil {
+			return nil, fmt.Errorf("clip %q: %w", c.clip.Name(), err)
+		}
+	}
+	return derivedTimeline(edit, s.out)
+}
+
+// CutPlaylists is Cut for source media playlists
+func CutPlaylists(edit *gotio.Timeline, sources map[string]*MediaPlaylist) (*gotio.Timeline, error) {
+	d := NewDecoder(nil)
+	timelines := make(map[string]*gotio.Timeline, len(sources))
+	for uri, p := range sources {
+		timelines[uri] = d.decodeMediaPlaylist(p)
+	}
+	return Cut(edit, timelines)
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/gotio/opentime"
+)
+
+func cutSource(prefix string, n int) *MediaPlaylist {
+	p := &MediaPlaylist{Version: 3, TargetDuration: 4, EndList: true, PlaylistType: PlaylistTypeVOD}
+	for i := 0; i < n; i++ {
+		p.Segments = append(p.Segments, &Segment{URI: fmt.Sprintf("%s%d.ts", prefix, i), Duration: 4})
+	}
+	return p
+}
+
+// cutClip is an edit clip at 24 fps, with its source range in frames
+type cutClip struct {
+	name, url       string
+	start, duration float64
+}
+
+func cutEdit(t *testing.T, clips ...cutClip) *gotio.Timeline {
+	t.Helper()
+	timeline := gotio.NewTimeline("edit", nil, nil)
+	track := gotio.NewTrack("V1", nil, gotio.TrackKindVideo, nil, nil)
+	for _, c := range clips {
+		r := opentime.NewTimeRange(opentime.NewRationalTime(c.start, 24), opentime.NewRationalTime(c.duration, 24))
+		ref := gotio.NewExternalReference("", c.url, nil, nil)
+		if err := track.AppendChild(gotio.NewClip(c.name, ref, &r, nil, nil, nil, "", nil)); err != nil {
+			t.Fatalf("AppendChild failed: %v", err)
+		}
+	}
+	if err := timeline.Tracks().AppendChild(track); err != nil {
+		t.Fatalf("AppendChild failed: %v", err)
+	}
+	return timeline
+}
+
+func TestCut(t *testing.T) {
+	sources := map[string]*MediaPlaylist{
+		"a.m3u8": cutSource("a", 5),
+		"b.m3u8": cutSource("b", 5),
+	}
+	// A from 2s to 9s, then B from 12s to 16s
+	edit := cutEdit(t, cutClip{"A", "a.m3u8", 48, 168}, cutClip{"B", "b.m3u8", 288, 96})
+	timeline, err := CutPlaylists(edit, sources)
+	if err != nil {
+		t.Fatalf("CutPlaylists failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	p := mustUnmarshalMedia(t, buf.String())
+	if got, want := segmentURIs(p), "a0.ts a1.ts a2.ts | b3.ts"; got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+	if p.Start == nil || p.Start.TimeOffset != 2 || !p.Start.Precise {
+		t.Errorf("Expected EXT-X-START at 2s, got %+v", p.Start)
+	}
+	if p.PlaylistType != PlaylistTypeVOD || !p.EndList || p.MediaSequence != 0 {
+		t.Errorf("Expected a VOD playlist from media sequence 0, got:\n%s", buf.String())
+	}
+
+	clips := firstTrack(t, timeline).Children()
+	md := func(i int) map[string]interface{} {
+		return namespace(clips[i].(*gotio.Clip).Metadata(), metadataNamespace)
+	}
+	first, _ := asMap(md(0)[cutKey])
+	if md(0)[inOffsetKey] != 2.0 || first["in_frames"] != int64(48) || first["name"] != "A" || first["rate"] != 24.0 {
+		t.Errorf("Expected A to start 48 frames into a0.ts, got %v", md(0))
+	}
+	last, _ := asMap(md(2)[cutKey])
+	if md(2)[outOffsetKey] != 1.0 || last["out_frames"] != int64(24) {
+		t.Errorf("Expected A to end 24 frames into a2.ts, got %v", md(2))
+	}
+	// B covers b3.ts exactly, so it needs no offsets
+	if _, ok := md(3)[inOffsetKey]; ok {
+		t.Errorf("Expected no in offset for B, got %v", md(3))
+	}
+	if _, ok := md(3)[outOffsetKey]; ok {
+		t.Errorf("Expected no out offset for B, got %v", md(3))
+	}
+}
+
+func TestCutPinsIVs(t *testing.T) {
+	source := cutSource("a", 5)
+	source.MediaSequence = 10
+	for _, seg := range source.Segments {
+		seg.Key = &Key{Method: "AES-128", URI: "k.bin"}
+	}
+	// From 8s to 12s, the segments at media sequence 12
+	edit := cutEdit(t, cutClip{"A", "a.m3u8", 192, 96})
+	timeline, err := CutPlaylists(edit, map[string]*MediaPlaylist{"a.m3u8": source})
+	if err != nil {
+		t.Fatalf("CutPlaylists failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	p := mustUnmarshalMedia(t, buf.String())
+	if len(p.Segments) != 1 {
+		t.Fatalf("Expected one segment, got:\n%s", buf.String())
+	}
+	if key := p.Segments[0].Key; key == nil || key.IV != "0x0000000000000000000000000000000c" {
+		t.Errorf("Expected the IV of media sequence 12, got %v", key)
+	}
+}
+
+func TestCutErrors(t *testing.T) {
+	sources := map[string]*MediaPlaylist{"a.m3u8": cutSource("a", 2)}
+	if _, err := CutPlaylists(cutEdit(t, cutClip{"A", "missing.m3u8", 0, 24}), sources); err == nil {
+		t.Error("Expected error for a clip without a source")
+	}
+	if _, err := CutPlaylists(cutEdit(t, cutClip{"A", "a.m3u8", 480, 24}), sources); err == nil {
+		t.Error("Expected error for a source range past the end of the source")
+	}
+	if _, err := CutPlaylists(cutEdit(t), sources); err == nil {
+		t.Error("Expected error for an empty edit")
+	}
+}
@@ -4,178 +4,128 @@
 package hls
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
-	"strconv"
+	"net/url"
+	"path"
 	"strings"
 
-	"github.com/Avalanche-io/gotio/opentime"
 	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/gotio/opentime"
 )
 
 // Decoder reads HLS playlists and converts them to OTIO timelines
 type Decoder struct {
-	r io.Reader
+	r       io.Reader
+	limits  Limits
+	baseURL string
+	rate    float64
+	strict  bool
+
+	base *url.URL
 }
 
-// NewDecoder creates a new HLS decoder
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: r}
+// NewDecoder creates a new HLS decoder. Without options it uses
+// DefaultLimits, a 1 Hz clip rate and skips malformed tags.
+func NewDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	d := &Decoder{r: r, limits: DefaultLimits(), rate: 1}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// SetLimits replaces the limits applied to the playlist being decoded
+func (d *Decoder) SetLimits(l Limits) {
+	d.limits = l
 }
 
 // Decode reads an HLS playlist and returns an OTIO timeline
 func (d *Decoder) Decode() (*gotio.Timeline, error) {
-	entries, err := d.parsePlaylist()
+	return d.DecodeContext(context.Background())
+}
+
+// DecodeContext is like Decode but stops with the context's error once ctx
+// is cancelled. A read already blocked in the underlying reader is not
+// interrupted.
+func (d *Decoder) DecodeContext(ctx context.Context) (*gotio.Timeline, error) {
+	playlist, err := d.DecodePlaylist(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return d.Timeline(playlist)
+}
 
-	// Determine playlist type
-	if d.isMediaPlaylist(entries) {
-		return d.decodeMediaPlaylist(entries)
+// DecodePlaylist reads an HLS playlist into its typed form, applying the
+// decoder's limits and strictness, without converting it to a timeline
+func (d *Decoder) DecodePlaylist(ctx context.Context) (Playlist, error) {
+	if err := d.parseBaseURL(); err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("unsupported playlist type (master playlists not yet implemented)")
+	entries, err := readEntries(ctx, d.r, d.limits)
+	if err != nil {
+		return nil, err
+	}
+	return newParser(d.limits, d.strict).parse(ctx, entries)
 }
 
-// parsePlaylist reads and parses all entries from the playlist
-func (d *Decoder) parsePlaylist() ([]*PlaylistEntry, error) {
-	var entries []*PlaylistEntry
-	scanner := bufio.NewScanner(d.r)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		entry := ParsePlaylistEntry(line)
-		if entry != nil {
-			entries = append(entries, entry)
-		}
+// Timeline converts a typed playlist to an OTIO timeline
+func (d *Decoder) Timeline(p Playlist) (*gotio.Timeline, error) {
+	if err := d.parseBaseURL(); err != nil {
+		return nil, err
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading playlist: %w", err)
+	switch pl := p.(type) {
+	case *MediaPlaylist:
+		return d.decodeMediaPlaylist(pl), nil
+	case *MultivariantPlaylist:
+		return d.decodeMultivariantPlaylist(pl), nil
 	}
+	return nil, fmt.Errorf("unsupported playlist type %T", p)
+}
 
-	// Validate that it's an HLS playlist
-	if len(entries) == 0 || !entries[0].IsTag("EXTM3U") {
-		return nil, fmt.Errorf("not a valid M3U8 playlist")
+func (d *Decoder) parseBaseURL() error {
+	if d.baseURL == "" || d.base != nil {
+		return nil
 	}
-
-	return entries, nil
+	base, err := url.Parse(d.baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	d.base = base
+	return nil
 }
 
-// isMediaPlaylist determines if this is a media playlist (vs master playlist)
-func (d *Decoder) isMediaPlaylist(entries []*PlaylistEntry) bool {
-	for _, entry := range entries {
-		if entry.IsTag("EXTINF") {
-			return true
-		}
-		if entry.IsTag("EXT-X-STREAM-INF") {
-			return false
-		}
+// resolveURI resolves a playlist URI against the base URL, if one is set
+func (d *Decoder) resolveURI(uri string) string {
+	if d.base == nil {
+		return uri
 	}
-	return false
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return d.base.ResolveReference(ref).String()
 }
 
 // decodeMediaPlaylist converts a media playlist to an OTIO timeline
-func (d *Decoder) decodeMediaPlaylist(entries []*PlaylistEntry) (*gotio.Timeline, error) {
+func (d *Decoder) decodeMediaPlaylist(p *MediaPlaylist) *gotio.Timeline {
 	// Create timeline and track
 	timeline := gotio.NewTimeline("HLS Playlist", nil, nil)
 	track := gotio.NewTrack("", nil, gotio.TrackKindVideo, nil, nil)
 
 	// Track metadata
 	trackMetadata := make(gotio.AnyDictionary)
-	hlsMetadata := make(map[string]interface{})
-
-	// State for building clips
-	var (
-		currentDuration     float64
-		currentTitle        string
-		currentByterange    *Byterange
-		currentKey          string
-		currentProgramDateTime string
-		mapURI              string
-		mapByterange        *Byterange
-		lastByterangeEnd    int64
-		discontinuityCount  int
-	)
-
-	for i := 0; i < len(entries); i++ {
-		entry := entries[i]
-
-		switch {
-		case entry.IsTag("EXT-X-VERSION"):
-			version, _ := strconv.Atoi(strings.TrimSpace(entry.Value))
-			hlsMetadata["version"] = version
-
-		case entry.IsTag("EXT-X-TARGETDURATION"):
-			duration, _ := strconv.Atoi(strings.TrimSpace(entry.Value))
-			hlsMetadata["target_duration"] = duration
-
-		case entry.IsTag("EXT-X-MEDIA-SEQUENCE"):
-			seq, _ := strconv.Atoi(strings.TrimSpace(entry.Value))
-			hlsMetadata["media_sequence"] = seq
-
-		case entry.IsTag("EXT-X-PLAYLIST-TYPE"):
-			hlsMetadata["playlist_type"] = strings.TrimSpace(entry.Value)
-
-		case entry.IsTag("EXT-X-MAP"):
-			// Parse MAP tag for initialization data
-			attrs := ParseAttributeList(entry.Value)
-			mapURI = attrs.Get("URI")
-			if byterangeStr := attrs.Get("BYTERANGE"); byterangeStr != "" {
-				mapByterange, _ = NewByterangeFromString(byterangeStr)
-			}
-
-		case entry.IsTag("EXTINF"):
-			// Parse duration and optional title
-			parts := strings.SplitN(entry.Value, ",", 2)
-			if len(parts) > 0 {
-				currentDuration, _ = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
-			}
-			if len(parts) > 1 {
-				currentTitle = strings.TrimSpace(parts[1])
-			}
-
-		case entry.IsTag("EXT-X-BYTERANGE"):
-			// Parse byterange for next segment
-			br, err := NewByterangeFromString(strings.TrimSpace(entry.Value))
-			if err == nil {
-				currentByterange = br
-				// If offset not specified, use last segment's end
-				if currentByterange.Offset == 0 && lastByterangeEnd > 0 {
-					currentByterange.Offset = lastByterangeEnd
-				}
-			}
-
-		case entry.IsTag("EXT-X-KEY"):
-			// Store encryption key info for subsequent segments
-			currentKey = entry.Value
-
-		case entry.IsTag("EXT-X-PROGRAM-DATE-TIME"):
-			// Store program date time for next segment
-			currentProgramDateTime = strings.TrimSpace(entry.Value)
-
-		case entry.IsTag("EXT-X-DISCONTINUITY"):
-			// Increment discontinuity counter
-			discontinuityCount++
+	hlsMetadata := mediaPlaylistMetadata(p)
 
-		case entry.Type == EntryTypeURI:
-			// Create a clip for this segment
-			clip := d.createClip(entry.URI, currentDuration, currentTitle, currentByterange, mapURI, mapByterange, currentKey, currentProgramDateTime, discontinuityCount)
-			track.AppendChild(clip)
-
-			// Update state
-			if currentByterange != nil {
-				lastByterangeEnd = currentByterange.Offset + currentByterange.Count
-			}
-
-			// Reset per-segment state (not persistent state like currentKey)
-			currentDuration = 0
-			currentTitle = ""
-			currentByterange = nil
-			currentProgramDateTime = ""
+	discontinuitySeq := p.DiscontinuitySequence
+	for _, seg := range p.Segments {
+		if seg.Discontinuity {
+			discontinuitySeq++
 		}
+		track.AppendChild(d.createClip(seg, discontinuitySeq))
 	}
 
 	// Add HLS metadata to track
@@ -185,63 +135,100 @@ func (d *Decoder) decodeMediaPlaylist(entries []*PlaylistEntry) (*gotio.Timeline
 	// Add track to timeline
 	timeline.Tracks().AppendChild(track)
 
-	return timeline, nil
+	return timeline
+}
+
+// mediaPlaylistMetadata builds the track HLS metadata for a media playlist
+func mediaPlaylistMetadata(p *MediaPlaylist) map[string]interface{} {
+	hlsMetadata := make(map[string]interface{})
+	if p.Version > 0 {
+		hlsMetadata["version"] = p.Version
+	}
+	if p.TargetDuration > 0 {
+		hlsMetadata["target_duration"] = p.TargetDuration
+	}
+	hlsMetadata["media_sequence"] = p.MediaSequence
+	if p.DiscontinuitySequence > 0 {
+		hlsMetadata["discontinuity_sequence"] = p.DiscontinuitySequence
+	}
+	if p.PlaylistType != "" {
+		hlsMetadata["playlist_type"] = string(p.PlaylistType)
+	}
+	if p.IndependentSegments {
+		hlsMetadata["independent_segments"] = true
+	}
+	if p.IFramesOnly {
+		hlsMetadata["iframes_only"] = true
+	}
+	hlsMetadata["end_list"] = p.EndList
+	if p.PartTarget > 0 {
+		hlsMetadata["part_target"] = p.PartTarget
+	}
+	if p.Start != nil {
+		hlsMetadata["EXT-X-START"] = startString(p.Start)
+	}
+	if len(p.Defines) > 0 {
+		hlsMetadata["EXT-X-DEFINE"] = stringList(defineStrings(p.Defines))
+	}
+	if len(p.Parts) > 0 {
+		hlsMetadata["EXT-X-PART"] = stringList(partStrings(p.Parts))
+	}
+	if len(p.Tags) > 0 {
+		hlsMetadata["tags"] = stringList(tagStrings(p.Tags))
+	}
+	return hlsMetadata
 }
 
-// createClip creates an OTIO clip from HLS segment information
-func (d *Decoder) createClip(uri string, duration float64, title string, byterange *Byterange, mapURI string, mapByterange *Byterange, keyInfo string, programDateTime string, discontinuitySeq int) *gotio.Clip {
+// createClip creates an OTIO clip from an HLS segment
+func (d *Decoder) createClip(seg *Segment, discontinuitySeq int) *gotio.Clip {
 	// Use title as clip name, or URI if no title
-	name := title
+	name := seg.Title
 	if name == "" {
-		name = uri
+		name = seg.URI
 	}
 
-	// Create time range - use 1Hz rate for simplicity (Python uses seconds directly)
+	// Create time range - the default 1Hz rate matches Python's
+	// TimeRange(RationalTime(0, 1), RationalTime(duration, 1))
 	var sourceRange *opentime.TimeRange
-	if duration > 0 {
-		rate := 1.0 // Use 1Hz to match Python's TimeRange(RationalTime(0, 1), RationalTime(duration, 1))
-		durationTime := opentime.NewRationalTime(duration*rate, rate)
+	if seg.Duration > 0 {
+		rate := d.rate
+		durationTime := opentime.NewRationalTime(seg.Duration*rate, rate)
 		startTime := opentime.NewRationalTime(0, rate)
 		tr := opentime.NewTimeRange(startTime, durationTime)
 		sourceRange = &tr
 	}
 
 	// Create external reference
-	ref := gotio.NewExternalReference("", uri, nil, nil)
+	ref := gotio.NewExternalReference("", d.resolveURI(seg.URI), nil, nil)
 
 	// Build clip metadata
 	metadata := make(gotio.AnyDictionary)
 	hlsClipMetadata := make(map[string]interface{})
 	streamingMetadata := make(map[string]interface{})
 
-	if byterange != nil {
-		streamingMetadata["byte_count"] = byterange.Count
-		streamingMetadata["byte_offset"] = byterange.Offset
+	if seg.Byterange != nil {
+		streamingMetadata["byte_count"] = seg.Byterange.Count
+		streamingMetadata["byte_offset"] = seg.Byterange.Offset
 	}
 
-	if mapURI != "" {
-		mapData := map[string]interface{}{
-			"init_uri": mapURI,
-		}
-		if mapByterange != nil {
-			mapData["init_byterange"] = map[string]interface{}{
-				"byte_count":  mapByterange.Count,
-				"byte_offset": mapByterange.Offset,
+	if seg.Map != nil {
+		streamingMetadata["init_uri"] = d.resolveURI(seg.Map.URI)
+		if seg.Map.Byterange != nil {
+			streamingMetadata["init_byterange"] = map[string]interface{}{
+				"byte_count":  seg.Map.Byterange.Count,
+				"byte_offset": seg.Map.Byterange.Offset,
 			}
 		}
-		for k, v := range mapData {
-			streamingMetadata[k] = v
-		}
 	}
 
 	// Add encryption key info if present
-	if keyInfo != "" {
-		hlsClipMetadata["EXT-X-KEY"] = keyInfo
+	if seg.Key != nil {
+		hlsClipMetadata["EXT-X-KEY"] = seg.Key.String()
 	}
 
 	// Add program date time if present
-	if programDateTime != "" {
-		hlsClipMetadata["EXT-X-PROGRAM-DATE-TIME"] = programDateTime
+	if !seg.ProgramDateTime.IsZero() {
+		hlsClipMetadata["EXT-X-PROGRAM-DATE-TIME"] = formatDateTime(seg.ProgramDateTime)
 	}
 
 	// Add discontinuity sequence if non-zero
@@ -249,22 +236,33 @@ func (d *Decoder) createClip(uri string, duration float64, title string, byteran
 		hlsClipMetadata["discontinuity_sequence"] = discontinuitySeq
 	}
 
-	if len(hlsClipMetadata) > 0 {
-		metadata[metadataNamespace] = hlsClipMetadata
+	if len(seg.DateRanges) > 0 {
+		ranges := make([]string, len(seg.DateRanges))
+		for i, dr := range seg.DateRanges {
+			ranges[i] = dr.String()
+		}
+		hlsClipMetadata["EXT-X-DATERANGE"] = stringList(ranges)
 	}
-
-	if len(streamingMetadata) > 0 {
-		metadata[streamingMetadataNamespace] = streamingMetadata
+	if seg.Gap {
+		hlsClipMetadata["gap"] = true
+	}
+	if seg.Bitrate > 0 {
+		hlsClipMetadata["bitrate"] = seg.Bitrate
+	}
+	if len(seg.Parts) > 0 {
+		hlsClipMetadata["EXT-X-PART"] = stringList(partStrings(seg.Parts))
 	}
+	if len(seg.Tags) > 0 {
+		hlsClipMetadata["tags"] = stringList(tagStrings(seg.Tags))
+	}
+
+	setNamespace(metadata, metadataNamespace, hlsClipMetadata)
+	setNamespace(metadata, streamingMetadataNamespace, streamingMetadata)
 
 	// Create clip with metadata on the reference
 	refMetadata := make(gotio.AnyDictionary)
-	if len(hlsClipMetadata) > 0 {
-		refMetadata[metadataNamespace] = hlsClipMetadata
-	}
-	if len(streamingMetadata) > 0 {
-		refMetadata[streamingMetadataNamespace] = streamingMetadata
-	}
+	setNamespace(refMetadata, metadataNamespace, hlsClipMetadata)
+	setNamespace(refMetadata, streamingMetadataNamespace, streamingMetadata)
 	ref.SetMetadata(refMetadata)
 
 	// Create clip
@@ -272,3 +270,263 @@ func (d *Decoder) createClip(uri string, duration float64, title string, byteran
 
 	return clip
 }
+
+// decodeMultivariantPlaylist converts a multivariant playlist to a timeline
+// with one video track per variant and one audio track per audio rendition
+func (d *Decoder) decodeMultivariantPlaylist(p *MultivariantPlaylist) *gotio.Timeline {
+	timeline := gotio.NewTimeline("HLS Playlist", nil, nil)
+
+	timelineHLS := map[string]interface{}{
+		"master_playlist": true,
+	}
+	if p.Version > 0 {
+		timelineHLS["version"] = p.Version
+	}
+	if p.IndependentSegments {
+		timelineHLS["independent_segments"] = true
+	}
+	if p.Start != nil {
+		timelineHLS["EXT-X-START"] = startString(p.Start)
+	}
+	if len(p.Defines) > 0 {
+		timelineHLS["EXT-X-DEFINE"] = stringList(defineStrings(p.Defines))
+	}
+
+	tags := tagStrings(p.Tags)
+
+	// Audio, subtitle and closed-caption renditions become tracks, named
+	// uniquely so variants can link to them by name
+	audioNames := make(map[*Rendition]string)
+	usedNames := make(map[string]bool)
+	var renditionTracks []*gotio.Track
+	for _, r := range p.Renditions {
+		if r.Type == MediaTypeVideo {
+			tags = append(tags, "#EXT-X-MEDIA:"+renditionString(r))
+			continue
+		}
+		name := r.Name
+		if usedNames[name] {
+			name = fmt.Sprintf("%s (%s)", r.Name, r.GroupID)
+		}
+		usedNames[name] = true
+		if r.Type == MediaTypeAudio {
+			audioNames[r] = name
+		}
+		renditionTracks = append(renditionTracks, d.renditionTrack(r, name))
+	}
+
+	if len(tags) > 0 {
+		timelineHLS["tags"] = stringList(tags)
+	}
+	timelineMD := make(gotio.AnyDictionary)
+	timelineMD[metadataNamespace] = timelineHLS
+	timeline.SetMetadata(timelineMD)
+
+	// Variants become video tracks
+	var videoTracks []*gotio.Track
+	for _, v := range p.Variants {
+		md := make(gotio.AnyDictionary)
+		streamingMD := variantStreamingMetadata(v)
+		hlsMD := map[string]interface{}{
+			"uri": d.resolveURI(v.URI),
+		}
+		if v.Video != "" {
+			hlsMD["video"] = v.Video
+		}
+		if v.Subtitles != "" {
+			hlsMD["subtitles"] = v.Subtitles
+		}
+		if v.ClosedCaptions != "" {
+			hlsMD["closed_captions"] = v.ClosedCaptions
+		}
+		if len(v.Attrs) > 0 {
+			hlsMD["attributes"] = attributesString(v.Attrs)
+		}
+
+		var linked []string
+		for _, r := range p.Group(MediaTypeAudio, v.Audio) {
+			linked = append(linked, audioNames[r])
+		}
+		if len(linked) > 0 {
+			md["linked_tracks"] = stringList(linked)
+		}
+
+		setNamespace(md, streamingMetadataNamespace, streamingMD)
+		setNamespace(md, metadataNamespace, hlsMD)
+		name := strings.TrimSuffix(v.URI, path.Ext(v.URI))
+		videoTracks = append(videoTracks, gotio.NewTrack(name, nil, gotio.TrackKindVideo, md, nil))
+	}
+
+	// I-frame variants are attached to the variant of the same resolution
+	for i, v := range p.IFrameVariants {
+		track := matchIFrameVariant(p.Variants, videoTracks, v, i)
+		if track == nil {
+			continue
+		}
+		md := track.Metadata()
+		hlsMD, _ := asMap(md[metadataNamespace])
+		hlsMD["iframe_uri"] = d.resolveURI(v.URI)
+		if v.Bandwidth > 0 {
+			hlsMD["iframe_bandwidth"] = v.Bandwidth
+		}
+		if v.Codecs != "" {
+			hlsMD["iframe_codec"] = v.Codecs
+		}
+	}
+
+	for _, track := range videoTracks {
+		timeline.Tracks().AppendChild(track)
+	}
+	for _, track := range renditionTracks {
+		timeline.Tracks().AppendChild(track)
+	}
+
+	return timeline
+}
+
+// renditionTrack converts an EXT-X-MEDIA rendition to a track without
+// clips. Subtitle and closed-caption tracks are video tracks marked by
+// their media_type metadata.
+func (d *Decoder) renditionTrack(r *Rendition, name string) *gotio.Track {
+	streamingMD := map[string]interface{}{
+		"group_id": r.GroupID,
+	}
+	setString := func(key, value string) {
+		if value != "" {
+			streamingMD[key] = value
+		}
+	}
+	setFlag := func(key string, value bool) {
+		if value {
+			streamingMD[key] = true
+		}
+	}
+	setFlag("default", r.Default)
+	setFlag("autoselect", r.Autoselect)
+	setFlag("forced", r.Forced)
+	setString("language", r.Language)
+	setString("assoc_language", r.AssocLanguage)
+	setString("stable_rendition_id", r.StableRenditionID)
+	setString("instream_id", r.InstreamID)
+	setString("characteristics", r.Characteristics)
+	setString("channels", r.Channels)
+	if r.BitDepth > 0 {
+		streamingMD["bit_depth"] = r.BitDepth
+	}
+	if r.SampleRate > 0 {
+		streamingMD["sample_rate"] = r.SampleRate
+	}
+
+	hlsMD := make(map[string]interface{})
+	if r.URI != "" {
+		hlsMD["uri"] = d.resolveURI(r.URI)
+	} else if r.Type == MediaTypeAudio {
+		// Audio without a URI is muxed into the variant streams
+		streamingMD["muxed"] = true
+	}
+	// Keep the NAME of renditions renamed to be unique
+	if name != r.Name {
+		hlsMD["name"] = r.Name
+	}
+	if len(r.Attrs) > 0 {
+		hlsMD["attributes"] = attributesString(r.Attrs)
+	}
+	kind := gotio.TrackKindAudio
+	if r.Type != MediaTypeAudio {
+		kind = gotio.TrackKindVideo
+		hlsMD["media_type"] = string(r.Type)
+	}
+
+	md := make(gotio.AnyDictionary)
+	setNamespace(md, streamingMetadataNamespace, streamingMD)
+	setNamespace(md, metadataNamespace, hlsMD)
+	return gotio.NewTrack(name, nil, kind, md, nil)
+}
+
+// variantStreamingMetadata maps variant attributes to streaming metadata
+func variantStreamingMetadata(v *Variant) map[string]interface{} {
+	streamingMD := make(map[string]interface{})
+	if v.Bandwidth > 0 {
+		streamingMD["bandwidth"] = v.Bandwidth
+	}
+	if v.AverageBandwidth > 0 {
+		streamingMD["average_bandwidth"] = v.AverageBandwidth
+	}
+	if v.Codecs != "" {
+		streamingMD["codec"] = v.Codecs
+	}
+	if v.Resolution != nil {
+		streamingMD["width"] = v.Resolution.Width
+		streamingMD["height"] = v.Resolution.Height
+	}
+	if v.FrameRate > 0 {
+		streamingMD["frame_rate"] = v.FrameRate
+	}
+	if v.Score > 0 {
+		streamingMD["score"] = v.Score
+	}
+	for key, value := range map[string]string{
+		"hdcp_level":          v.HDCPLevel,
+		"supplemental_codecs": v.SupplementalCodecs,
+		"video_range":         string(v.VideoRange),
+		"allowed_cpc":         v.AllowedCPC,
+		"stable_variant_id":   v.StableVariantID,
+		"pathway_id":          v.PathwayID,
+		"req_video_layout":    v.ReqVideoLayout,
+	} {
+		if value != "" {
+			streamingMD[key] = value
+		}
+	}
+	return streamingMD
+}
+
+// matchIFrameVariant finds the video track an I-frame variant belongs to:
+// the first with the same resolution, or else the one at the same index
+func matchIFrameVariant(variants []*Variant, tracks []*gotio.Track, iframe *Variant, index int) *gotio.Track {
+	if iframe.Resolution != nil {
+		for i, v := range variants {
+			if v.Resolution != nil && *v.Resolution == *iframe.Resolution {
+				if _, taken := namespace(tracks[i].Metadata(), metadataNamespace)["iframe_uri"]; !taken {
+					return tracks[i]
+				}
+			}
+		}
+	}
+	if index < len(tracks) {
+		return tracks[index]
+	}
+	return nil
+}
+
+func defineStrings(defines []Define) []string {
+	var b strings.Builder
+	writeDefines(&b, defines)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "#EXT-X-DEFINE:")
+	}
+	return lines
+}
+
+func partStrings(parts []*PartialSegment) []string {
+	out := make([]string, len(parts))
+	for i, part := range parts {
+		out[i] = partialSegmentString(part)
+	}
+	return out
+}
+
+func tagStrings(tags []Tag) []string {
+	out := make([]string, len(tags))
+	for i, tag := range tags {
+		out[i] = tag.String()
+	}
+	return out
+}
+
+func attributesString(attrs AttributeList) string {
+	var a attrWriter
+	a.other(attrs)
+	return a.String()
+}
@@ -220,6 +220,24 @@ func TestParseAttributeList(t *testing.T) {
 	}
 }
 
+func TestParseAttributeListHyphenatedAndQuotedCommas(t *testing.T) {
+	input := `TYPE=AUDIO,GROUP-ID="aac",NAME="English, Main",AVERAGE-BANDWIDTH=96000,TIME-OFFSET=-12.5`
+	attrs := ParseAttributeList(input)
+
+	expected := map[string]string{
+		"TYPE":              "AUDIO",
+		"GROUP-ID":          "aac",
+		"NAME":              "English, Main",
+		"AVERAGE-BANDWIDTH": "96000",
+		"TIME-OFFSET":       "-12.5",
+	}
+	for key, want := range expected {
+		if got := attrs.Get(key); got != want {
+			t.Errorf("Expected %s '%s', got '%s'", key, want, got)
+		}
+	}
+}
+
 func TestInvalidPlaylist(t *testing.T) {
 	playlist := `This is not a valid playlist`
 
@@ -0,0 +1,375 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+// Package diff reports the semantic differences between two HLS playlists,
+// packages or the OTIO timelines they are encoded from. Playlists are
+// compared after decoding, so attribute order and float formatting do not
+// count as changes.
+package diff
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Avalanche-io/gotio"
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// Kind is the kind of a change
+type Kind string
+
+const (
+	// Added items are only in the new playlist
+	Added Kind = "added"
+	// Removed items are only in the old playlist
+	Removed Kind = "removed"
+	// Changed items have a different attribute or tag value
+	Changed Kind = "changed"
+	// Retimed segments have a different EXTINF duration
+	Retimed Kind = "retimed"
+)
+
+// Items a change is about
+const (
+	ItemPlaylist      = "playlist"
+	ItemSegment       = "segment"
+	ItemVariant       = "variant"
+	ItemIFrameVariant = "iframe-variant"
+	ItemRendition     = "rendition"
+)
+
+// tolerance is how far apart durations in seconds may be and still count
+// as equal, covering the rounding of EXTINF precision
+const tolerance = 0.0005
+
+// Change is one semantic difference between two playlists
+type Change struct {
+	Kind Kind `json:"kind"`
+	// Playlist names the media playlist of a change found in a package or
+	// timeline, by its URI or track name
+	Playlist string `json:"playlist,omitempty"`
+	// Item is what changed, one of the Item constants
+	Item string `json:"item"`
+	// ID identifies the item: the URI or media sequence number of a
+	// segment, the URI of a variant, or TYPE/GROUP-ID/NAME of a rendition
+	ID string `json:"id,omitempty"`
+	// Attribute names the tag or attribute whose value changed
+	Attribute string `json:"attribute,omitempty"`
+	Old       string `json:"old,omitempty"`
+	New       string `json:"new,omitempty"`
+	// Delta is New minus Old for bandwidths and durations
+	Delta float64 `json:"delta,omitempty"`
+}
+
+// String formats the change as one line, e.g.
+// "retimed segment seg1.ts EXTINF: 6.006 -> 5.5 (-0.506)"
+func (c Change) String() string {
+	var b strings.Builder
+	b.WriteString(string(c.Kind))
+	if c.Playlist != "" {
+		fmt.Fprintf(&b, " %s:", c.Playlist)
+	}
+	fmt.Fprintf(&b, " %s", c.Item)
+	if c.ID != "" {
+		fmt.Fprintf(&b, " %s", c.ID)
+	}
+	if c.Attribute != "" {
+		fmt.Fprintf(&b, " %s", c.Attribute)
+	}
+	switch {
+	case c.Kind == Changed || c.Kind == Retimed:
+		fmt.Fprintf(&b, ": %s -> %s", orNone(c.Old), orNone(c.New))
+	case c.Old != "":
+		fmt.Fprintf(&b, ": %s", c.Old)
+	case c.New != "":
+		fmt.Fprintf(&b, ": %s", c.New)
+	}
+	if c.Delta != 0 {
+		fmt.Fprintf(&b, " (%+g)", c.Delta)
+	}
+	return b.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// Result is the changes from an old to a new playlist in playlist order
+type Result struct {
+	Changes []Change `json:"changes"`
+}
+
+// Equal reports whether no changes were found
+func (r *Result) Equal() bool {
+	return len(r.Changes) == 0
+}
+
+func (r *Result) add(c Change) {
+	r.Changes = append(r.Changes, c)
+}
+
+// attr records a changed attribute of an item when the values differ
+func (r *Result) attr(item, id, name, old, new string) {
+	if old != new {
+		r.add(Change{Kind: Changed, Item: item, ID: id, Attribute: name, Old: old, New: new})
+	}
+}
+
+// number records a changed numeric attribute with its delta
+func (r *Result) number(kind Kind, item, id, name string, old, new float64) {
+	if math.Abs(new-old) > tolerance {
+		r.add(Change{Kind: kind, Item: item, ID: id, Attribute: name,
+			Old: formatFloat(old), New: formatFloat(new), Delta: round(new - old)})
+	}
+}
+
+// date records a changed date-time, comparing the instants
+func (r *Result) date(item, id, name string, old, new time.Time) {
+	if !old.Equal(new) {
+		r.add(Change{Kind: Changed, Item: item, ID: id, Attribute: name, Old: dateString(old), New: dateString(new)})
+	}
+}
+
+// Playlists compares an old and a new playlist. Playlists of different
+// types differ in their whole.
+func Playlists(old, new hls.Playlist) *Result {
+	r := &Result{}
+	switch o := old.(type) {
+	case *hls.MediaPlaylist:
+		if n, ok := new.(*hls.MediaPlaylist); ok {
+			diffMedia(r, o, n)
+			return r
+		}
+	case *hls.MultivariantPlaylist:
+		if n, ok := new.(*hls.MultivariantPlaylist); ok {
+			diffMultivariant(r, o, n)
+			return r
+		}
+	}
+	r.attr(ItemPlaylist, "", "type", playlistType(old), playlistType(new))
+	return r
+}
+
+func playlistType(p hls.Playlist) string {
+	switch p.(type) {
+	case *hls.MediaPlaylist:
+		return "media"
+	case *hls.MultivariantPlaylist:
+		return "multivariant"
+	}
+	return ""
+}
+
+// Bytes parses and compares two M3U8 playlists
+func Bytes(old, new []byte) (*Result, error) {
+	o, err := hls.Unmarshal(old)
+	if err != nil {
+		return nil, fmt.Errorf("old playlist: %w", err)
+	}
+	n, err := hls.Unmarshal(new)
+	if err != nil {
+		return nil, fmt.Errorf("new playlist: %w", err)
+	}
+	return Playlists(o, n), nil
+}
+
+// Timelines compares the playlists an encoder with opts writes for two
+// timelines. For multivariant playlists the media playlists of tracks of
+// the same name are compared as well.
+func Timelines(old, new *gotio.Timeline, opts ...hls.EncoderOption) (*Result, error) {
+	enc := hls.NewEncoder(nil, opts...)
+	o, err := enc.Playlist(old)
+	if err != nil {
+		return nil, fmt.Errorf("old timeline: %w", err)
+	}
+	n, err := enc.Playlist(new)
+	if err != nil {
+		return nil, fmt.Errorf("new timeline: %w", err)
+	}
+	r := Playlists(o, n)
+	_, oldMaster := o.(*hls.MultivariantPlaylist)
+	_, newMaster := n.(*hls.MultivariantPlaylist)
+	if !oldMaster || !newMaster {
+		return r, nil
+	}
+
+	oldTracks := make(map[string]*gotio.Track)
+	for _, child := range old.Tracks().Children() {
+		if track, ok := child.(*gotio.Track); ok && len(track.Children()) > 0 {
+			oldTracks[track.Name()] = track
+		}
+	}
+	for _, child := range new.Tracks().Children() {
+		track, ok := child.(*gotio.Track)
+		if !ok || len(track.Children()) == 0 || oldTracks[track.Name()] == nil {
+			continue
+		}
+		om, err := enc.MediaPlaylist(oldTracks[track.Name()])
+		if err != nil {
+			return nil, fmt.Errorf("old track %q: %w", track.Name(), err)
+		}
+		nm, err := enc.MediaPlaylist(track)
+		if err != nil {
+			return nil, fmt.Errorf("new track %q: %w", track.Name(), err)
+		}
+		r.nest(track.Name(), Playlists(om, nm))
+	}
+	return r, nil
+}
+
+// Packages compares two multivariant playlists read from old and new, and
+// the media playlists with the same relative URI in both. Other playlists
+// are compared on their own. Playlists are decoded with the limits of
+// opts.
+func Packages(old fs.FS, oldName string, new fs.FS, newName string, opts ...hls.DecoderOption) (*Result, error) {
+	ctx := context.Background()
+	oldResolver, newResolver := hls.NewResolver(old, opts...), hls.NewResolver(new, opts...)
+	o, err := oldResolver.Playlist(ctx, oldName)
+	if err != nil {
+		return nil, err
+	}
+	n, err := newResolver.Playlist(ctx, newName)
+	if err != nil {
+		return nil, err
+	}
+	r := Playlists(o, n)
+	om, ok1 := o.(*hls.MultivariantPlaylist)
+	nm, ok2 := n.(*hls.MultivariantPlaylist)
+	if !ok1 || !ok2 {
+		return r, nil
+	}
+
+	oldURIs := make(map[string]bool)
+	for _, uri := range mediaURIs(om) {
+		oldURIs[uri] = true
+	}
+	for _, uri := range mediaURIs(nm) {
+		if !oldURIs[uri] {
+			continue
+		}
+		op, _, err := oldResolver.Reference(ctx, oldName, uri)
+		if err != nil {
+			return nil, err
+		}
+		np, _, err := newResolver.Reference(ctx, newName, uri)
+		if err != nil {
+			return nil, err
+		}
+		r.nest(uri, Playlists(op, np))
+	}
+	return r, nil
+}
+
+// mediaURIs lists the relative URIs of the media playlists of a
+// multivariant playlist, each once
+func mediaURIs(p *hls.MultivariantPlaylist) []string {
+	var uris []string
+	seen := make(map[string]bool)
+	add := func(uri string) {
+		if u, err := url.Parse(uri); uri == "" || seen[uri] || err != nil || u.Scheme != "" || u.Host != "" {
+			return
+		}
+		seen[uri] = true
+		uris = append(uris, uri)
+	}
+	for _, v := range p.Variants {
+		add(v.URI)
+	}
+	for _, v := range p.IFrameVariants {
+		add(v.URI)
+	}
+	for _, rd := range p.Renditions {
+		add(rd.URI)
+	}
+	return uris
+}
+
+// nest adds the changes of a media playlist
+func (r *Result) nest(playlist string, media *Result) {
+	for _, c := range media.Changes {
+		c.Playlist = playlist
+		r.add(c)
+	}
+}
+
+// tags compares the unmodelled tags of an item as multisets. Tags whose
+// values are attribute lists compare equal in any attribute order.
+func (r *Result) tags(item, id string, old, new []hls.Tag) {
+	used := make([]bool, len(new))
+	for _, t := range old {
+		found := false
+		for i, u := range new {
+			if !used[i] && sameTag(t, u) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			r.add(Change{Kind: Removed, Item: item, ID: id, Attribute: tagName(t), Old: t.String()})
+		}
+	}
+	for i, u := range new {
+		if !used[i] {
+			r.add(Change{Kind: Added, Item: item, ID: id, Attribute: tagName(u), New: u.String()})
+		}
+	}
+}
+
+func tagName(t hls.Tag) string {
+	if t.Name == "" {
+		return "comment"
+	}
+	return t.Name
+}
+
+func sameTag(a, b hls.Tag) bool {
+	if a.Name != b.Name {
+		return false
+	}
+	if a.Value == b.Value {
+		return true
+	}
+	aa, ba := hls.ParseAttributeList(a.Value), hls.ParseAttributeList(b.Value)
+	if len(aa) == 0 || len(aa) != len(ba) {
+		return false
+	}
+	for k, v := range aa {
+		if bv, ok := ba[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// formatFloat writes the shortest form of a float
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// round drops the float noise of a subtraction
+func round(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+func yes(b bool) string {
+	if b {
+		return "YES"
+	}
+	return ""
+}
+
+func itoa(v int64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
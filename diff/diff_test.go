@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package diff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const oldMedia = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:6
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=AES-128,URI="key1.bin",IV=0x1
+#EXTINF:6.006000,
+seg0.m4s
+#EXTINF:6.006000,
+seg1.m4s
+#EXTINF:6.006000,
+seg2.m4s
+#EXTINF:2.000000,
+seg3.m4s
+#EXT-X-ENDLIST
+`
+
+// newMedia formats durations differently, orders the key attributes
+// differently, retimes seg1, rotates the key from seg2, drops seg3 and adds
+// seg4
+const newMedia = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:6
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:IV=0x1,URI="key1.bin",METHOD=AES-128
+#EXTINF:6.006,
+seg0.m4s
+#EXTINF:5.5,
+seg1.m4s
+#EXT-X-KEY:METHOD=AES-128,URI="key2.bin",IV=0x1
+#EXTINF:6.006,
+seg2.m4s
+#EXTINF:4,
+seg4.m4s
+#EXT-X-ENDLIST
+#EXT-X-CUSTOM:A=1
+`
+
+func changes(r *Result) []string {
+	var out []string
+	for _, c := range r.Changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestMedia(t *testing.T) {
+	r, err := Bytes([]byte(oldMedia), []byte(newMedia))
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	want := []string{
+		"added playlist EXT-X-CUSTOM: #EXT-X-CUSTOM:A=1",
+		"retimed segment seg1.m4s EXTINF: 6.006 -> 5.5 (-0.506)",
+		`changed segment seg2.m4s EXT-X-KEY: METHOD=AES-128,URI="key1.bin",IV=0x1 -> METHOD=AES-128,URI="key2.bin",IV=0x1`,
+		"added segment seg4.m4s EXTINF: 4",
+		"removed segment seg3.m4s EXTINF: 2",
+	}
+	if got := changes(r); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	if r, _ := Bytes([]byte(oldMedia), []byte(oldMedia)); !r.Equal() {
+		t.Errorf("Expected no changes, got %v", changes(r))
+	}
+}
+
+func TestSequenceAlignment(t *testing.T) {
+	// Byte ranges of one file are matched by media sequence number
+	r, err := Bytes([]byte(`#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:10
+#EXTINF:4,
+#EXT-X-BYTERANGE:100@0
+main.ts
+#EXTINF:4,
+#EXT-X-BYTERANGE:100@100
+main.ts
+`), []byte(`#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:11
+#EXTINF:4,
+#EXT-X-BYTERANGE:120@100
+main.ts
+#EXTINF:4,
+#EXT-X-BYTERANGE:100@220
+main.ts
+`))
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	want := []string{
+		"changed playlist EXT-X-MEDIA-SEQUENCE: 10 -> 11",
+		"removed segment 10 URI: main.ts",
+		"changed segment 11 EXT-X-BYTERANGE: 100@100 -> 120@100",
+		"added segment 12 URI: main.ts",
+	}
+	if got := changes(r); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestDates(t *testing.T) {
+	// The date-time moves by a microsecond and a range with the same ID
+	// is given again at a new START-DATE
+	r, err := Bytes([]byte(`#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000100Z
+#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:00.000Z",DURATION=4
+#EXTINF:4,
+a.ts
+`), []byte(`#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000101Z
+#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:00.000Z",DURATION=4
+#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:02.000Z",DURATION=2
+#EXTINF:4,
+a.ts
+`))
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	want := []string{
+		"changed segment a.ts EXT-X-PROGRAM-DATE-TIME: 2024-01-01T00:00:00.0001Z -> 2024-01-01T00:00:00.000101Z",
+		`added segment a.ts EXT-X-DATERANGE: ID="ad",START-DATE="2024-01-01T00:00:02.000Z",DURATION=2`,
+	}
+	if got := changes(r); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestPackages(t *testing.T) {
+	old := fstest.MapFS{
+		"master.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+v720/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=800000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+v360/index.m3u8
+`)},
+		"v720/index.m3u8": {Data: []byte(oldMedia)},
+	}
+	new := fstest.MapFS{
+		"master.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-STREAM-INF:RESOLUTION=1280x720,BANDWIDTH=2200000,CODECS="avc1.64001f,mp4a.40.2"
+v720/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=4500000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080
+v1080/index.m3u8
+`)},
+		"v720/index.m3u8": {Data: []byte(strings.Replace(oldMedia, "seg3.m4s", "seg3b.m4s", 1))},
+	}
+	r, err := Packages(old, "master.m3u8", new, "master.m3u8")
+	if err != nil {
+		t.Fatalf("Packages failed: %v", err)
+	}
+	want := []string{
+		"changed variant v720/index.m3u8 BANDWIDTH: 2000000 -> 2200000 (+200000)",
+		"added variant v1080/index.m3u8 BANDWIDTH: 4500000",
+		"removed variant v360/index.m3u8 BANDWIDTH: 800000",
+		"added v720/index.m3u8: segment seg3b.m4s EXTINF: 2",
+		"removed v720/index.m3u8: segment seg3.m4s EXTINF: 2",
+	}
+	if got := changes(r); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	data, err := json.Marshal(r.Changes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"kind":"changed","item":"variant","id":"v720/index.m3u8","attribute":"BANDWIDTH","old":"2000000","new":"2200000","delta":200000}`; string(data) != want {
+		t.Errorf("Expected %s, got %s", want, data)
+	}
+}
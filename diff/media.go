@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package diff
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+func diffMedia(r *Result, old, new *hls.MediaPlaylist) {
+	r.attr(ItemPlaylist, "", "EXT-X-VERSION", itoa(int64(old.Version)), itoa(int64(new.Version)))
+	r.attr(ItemPlaylist, "", "EXT-X-TARGETDURATION", itoa(int64(old.TargetDuration)), itoa(int64(new.TargetDuration)))
+	r.attr(ItemPlaylist, "", "EXT-X-MEDIA-SEQUENCE", itoa(int64(old.MediaSequence)), itoa(int64(new.MediaSequence)))
+	r.attr(ItemPlaylist, "", "EXT-X-DISCONTINUITY-SEQUENCE",
+		itoa(int64(old.DiscontinuitySequence)), itoa(int64(new.DiscontinuitySequence)))
+	r.attr(ItemPlaylist, "", "EXT-X-PLAYLIST-TYPE", string(old.PlaylistType), string(new.PlaylistType))
+	r.attr(ItemPlaylist, "", "EXT-X-I-FRAMES-ONLY", yes(old.IFramesOnly), yes(new.IFramesOnly))
+	r.attr(ItemPlaylist, "", "EXT-X-INDEPENDENT-SEGMENTS", yes(old.IndependentSegments), yes(new.IndependentSegments))
+	r.attr(ItemPlaylist, "", "EXT-X-ENDLIST", yes(old.EndList), yes(new.EndList))
+	r.number(Changed, ItemPlaylist, "", "EXT-X-PART-INF", old.PartTarget, new.PartTarget)
+	r.attr(ItemPlaylist, "", "EXT-X-START", startString(old.Start), startString(new.Start))
+	r.attr(ItemPlaylist, "", "EXT-X-DEFINE", definesString(old.Defines), definesString(new.Defines))
+	r.tags(ItemPlaylist, "", old.Tags, new.Tags)
+
+	if alignByURI(old, new) {
+		diffSegmentsByURI(r, old, new)
+	} else {
+		diffSegmentsBySequence(r, old, new)
+	}
+}
+
+// alignByURI reports whether segments can be matched by URI: every URI is
+// unique within its playlist and the playlists share at least one
+func alignByURI(old, new *hls.MediaPlaylist) bool {
+	oldURIs, ok := uniqueURIs(old)
+	if !ok {
+		return false
+	}
+	newURIs, ok := uniqueURIs(new)
+	if !ok {
+		return false
+	}
+	for uri := range newURIs {
+		if _, ok := oldURIs[uri]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueURIs indexes the segments of a playlist by URI, or returns false
+// when a URI repeats, as it does for byte ranges of one file
+func uniqueURIs(p *hls.MediaPlaylist) (map[string]int, bool) {
+	uris := make(map[string]int, len(p.Segments))
+	for i, seg := range p.Segments {
+		if _, dup := uris[seg.URI]; dup {
+			return nil, false
+		}
+		uris[seg.URI] = i
+	}
+	return uris, true
+}
+
+// segmentDiff compares matched segments, reporting key and map changes
+// only where they start rather than for every segment they cover
+type segmentDiff struct {
+	r          *Result
+	lastKey    string
+	lastMap    string
+	bySequence bool
+}
+
+func diffSegmentsByURI(r *Result, old, new *hls.MediaPlaylist) {
+	oldURIs, _ := uniqueURIs(old)
+	newURIs, _ := uniqueURIs(new)
+	d := &segmentDiff{r: r}
+
+	next := 0
+	removeUntil := func(end int) {
+		for ; next < end; next++ {
+			if _, ok := newURIs[old.Segments[next].URI]; !ok {
+				r.add(Change{Kind: Removed, Item: ItemSegment, ID: old.Segments[next].URI,
+					Attribute: "EXTINF", Old: formatFloat(old.Segments[next].Duration)})
+			}
+		}
+	}
+	for _, seg := range new.Segments {
+		i, ok := oldURIs[seg.URI]
+		if !ok {
+			r.add(Change{Kind: Added, Item: ItemSegment, ID: seg.URI, Attribute: "EXTINF", New: formatFloat(seg.Duration)})
+			continue
+		}
+		removeUntil(i)
+		next = max(next, i+1)
+		d.compare(seg.URI, old.Segments[i], seg)
+	}
+	removeUntil(len(old.Segments))
+}
+
+func diffSegmentsBySequence(r *Result, old, new *hls.MediaPlaylist) {
+	d := &segmentDiff{r: r, bySequence: true}
+	first := min(old.MediaSequence, new.MediaSequence)
+	last := max(old.MediaSequence+len(old.Segments), new.MediaSequence+len(new.Segments))
+	for seq := first; seq < last; seq++ {
+		id := strconv.Itoa(seq)
+		o, n := seq-old.MediaSequence, seq-new.MediaSequence
+		hasOld := o >= 0 && o < len(old.Segments)
+		hasNew := n >= 0 && n < len(new.Segments)
+		switch {
+		case hasOld && hasNew:
+			d.compare(id, old.Segments[o], new.Segments[n])
+		case hasOld:
+			r.add(Change{Kind: Removed, Item: ItemSegment, ID: id, Attribute: "URI", Old: old.Segments[o].URI})
+		case hasNew:
+			r.add(Change{Kind: Added, Item: ItemSegment, ID: id, Attribute: "URI", New: new.Segments[n].URI})
+		}
+	}
+}
+
+func (d *segmentDiff) compare(id string, old, new *hls.Segment) {
+	r := d.r
+	if d.bySequence {
+		r.attr(ItemSegment, id, "URI", old.URI, new.URI)
+	}
+	r.number(Retimed, ItemSegment, id, "EXTINF", old.Duration, new.Duration)
+	r.attr(ItemSegment, id, "title", old.Title, new.Title)
+	r.attr(ItemSegment, id, "EXT-X-BYTERANGE", byterangeString(old.Byterange), byterangeString(new.Byterange))
+	r.attr(ItemSegment, id, "EXT-X-DISCONTINUITY", yes(old.Discontinuity), yes(new.Discontinuity))
+	r.attr(ItemSegment, id, "EXT-X-GAP", yes(old.Gap), yes(new.Gap))
+	r.attr(ItemSegment, id, "EXT-X-BITRATE", itoa(old.Bitrate), itoa(new.Bitrate))
+	r.date(ItemSegment, id, "EXT-X-PROGRAM-DATE-TIME", old.ProgramDateTime, new.ProgramDateTime)
+	r.attr(ItemSegment, id, "EXT-X-PART", itoa(int64(len(old.Parts))), itoa(int64(len(new.Parts))))
+
+	oldKey, newKey := keyString(old.Key), keyString(new.Key)
+	if change := oldKey + "\n" + newKey; oldKey != newKey && change != d.lastKey {
+		r.attr(ItemSegment, id, "EXT-X-KEY", oldKey, newKey)
+		d.lastKey = change
+	} else if oldKey == newKey {
+		d.lastKey = ""
+	}
+	oldMap, newMap := mapString(old.Map), mapString(new.Map)
+	if change := oldMap + "\n" + newMap; oldMap != newMap && change != d.lastMap {
+		r.attr(ItemSegment, id, "EXT-X-MAP", oldMap, newMap)
+		d.lastMap = change
+	} else if oldMap == newMap {
+		d.lastMap = ""
+	}
+
+	diffDateRanges(r, id, old.DateRanges, new.DateRanges)
+	r.tags(ItemSegment, id, old.Tags, new.Tags)
+}
+
+// diffDateRanges compares the EXT-X-DATERANGE tags of a segment by ID and
+// START-DATE, as a range may be given again with the same ID
+func diffDateRanges(r *Result, id string, old, new []*hls.DateRange) {
+	key := func(dr *hls.DateRange) string {
+		return dr.ID + "\n" + dateString(dr.StartDate)
+	}
+	oldByKey := make(map[string]*hls.DateRange, len(old))
+	for _, dr := range old {
+		oldByKey[key(dr)] = dr
+	}
+	newKeys := make(map[string]bool, len(new))
+	for _, dr := range new {
+		newKeys[key(dr)] = true
+		if o, ok := oldByKey[key(dr)]; ok {
+			r.attr(ItemSegment, id, "EXT-X-DATERANGE", o.String(), dr.String())
+		} else {
+			r.add(Change{Kind: Added, Item: ItemSegment, ID: id, Attribute: "EXT-X-DATERANGE", New: dr.String()})
+		}
+	}
+	for _, dr := range old {
+		if !newKeys[key(dr)] {
+			r.add(Change{Kind: Removed, Item: ItemSegment, ID: id, Attribute: "EXT-X-DATERANGE", Old: dr.String()})
+		}
+	}
+}
+
+func byterangeString(b *hls.Byterange) string {
+	if b == nil {
+		return ""
+	}
+	return b.String()
+}
+
+func keyString(k *hls.Key) string {
+	if k == nil || k.Method == "NONE" {
+		return ""
+	}
+	return k.String()
+}
+
+func mapString(m *hls.Map) string {
+	if m == nil {
+		return ""
+	}
+	return m.String()
+}
+
+// dateString formats a date-time in UTC as the encoder writes it, with
+// milliseconds or as many digits as it needs
+func dateString(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	t = t.UTC()
+	if t.Nanosecond()%int(time.Millisecond) != 0 {
+		return t.Format("2006-01-02T15:04:05.999999999Z07:00")
+	}
+	return t.Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+func startString(s *hls.Start) string {
+	if s == nil {
+		return ""
+	}
+	out := "TIME-OFFSET=" + formatFloat(s.TimeOffset)
+	if s.Precise {
+		out += ",PRECISE=YES"
+	}
+	return out
+}
+
+func definesString(defines []hls.Define) string {
+	var parts []string
+	for _, d := range defines {
+		switch {
+		case d.Import != "":
+			parts = append(parts, "IMPORT="+d.Import)
+		case d.QueryParam != "":
+			parts = append(parts, "QUERYPARAM="+d.QueryParam)
+		default:
+			parts = append(parts, d.Name+"="+d.Value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
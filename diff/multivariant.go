@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package diff
+
+import (
+	"sort"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+func diffMultivariant(r *Result, old, new *hls.MultivariantPlaylist) {
+	r.attr(ItemPlaylist, "", "EXT-X-VERSION", itoa(int64(old.Version)), itoa(int64(new.Version)))
+	r.attr(ItemPlaylist, "", "EXT-X-INDEPENDENT-SEGMENTS", yes(old.IndependentSegments), yes(new.IndependentSegments))
+	r.attr(ItemPlaylist, "", "EXT-X-START", startString(old.Start), startString(new.Start))
+	r.attr(ItemPlaylist, "", "EXT-X-DEFINE", definesString(old.Defines), definesString(new.Defines))
+	r.tags(ItemPlaylist, "", old.Tags, new.Tags)
+
+	diffRenditions(r, old.Renditions, new.Renditions)
+	diffVariants(r, ItemVariant, old.Variants, new.Variants)
+	diffVariants(r, ItemIFrameVariant, old.IFrameVariants, new.IFrameVariants)
+}
+
+// variantIDs names variants by URI, adding the audio group for variants
+// sharing a URI
+func variantIDs(variants []*hls.Variant) []string {
+	count := make(map[string]int)
+	for _, v := range variants {
+		count[v.URI]++
+	}
+	ids := make([]string, len(variants))
+	for i, v := range variants {
+		ids[i] = v.URI
+		if count[v.URI] > 1 {
+			ids[i] += " AUDIO=" + v.Audio
+		}
+	}
+	return ids
+}
+
+func diffVariants(r *Result, item string, old, new []*hls.Variant) {
+	oldIDs, newIDs := variantIDs(old), variantIDs(new)
+	oldByID := make(map[string]*hls.Variant, len(old))
+	for i, v := range old {
+		oldByID[oldIDs[i]] = v
+	}
+	seen := make(map[string]bool, len(new))
+	for i, v := range new {
+		id := newIDs[i]
+		seen[id] = true
+		o, ok := oldByID[id]
+		if !ok {
+			r.add(Change{Kind: Added, Item: item, ID: id, Attribute: "BANDWIDTH", New: itoa(v.Bandwidth)})
+			continue
+		}
+		r.number(Changed, item, id, "BANDWIDTH", float64(o.Bandwidth), float64(v.Bandwidth))
+		r.number(Changed, item, id, "AVERAGE-BANDWIDTH", float64(o.AverageBandwidth), float64(v.AverageBandwidth))
+		r.attr(item, id, "CODECS", o.Codecs, v.Codecs)
+		r.attr(item, id, "SUPPLEMENTAL-CODECS", o.SupplementalCodecs, v.SupplementalCodecs)
+		r.attr(item, id, "RESOLUTION", resolutionString(o.Resolution), resolutionString(v.Resolution))
+		r.number(Changed, item, id, "FRAME-RATE", o.FrameRate, v.FrameRate)
+		r.attr(item, id, "HDCP-LEVEL", o.HDCPLevel, v.HDCPLevel)
+		r.attr(item, id, "VIDEO-RANGE", string(o.VideoRange), string(v.VideoRange))
+		r.number(Changed, item, id, "SCORE", o.Score, v.Score)
+		r.attr(item, id, "AUDIO", o.Audio, v.Audio)
+		r.attr(item, id, "VIDEO", o.Video, v.Video)
+		r.attr(item, id, "SUBTITLES", o.Subtitles, v.Subtitles)
+		r.attr(item, id, "CLOSED-CAPTIONS", o.ClosedCaptions, v.ClosedCaptions)
+		r.attr(item, id, "ALLOWED-CPC", o.AllowedCPC, v.AllowedCPC)
+		r.attr(item, id, "STABLE-VARIANT-ID", o.StableVariantID, v.StableVariantID)
+		r.attr(item, id, "PATHWAY-ID", o.PathwayID, v.PathwayID)
+		r.attr(item, id, "REQ-VIDEO-LAYOUT", o.ReqVideoLayout, v.ReqVideoLayout)
+		diffAttrs(r, item, id, o.Attrs, v.Attrs)
+	}
+	for i, v := range old {
+		if id := oldIDs[i]; !seen[id] {
+			r.add(Change{Kind: Removed, Item: item, ID: id, Attribute: "BANDWIDTH", Old: itoa(v.Bandwidth)})
+		}
+	}
+}
+
+func renditionID(rd *hls.Rendition) string {
+	return string(rd.Type) + "/" + rd.GroupID + "/" + rd.Name
+}
+
+func diffRenditions(r *Result, old, new []*hls.Rendition) {
+	oldByID := make(map[string]*hls.Rendition, len(old))
+	for _, rd := range old {
+		oldByID[renditionID(rd)] = rd
+	}
+	seen := make(map[string]bool, len(new))
+	for _, rd := range new {
+		id := renditionID(rd)
+		seen[id] = true
+		o, ok := oldByID[id]
+		if !ok {
+			r.add(Change{Kind: Added, Item: ItemRendition, ID: id, Attribute: "URI", New: rd.URI})
+			continue
+		}
+		r.attr(ItemRendition, id, "URI", o.URI, rd.URI)
+		r.attr(ItemRendition, id, "LANGUAGE", o.Language, rd.Language)
+		r.attr(ItemRendition, id, "ASSOC-LANGUAGE", o.AssocLanguage, rd.AssocLanguage)
+		r.attr(ItemRendition, id, "DEFAULT", yes(o.Default), yes(rd.Default))
+		r.attr(ItemRendition, id, "AUTOSELECT", yes(o.Autoselect), yes(rd.Autoselect))
+		r.attr(ItemRendition, id, "FORCED", yes(o.Forced), yes(rd.Forced))
+		r.attr(ItemRendition, id, "INSTREAM-ID", o.InstreamID, rd.InstreamID)
+		r.attr(ItemRendition, id, "CHARACTERISTICS", o.Characteristics, rd.Characteristics)
+		r.attr(ItemRendition, id, "CHANNELS", o.Channels, rd.Channels)
+		r.attr(ItemRendition, id, "STABLE-RENDITION-ID", o.StableRenditionID, rd.StableRenditionID)
+		r.attr(ItemRendition, id, "BIT-DEPTH", itoa(int64(o.BitDepth)), itoa(int64(rd.BitDepth)))
+		r.attr(ItemRendition, id, "SAMPLE-RATE", itoa(int64(o.SampleRate)), itoa(int64(rd.SampleRate)))
+		diffAttrs(r, ItemRendition, id, o.Attrs, rd.Attrs)
+	}
+	for _, rd := range old {
+		if id := renditionID(rd); !seen[id] {
+			r.add(Change{Kind: Removed, Item: ItemRendition, ID: id, Attribute: "URI", Old: rd.URI})
+		}
+	}
+}
+
+// diffAttrs compares the attributes the typed fields do not model
+func diffAttrs(r *Result, item, id string, old, new hls.AttributeList) {
+	names := make([]string, 0, len(old)+len(new))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.attr(item, id, name, old[name], new[name])
+	}
+}
+
+func resolutionString(res *hls.Resolution) string {
+	if res == nil {
+		return ""
+	}
+	return res.String()
+}
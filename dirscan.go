@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/otio-hls/internal/isobmff"
+	"github.com/Avalanche-io/otio-hls/internal/mpegts"
+)
+
+// ErrNoSegments is returned when a directory holds no media segments
+var ErrNoSegments = errors.New("no media segments found")
+
+// segmentExtensions are the file extensions scanned when no glob is given
+var segmentExtensions = map[string]bool{
+	".ts":   true,
+	".m4s":  true,
+	".m4v":  true,
+	".m4a":  true,
+	".mp4":  true,
+	".cmfv": true,
+	".cmfa": true,
+}
+
+// ScanOption configures TimelineFromDir
+type ScanOption func(*scanner)
+
+// ScanGlob limits the files scanned to those whose names match pattern, in
+// the syntax of path.Match. The initialization segment is still found
+// among all files of the directory.
+func ScanGlob(pattern string) ScanOption {
+	return func(s *scanner) {
+		s.glob = pattern
+	}
+}
+
+type scanner struct {
+	glob string
+}
+
+// fileKind is what a scanned file holds
+type fileKind int
+
+const (
+	fileOther fileKind = iota
+	fileTS
+	fileInit
+	fileFragment
+)
+
+// scannedFile is a media segment found in the directory
+type scannedFile struct {
+	name string
+	kind fileKind
+}
+
+// TimelineFromDir builds a timeline from a directory of media segments
+// that has no playlist. Segments are taken in natural order, so
+// segment_2.ts sorts before segment_10.ts, and each is probed for its
+// duration: MPEG-TS from its PES timestamps, fragmented MP4 from its
+// sample tables. An fMP4 file with a moov box and no movie fragments is
+// used as the initialization segment.
+//
+// The timeline has one track with a clip per segment, whose URI is the
+// file name relative to dir. Encoding it writes a VOD playlist meant to
+// sit in dir, with the target duration and version computed.
+func TimelineFromDir(ctx context.Context, fsys fs.FS, dir string, opts ...ScanOption) (*gotio.Timeline, error) {
+	s := &scanner{}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return naturalLess(entries[i].Name(), entries[j].Name())
+	})
+
+	// Only the box headers are read here, so the directory's media is
+	// never all in memory at once
+	var (
+		segments []scannedFile
+		initName string
+	)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		name := entry.Name()
+		ext := strings.ToLower(path.Ext(name))
+		matched := segmentExtensions[ext]
+		if s.glob != "" {
+			matched = matchGlob(s.glob, name)
+		}
+		// Files the glob leaves out may still hold the init segment
+		if entry.IsDir() || (!matched && (ext == ".ts" || !segmentExtensions[ext])) {
+			continue
+		}
+
+		kind, err := scanFile(fsys, path.Join(dir, name))
+		if err != nil {
+			if matched {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			continue
+		}
+		switch {
+		case kind == fileInit:
+			if initName != "" {
+				return nil, fmt.Errorf("found initialization segments %s and %s", initName, name)
+			}
+			initName = name
+		case !matched:
+		case kind == fileOther:
+			return nil, fmt.Errorf("%s: not an MPEG-TS or fragmented MP4 segment", name)
+		default:
+			segments = append(segments, scannedFile{name: name, kind: kind})
+		}
+	}
+	if len(segments) == 0 {
+		return nil, ErrNoSegments
+	}
+
+	var track *isobmff.Track
+	if initName != "" {
+		data, err := fs.ReadFile(fsys, path.Join(dir, initName))
+		if err != nil {
+			return nil, err
+		}
+		if track, err = initTrack(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", initName, err)
+		}
+	}
+
+	p := &MediaPlaylist{
+		Version:      3,
+		PlaylistType: PlaylistTypeVOD,
+		EndList:      true,
+	}
+	durations := make([]uint64, len(segments))
+	timescale := uint32(ptsRate)
+	for i, f := range segments {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if f.kind == fileTS && track != nil {
+			return nil, fmt.Errorf("%s: MPEG-TS segment mixed with fMP4", f.name)
+		}
+		if f.kind == fileFragment && track == nil {
+			return nil, fmt.Errorf("%s: no initialization segment for fMP4", f.name)
+		}
+		ticks, err := segmentTicks(fsys, path.Join(dir, f.name), f.kind, track)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		durations[i] = ticks
+		seg := &Segment{URI: f.name}
+		if f.kind == fileFragment {
+			timescale = track.Timescale
+			seg.Map = &Map{URI: initName}
+			// EXT-X-MAP outside I-frame playlists requires version 6
+			p.Version = 6
+		}
+		seg.Duration = float64(durations[i]) / float64(timescale)
+		p.TargetDuration = max(p.TargetDuration, int(math.Round(seg.Duration)))
+		p.Segments = append(p.Segments, seg)
+	}
+
+	kind := gotio.TrackKindVideo
+	if track != nil && track.Handler == isobmff.HandlerAudio {
+		kind = gotio.TrackKindAudio
+	}
+	name := path.Base(dir)
+	if name == "." {
+		name = ""
+	}
+	timeline := gotio.NewTimeline(name, nil, nil)
+	timeline.Tracks().AppendChild(exactTrack(name, kind, p, durations, timescale))
+	return timeline, nil
+}
+
+// scanFile tells what a file holds from its first byte and its box
+// headers, reading all of it only when fsys cannot read at an offset
+func scanFile(fsys fs.FS, name string) (fileKind, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fileOther, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fileOther, err
+	}
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return fileOther, err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	if info.Size() >= mpegts.PacketSize {
+		var sync [1]byte
+		if _, err := r.ReadAt(sync[:], 0); err != nil {
+			return fileOther, err
+		}
+		if sync[0] == 0x47 {
+			return fileTS, nil
+		}
+	}
+	boxes, err := isobmff.ScanBoxes(r, info.Size())
+	if err != nil {
+		return fileOther, err
+	}
+	_, hasMoov := isobmff.Find(boxes, "moov")
+	_, hasMoof := isobmff.Find(boxes, "moof")
+	switch {
+	case hasMoof:
+		return fileFragment, nil
+	case hasMoov:
+		return fileInit, nil
+	}
+	return fileOther, nil
+}
+
+// segmentTicks reads a segment and returns its duration, in 90 kHz ticks
+// for MPEG-TS and in the timescale of track for fMP4
+func segmentTicks(fsys fs.FS, name string, kind fileKind, track *isobmff.Track) (uint64, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	if kind == fileTS {
+		_, ticks, err := measureTS(data, 0)
+		return uint64(ticks), err
+	}
+	fragments, err := isobmff.ParseFragments(data, 0, track)
+	if err != nil {
+		return 0, err
+	}
+	var ticks uint64
+	for _, frag := range fragments {
+		ticks += frag.Duration()
+	}
+	return ticks, nil
+}
+
+// initTrack returns the video track of an initialization section, or its
+// first track when it has no video
+func initTrack(data []byte) (*isobmff.Track, error) {
+	tracks, err := isobmff.ParseInit(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, errors.New("no tracks")
+	}
+	for _, t := range tracks {
+		if t.Handler == isobmff.HandlerVideo {
+			return t, nil
+		}
+	}
+	if tracks[0].Timescale == 0 {
+		return nil, errors.New("missing timescale")
+	}
+	return tracks[0], nil
+}
+
+func matchGlob(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// naturalLess compares names with runs of digits ordered by value
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			if len(da) != len(db) {
+				return len(da) < len(db)
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+func TestNaturalLess(t *testing.T) {
+	names := []string{"segment_10.ts", "segment_2.ts", "segment_1.ts", "init.mp4", "segment_02.ts"}
+	sort.Slice(names, func(i, j int) bool { return naturalLess(names[i], names[j]) })
+	want := []string{"init.mp4", "segment_1.ts", "segment_2.ts", "segment_02.ts", "segment_10.ts"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, names)
+		}
+	}
+}
+
+func TestTimelineFromDirTS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"vod/segment_10.ts": {Data: tsSegment(0, 50)},
+		"vod/segment_2.ts":  {Data: tsSegment(0, 100)},
+		"vod/segment_1.ts":  {Data: tsSegment(0, 150)},
+		"vod/notes.txt":     {Data: []byte("not media")},
+	}
+
+	timeline, err := TimelineFromDir(context.Background(), fsys, "vod")
+	if err != nil {
+		t.Fatalf("TimelineFromDir failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	expected := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:6.000000,
+segment_1.ts
+#EXTINF:4.000000,
+segment_2.ts
+#EXTINF:2.000000,
+segment_10.ts
+#EXT-X-ENDLIST
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected playlist:\n%s", buf.String())
+	}
+
+	// Only the matching files with a glob
+	timeline, err = TimelineFromDir(context.Background(), fsys, "vod", ScanGlob("segment_1*.ts"))
+	if err != nil {
+		t.Fatalf("TimelineFromDir with glob failed: %v", err)
+	}
+	if n := len(firstTrack(t, timeline).Children()); n != 2 {
+		t.Errorf("Expected 2 clips with glob, got %d", n)
+	}
+}
+
+func TestTimelineFromDirFMP4(t *testing.T) {
+	fsys := fstest.MapFS{
+		"init.mp4":  {Data: buildInit(90000)},
+		"seg_1.m4s": {Data: buildFragment(1, 0, gopSamples(48, 3750, 10, true))},
+		"seg_2.m4s": {Data: buildFragment(2, 48*3750, gopSamples(24, 3750, 10, true))},
+	}
+
+	timeline, err := TimelineFromDir(context.Background(), fsys, ".", ScanGlob("seg_*.m4s"))
+	if err != nil {
+		t.Fatalf("TimelineFromDir failed: %v", err)
+	}
+	track := firstTrack(t, timeline)
+	clips := track.Children()
+	if len(clips) != 2 {
+		t.Fatalf("Expected 2 clips, got %d", len(clips))
+	}
+	if d, _ := clips[0].(*gotio.Clip).Duration(); d.ToSeconds() != 2 {
+		t.Errorf("Expected 2 second segment, got %v", d.ToSeconds())
+	}
+
+	p, err := NewEncoder(nil).Playlist(timeline)
+	if err != nil {
+		t.Fatalf("Playlist failed: %v", err)
+	}
+	media := p.(*MediaPlaylist)
+	if media.Version != 6 || media.TargetDuration != 2 || media.PlaylistType != PlaylistTypeVOD {
+		t.Errorf("Unexpected header: version %d, target %d, type %s", media.Version, media.TargetDuration, media.PlaylistType)
+	}
+	if m := media.Segments[1].Map; m == nil || m.URI != "init.mp4" {
+		t.Errorf("Expected init.mp4 as the map, got %+v", m)
+	}
+}
+
+func TestTimelineFromDirErrors(t *testing.T) {
+	ctx := context.Background()
+	empty := fstest.MapFS{"readme.txt": {Data: []byte("x")}}
+	if _, err := TimelineFromDir(ctx, empty, "."); !errors.Is(err, ErrNoSegments) {
+		t.Errorf("Expected ErrNoSegments, got %v", err)
+	}
+
+	noInit := fstest.MapFS{"seg_1.m4s": {Data: buildFragment(1, 0, gopSamples(4, 100, 10, true))}}
+	if _, err := TimelineFromDir(ctx, noInit, "."); err == nil {
+		t.Error("Expected an error for fMP4 segments without an init segment")
+	}
+}
@@ -4,170 +4,358 @@
 package hls
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"maps"
+	"math"
+	"sort"
 	"strings"
 
-	"github.com/Avalanche-io/gotio/opentime"
 	"github.com/Avalanche-io/gotio"
 )
 
 // Encoder writes OTIO timelines as HLS playlists
 type Encoder struct {
-	w io.Writer
+	w         io.Writer
+	version   int
+	precision int
+	master    *bool
+
+	variantRules []VariantRule
+	normalize    bool
+	preflight    func(Playlist) error
+
+	// packaged holds the playlist locations Package chose for tracks
+	packaged map[*gotio.Track]*packagedTrack
+}
+
+// packagedTrack is where Package writes a track's playlists
+type packagedTrack struct {
+	uri             string
+	iframeURI       string
+	iframeBandwidth int64
+	// bandwidth and bitrates are measured with WithComputedBandwidth, and
+	// override the track's metadata without changing it
+	bandwidth *Bandwidth
+	bitrates  map[*gotio.Clip]int64
 }
 
-// NewEncoder creates a new HLS encoder
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w}
+// bitrate returns the measured EXT-X-BITRATE hint of a clip, if any
+func (pk *packagedTrack) bitrate(clip *gotio.Clip) (int64, bool) {
+	if pk == nil {
+		return 0, false
+	}
+	bitrate, ok := pk.bitrates[clip]
+	return bitrate, ok
+}
+
+// NewEncoder creates a new HLS encoder. Without options it writes the
+// version from the metadata, raised to the lowest one the playlist's
+// features need, and writes EXTINF durations with 6 decimals.
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	e := &Encoder{w: w, precision: 6}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // Encode writes an OTIO timeline as an HLS playlist
 func (e *Encoder) Encode(t *gotio.Timeline) error {
+	return e.EncodeContext(context.Background(), t)
+}
+
+// EncodeContext is like Encode but stops with the context's error once ctx
+// is cancelled. Nothing is written to the output after cancellation.
+func (e *Encoder) EncodeContext(ctx context.Context, t *gotio.Timeline) error {
+	playlist, err := e.playlist(ctx, t)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.EncodePlaylist(playlist)
+}
+
+// Playlist converts an OTIO timeline to the typed playlist Encode would
+// write, without writing it
+func (e *Encoder) Playlist(t *gotio.Timeline) (Playlist, error) {
+	return e.playlist(context.Background(), t)
+}
+
+// MediaPlaylist converts a single track to the media playlist Encode would
+// write for it, without writing it
+func (e *Encoder) MediaPlaylist(track *gotio.Track) (*MediaPlaylist, error) {
+	return e.encodeMediaPlaylist(context.Background(), track)
+}
+
+// EncodePlaylist writes a typed playlist using the encoder's options. With
+// WithNormalize it writes a normalized copy, leaving p unchanged.
+func (e *Encoder) EncodePlaylist(p Playlist) error {
+	p, err := e.check(p)
+	if err != nil {
+		return err
+	}
+	var output strings.Builder
+
+	switch pl := p.(type) {
+	case *MediaPlaylist:
+		if err := writeMediaPlaylist(&output, pl, e.precision); err != nil {
+			return err
+		}
+	case *MultivariantPlaylist:
+		if err := writeMultivariantPlaylist(&output, pl); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported playlist type %T", p)
+	}
+
+	// Write to output
+	_, err = e.w.Write([]byte(output.String()))
+	return err
+}
+
+// check returns the playlist to write in place of p, a normalized copy when
+// asked for, after running the preflight check, if any, on it
+func (e *Encoder) check(p Playlist) (Playlist, error) {
+	if e.normalize {
+		p = normalizedCopy(p)
+	}
+	if e.preflight == nil {
+		return p, nil
+	}
+	return p, e.preflight(p)
+}
+
+func (e *Encoder) playlist(ctx context.Context, t *gotio.Timeline) (Playlist, error) {
 	tracks := t.Tracks()
 	if tracks == nil {
-		return fmt.Errorf("timeline has no tracks")
+		return nil, fmt.Errorf("timeline has no tracks")
 	}
 
 	children := tracks.Children()
 	if len(children) == 0 {
-		return fmt.Errorf("timeline has no tracks")
+		return nil, fmt.Errorf("timeline has no tracks")
 	}
 
 	// Check if master playlist is explicitly requested
 	forceMaster := false
-	if timelineMetadata := t.Metadata(); timelineMetadata != nil {
-		if hlsData, ok := timelineMetadata[metadataNamespace]; ok {
-			if hlsMap, ok := hlsData.(map[string]interface{}); ok {
-				if master, ok := hlsMap["master_playlist"].(bool); ok {
-					forceMaster = master
-				}
-			}
-		}
+	if master, ok := e.getHLSMetadata(t)["master_playlist"].(bool); ok {
+		forceMaster = master
 	}
 
 	// Single track = media playlist (unless master is forced)
-	if len(children) == 1 && !forceMaster {
+	master := len(children) > 1 || forceMaster
+	if e.master != nil {
+		master = *e.master
+	}
+	if !master {
 		track, ok := children[0].(*gotio.Track)
 		if !ok {
-			return fmt.Errorf("expected Track, got %T", children[0])
+			return nil, fmt.Errorf("expected Track, got %T", children[0])
 		}
-		return e.encodeMediaPlaylist(track)
+		return e.encodeMediaPlaylist(ctx, track)
 	}
 
 	// Multiple tracks or forced master = master playlist
-	return e.encodeMasterPlaylist(t)
+	return e.encodeMasterPlaylist(ctx, t)
 }
 
-// encodeMediaPlaylist writes a single track as a media playlist
-func (e *Encoder) encodeMediaPlaylist(track *gotio.Track) error {
-	var output strings.Builder
-
-	// Write header
-	output.WriteString("#EXTM3U\n")
-
+// encodeMediaPlaylist converts a single track to a media playlist
+func (e *Encoder) encodeMediaPlaylist(ctx context.Context, track *gotio.Track) (*MediaPlaylist, error) {
 	// Get HLS metadata from track
 	hlsMetadata := e.getHLSMetadata(track)
 
-	// Write version
-	version := defaultHLSVersion
-	if v, ok := hlsMetadata["version"].(int); ok {
-		version = v
-	} else if v, ok := hlsMetadata["version"].(float64); ok {
-		version = int(v)
+	p := &MediaPlaylist{EndList: true}
+	p.TargetDuration = e.getIntOrDefault(hlsMetadata, "target_duration", 0)
+	p.MediaSequence = e.getIntOrDefault(hlsMetadata, "media_sequence", 0)
+	p.DiscontinuitySequence = e.getIntOrDefault(hlsMetadata, "discontinuity_sequence", 0)
+	p.PlaylistType = PlaylistType(e.getStringOrDefault(hlsMetadata, "playlist_type", ""))
+	if independent, ok := hlsMetadata["independent_segments"].(bool); ok {
+		p.IndependentSegments = independent
 	}
-	output.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", version))
-
-	// Write target duration if present
-	if td, ok := hlsMetadata["target_duration"].(int); ok {
-		output.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", td))
-	} else if td, ok := hlsMetadata["target_duration"].(float64); ok {
-		output.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(td)))
+	if endList, ok := hlsMetadata["end_list"].(bool); ok {
+		p.EndList = endList
 	}
-
-	// Write media sequence if present
-	if seq, ok := hlsMetadata["media_sequence"].(int); ok {
-		output.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", seq))
-	} else if seq, ok := hlsMetadata["media_sequence"].(float64); ok {
-		output.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", int(seq)))
+	if iframesOnly, ok := hlsMetadata["iframes_only"].(bool); ok {
+		p.IFramesOnly = iframesOnly
 	}
-
-	// Write playlist type if present
-	if pt, ok := hlsMetadata["playlist_type"].(string); ok {
-		output.WriteString(fmt.Sprintf("#EXT-X-PLAYLIST-TYPE:%s\n", pt))
+	if partTarget, ok := asFloat(hlsMetadata["part_target"]); ok {
+		p.PartTarget = partTarget
 	}
-
-	// Track the last MAP data to avoid duplicates
-	var lastMapURI string
-	var lastMapByterange string
+	if start, ok := hlsMetadata["EXT-X-START"].(string); ok {
+		p.Start, _ = parseStart(ParseAttributeList(start))
+	}
+	p.Defines = definesFromMetadata(hlsMetadata["EXT-X-DEFINE"])
+	p.Parts = partsFromMetadata(hlsMetadata["EXT-X-PART"])
+	p.Tags = tagsFromMetadata(hlsMetadata["tags"])
 
 	// Write segments
-	for _, child := range track.Children() {
+	lastSeq := p.DiscontinuitySequence
+	for i, child := range track.Children() {
+		if i%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
 		clip, ok := child.(*gotio.Clip)
 		if !ok {
 			continue
 		}
 
-		// Get clip metadata
-		clipHLSMetadata := e.getHLSMetadata(clip)
+		seg := clipSegment(clip)
+		if bitrate, ok := e.packaged[track].bitrate(clip); ok {
+			seg.Bitrate = bitrate
+		}
 
-		// Write MAP tag if present and different from last
-		if mapData, ok := clipHLSMetadata["map"].(map[string]interface{}); ok {
-			mapURI, _ := mapData["uri"].(string)
-			var mapByterangeStr string
+		// A rise in the discontinuity sequence marks a discontinuity
+		clipHLSMetadata := e.getHLSMetadata(clip)
+		seq := e.getIntOrDefault(clipHLSMetadata, "discontinuity_sequence", p.DiscontinuitySequence)
+		if seq > lastSeq {
+			seg.Discontinuity = true
+		}
+		lastSeq = seq
 
-			if brData, ok := mapData["byterange"].(map[string]interface{}); ok {
-				br := ByterangeFromMetadata(brData)
-				mapByterangeStr = br.String()
-			}
+		p.Segments = append(p.Segments, seg)
+	}
 
-			// Only write if changed
-			if mapURI != lastMapURI || mapByterangeStr != lastMapByterange {
-				mapAttrs := make(AttributeList)
-				mapAttrs["URI"] = mapURI
-				if mapByterangeStr != "" {
-					mapAttrs["BYTERANGE"] = mapByterangeStr
-				}
-				output.WriteString(fmt.Sprintf("#EXT-X-MAP:%s\n", mapAttrs.String()))
-				lastMapURI = mapURI
-				lastMapByterange = mapByterangeStr
-			}
+	// The target duration is required, so derive it when not given
+	if p.TargetDuration == 0 {
+		for _, seg := range p.Segments {
+			p.TargetDuration = max(p.TargetDuration, int(math.Round(seg.Duration)))
 		}
+	}
+
+	if err := e.setVersion(p, hlsMetadata); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// setVersion sets the EXT-X-VERSION of a playlist: the version requested
+// with WithVersion, down-leveling the playlist to it, or else the version
+// in metadata raised to the lowest one the playlist's features allow
+func (e *Encoder) setVersion(p Playlist, hlsMetadata map[string]interface{}) error {
+	if e.version > 0 {
+		return Downlevel(p, e.version)
+	}
+	version := max(e.getIntOrDefault(hlsMetadata, "version", 0), MinVersion(p))
+	switch p := p.(type) {
+	case *MediaPlaylist:
+		p.Version = version
+	case *MultivariantPlaylist:
+		p.Version = version
+	}
+	return nil
+}
+
+// clipSegment converts a clip to a media segment
+func clipSegment(clip *gotio.Clip) *Segment {
+	clipHLSMetadata := namespace(clip.Metadata(), metadataNamespace)
+	streamingMetadata := namespace(clip.Metadata(), streamingMetadataNamespace)
 
-		// Get duration
-		duration, err := clip.Duration()
-		if err != nil {
-			duration = opentime.NewRationalTime(0, 1)
+	seg := &Segment{URI: targetURL(clip)}
+
+	// Get duration
+	if duration, err := clip.Duration(); err == nil {
+		seg.Duration = duration.ToSeconds()
+	}
+
+	// Get title (clip name)
+	if title := clip.Name(); title != seg.URI {
+		seg.Title = title
+	}
+
+	// Byterange from streaming metadata, or the HLS adapter's own form
+	if count, ok := asInt64(streamingMetadata["byte_count"]); ok {
+		offset, _ := asInt64(streamingMetadata["byte_offset"])
+		seg.Byterange = &Byterange{Count: count, Offset: offset}
+	} else if brData, ok := asMap(clipHLSMetadata["byterange"]); ok {
+		seg.Byterange = ByterangeFromMetadata(brData)
+	}
+
+	// Initialization section
+	if initURI, ok := streamingMetadata["init_uri"].(string); ok {
+		seg.Map = &Map{URI: initURI}
+		if brData, ok := asMap(streamingMetadata["init_byterange"]); ok {
+			count, _ := asInt64(brData["byte_count"])
+			offset, _ := asInt64(brData["byte_offset"])
+			seg.Map.Byterange = &Byterange{Count: count, Offset: offset}
+		}
+	} else if mapData, ok := asMap(clipHLSMetadata["map"]); ok {
+		mapURI, _ := mapData["uri"].(string)
+		seg.Map = &Map{URI: mapURI}
+		if brData, ok := asMap(mapData["byterange"]); ok {
+			seg.Map.Byterange = ByterangeFromMetadata(brData)
 		}
-		durationSeconds := duration.ToSeconds()
+	}
 
-		// Get title (clip name)
-		title := clip.Name()
+	if keyInfo, ok := clipHLSMetadata["EXT-X-KEY"].(string); ok {
+		if key, err := parseKey(ParseAttributeList(keyInfo)); err == nil && key.Method != "NONE" {
+			seg.Key = key
+		}
+	}
 
-		// Write EXTINF
-		if title != "" && title != e.getTargetURL(clip) {
-			output.WriteString(fmt.Sprintf("#EXTINF:%.6f,%s\n", durationSeconds, title))
-		} else {
-			output.WriteString(fmt.Sprintf("#EXTINF:%.6f,\n", durationSeconds))
+	if programDateTime, ok := clipHLSMetadata["EXT-X-PROGRAM-DATE-TIME"].(string); ok {
+		if t, err := parseDateTime(programDateTime); err == nil {
+			seg.ProgramDateTime = t
 		}
+	}
 
-		// Write byterange if present
-		if brData, ok := clipHLSMetadata["byterange"].(map[string]interface{}); ok {
-			br := ByterangeFromMetadata(brData)
-			output.WriteString(fmt.Sprintf("#EXT-X-BYTERANGE:%s\n", br.String()))
+	for _, value := range asStrings(clipHLSMetadata["EXT-X-DATERANGE"]) {
+		if dr, err := parseDateRange(ParseAttributeList(value)); err == nil {
+			seg.DateRanges = append(seg.DateRanges, dr)
 		}
+	}
+	if gap, ok := clipHLSMetadata["gap"].(bool); ok {
+		seg.Gap = gap
+	}
+	if bitrate, ok := asInt64(clipHLSMetadata["bitrate"]); ok {
+		seg.Bitrate = bitrate
+	}
+	seg.Parts = partsFromMetadata(clipHLSMetadata["EXT-X-PART"])
+	seg.Tags = tagsFromMetadata(clipHLSMetadata["tags"])
 
-		// Write segment URI
-		targetURL := e.getTargetURL(clip)
-		output.WriteString(fmt.Sprintf("%s\n", targetURL))
+	return seg
+}
+
+func definesFromMetadata(v interface{}) []Define {
+	var defines []Define
+	for _, value := range asStrings(v) {
+		if d, err := parseDefine(ParseAttributeList(value)); err == nil {
+			defines = append(defines, d)
+		}
 	}
+	return defines
+}
 
-	// Write end list tag
-	output.WriteString("#EXT-X-ENDLIST\n")
+func partsFromMetadata(v interface{}) []*PartialSegment {
+	var parts []*PartialSegment
+	for _, value := range asStrings(v) {
+		if part, err := parsePartialSegment(ParseAttributeList(value)); err == nil {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
 
-	// Write to output
-	_, err := e.w.Write([]byte(output.String()))
-	return err
+func tagsFromMetadata(v interface{}) []Tag {
+	var tags []Tag
+	for _, line := range asStrings(v) {
+		entry := ParsePlaylistEntry(line)
+		switch {
+		case entry == nil:
+		case entry.Type == EntryTypeTag:
+			tags = append(tags, Tag{Name: entry.Tag, Value: entry.Value})
+		case entry.Type == EntryTypeComment:
+			tags = append(tags, Tag{Value: entry.Value})
+		}
+	}
+	return tags
 }
 
 // getHLSMetadata extracts HLS metadata from an object's metadata
@@ -185,21 +373,11 @@ func (e *Encoder) getHLSMetadata(obj interface{}) map[string]interface{} {
 		return make(map[string]interface{})
 	}
 
-	if metadata == nil {
-		return make(map[string]interface{})
-	}
-
-	if hlsData, ok := metadata[metadataNamespace]; ok {
-		if hlsMap, ok := hlsData.(map[string]interface{}); ok {
-			return hlsMap
-		}
-	}
-
-	return make(map[string]interface{})
+	return namespace(metadata, metadataNamespace)
 }
 
-// getTargetURL extracts the target URL from a clip's media reference
-func (e *Encoder) getTargetURL(clip *gotio.Clip) string {
+// targetURL extracts the target URL from a clip's media reference
+func targetURL(clip *gotio.Clip) string {
 	ref := clip.MediaReference()
 	if ref == nil {
 		return ""
@@ -212,203 +390,317 @@ func (e *Encoder) getTargetURL(clip *gotio.Clip) string {
 	return ""
 }
 
-// encodeMasterPlaylist writes multiple tracks as a master playlist
-func (e *Encoder) encodeMasterPlaylist(t *gotio.Timeline) error {
-	var output strings.Builder
-
-	// Write header
-	output.WriteString("#EXTM3U\n")
-	output.WriteString("#EXT-X-VERSION:6\n")
+// timelineReservedKeys are timeline HLS metadata keys that are not
+// written verbatim as header tags of a master playlist
+var timelineReservedKeys = map[string]bool{
+	"master_playlist":      true,
+	"version":              true,
+	"independent_segments": true,
+	"tags":                 true,
+	"EXT-X-START":          true,
+	"EXT-X-DEFINE":         true,
+}
 
+// encodeMasterPlaylist converts multiple tracks to a master playlist
+func (e *Encoder) encodeMasterPlaylist(ctx context.Context, t *gotio.Timeline) (*MultivariantPlaylist, error) {
 	// Get timeline HLS metadata
 	timelineMetadata := e.getHLSMetadata(t)
 
+	p := &MultivariantPlaylist{}
+	if independent, ok := timelineMetadata["independent_segments"].(bool); ok {
+		p.IndependentSegments = independent
+	}
+	if start, ok := timelineMetadata["EXT-X-START"].(string); ok {
+		p.Start, _ = parseStart(ParseAttributeList(start))
+	}
+	p.Defines = definesFromMetadata(timelineMetadata["EXT-X-DEFINE"])
+
 	// Write any additional header tags from timeline metadata
-	for key, value := range timelineMetadata {
-		if key == "master_playlist" {
-			continue // Skip the directive itself
+	var keys []string
+	for key := range timelineMetadata {
+		if !timelineReservedKeys[key] {
+			keys = append(keys, key)
 		}
-		if value == nil {
-			output.WriteString(fmt.Sprintf("#%s\n", key))
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := timelineMetadata[key]; value == nil {
+			p.Tags = append(p.Tags, Tag{Name: key})
 		} else {
-			output.WriteString(fmt.Sprintf("#%s:%v\n", key, value))
+			p.Tags = append(p.Tags, Tag{Name: key, Value: fmt.Sprintf("%v", value)})
 		}
 	}
+	p.Tags = append(p.Tags, tagsFromMetadata(timelineMetadata["tags"])...)
 
 	tracks := t.Tracks().Children()
 
-	// Separate video and audio tracks
+	// Separate video, audio, subtitle and closed-caption tracks
 	var videoTracks []*gotio.Track
 	var audioTracks []*gotio.Track
+	var textTracks []*gotio.Track
 
 	for _, child := range tracks {
 		track, ok := child.(*gotio.Track)
 		if !ok {
 			continue
 		}
-		if track.Kind() == gotio.TrackKindVideo {
+		switch trackMediaType(track) {
+		case MediaTypeVideo:
 			videoTracks = append(videoTracks, track)
-		} else if track.Kind() == gotio.TrackKindAudio {
+		case MediaTypeAudio:
 			audioTracks = append(audioTracks, track)
+		case MediaTypeSubtitles, MediaTypeClosedCaptions:
+			textTracks = append(textTracks, track)
 		}
 	}
 
-	// Write EXT-X-MEDIA tags for audio tracks
+	// EXT-X-MEDIA renditions for audio tracks
 	for _, audioTrack := range audioTracks {
-		streamingMD := e.getStreamingMetadata(audioTrack)
-		trackHLSMD := e.getHLSMetadata(audioTrack)
-
-		groupID := e.getStringOrDefault(streamingMD, "group_id", "audio1")
-		uri := e.getStringOrDefault(trackHLSMD, "uri", audioTrack.Name()+".m3u8")
-
-		attrs := make(AttributeList)
-		attrs["TYPE"] = "AUDIO"
-		attrs["GROUP-ID"] = groupID
-		attrs["NAME"] = audioTrack.Name()
-		attrs["URI"] = uri
-
-		if autoselect, ok := streamingMD["autoselect"].(bool); ok && autoselect {
-			attrs["AUTOSELECT"] = "YES"
-		}
-		if defaultVal, ok := streamingMD["default"].(bool); ok && defaultVal {
-			attrs["DEFAULT"] = "YES"
-		}
-
-		output.WriteString(fmt.Sprintf("#EXT-X-MEDIA:%s\n", attrs.String()))
+		p.Renditions = append(p.Renditions, e.trackRendition(audioTrack, MediaTypeAudio))
 	}
 
-	if len(audioTracks) > 0 {
-		output.WriteString("\n")
+	// EXT-X-MEDIA renditions for subtitle tracks, then caption services
+	for _, mediaType := range []MediaType{MediaTypeSubtitles, MediaTypeClosedCaptions} {
+		for _, track := range textTracks {
+			if trackMediaType(track) == mediaType {
+				p.Renditions = append(p.Renditions, e.trackRendition(track, mediaType))
+			}
+		}
 	}
 
-	// Write EXT-X-I-FRAME-STREAM-INF tags for video tracks with iframe playlists
-	iframeWritten := false
+	// EXT-X-I-FRAME-STREAM-INF for video tracks with iframe playlists,
+	// and for trick-play tracks
 	for _, videoTrack := range videoTracks {
 		trackHLSMD := e.getHLSMetadata(videoTrack)
+		if IsTrickPlay(videoTrack) {
+			v := e.buildVariant(e.getStreamingMetadata(videoTrack), trackHLSMD)
+			v.IFrame = true
+			v.URI = e.trackURI(videoTrack, trackHLSMD)
+			p.IFrameVariants = append(p.IFrameVariants, v)
+			continue
+		}
+
 		iframeURI, hasIframe := trackHLSMD["iframe_uri"].(string)
+		iframeBandwidth, _ := asInt64(trackHLSMD["iframe_bandwidth"])
+		if pk := e.packaged[videoTrack]; pk != nil && pk.iframeURI != "" {
+			iframeURI, hasIframe = pk.iframeURI, true
+			iframeBandwidth = pk.iframeBandwidth
+		}
 		if !hasIframe {
 			continue
 		}
 
-		streamingMD := e.getStreamingMetadata(videoTrack)
-		attrs := e.buildStreamInfAttributes(streamingMD)
-
-		// Remove attributes not allowed for I-Frame playlists
-		delete(attrs, "FRAME-RATE")
-		delete(attrs, "AUDIO")
-		delete(attrs, "SUBTITLES")
-		delete(attrs, "CLOSED-CAPTIONS")
-
-		attrs["URI"] = iframeURI
-
-		output.WriteString(fmt.Sprintf("#EXT-X-I-FRAME-STREAM-INF:%s\n", attrs.String()))
-		iframeWritten = true
+		v := e.buildVariant(e.getStreamingMetadata(videoTrack), trackHLSMD)
+		v.IFrame = true
+		v.URI = iframeURI
+		// The variant's average bandwidth, score and stable ID do not
+		// describe the I-frames
+		v.AverageBandwidth = 0
+		v.Score = 0
+		v.StableVariantID = ""
+		if iframeBandwidth > 0 {
+			v.Bandwidth = iframeBandwidth
+		}
+		if codec, ok := trackHLSMD["iframe_codec"].(string); ok {
+			v.Codecs = codec
+		}
+		// Attributes not allowed for I-Frame playlists are dropped when
+		// the variant is written
+		p.IFrameVariants = append(p.IFrameVariants, v)
 	}
 
-	if iframeWritten {
-		output.WriteString("\n")
-	}
+	// EXT-X-STREAM-INF for video tracks
+	groups := e.audioGroups(audioTracks)
+	for i, videoTrack := range videoTracks {
+		if i%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if IsTrickPlay(videoTrack) {
+			continue
+		}
 
-	// Write EXT-X-STREAM-INF tags for video tracks
-	for _, videoTrack := range videoTracks {
-		streamingMD := e.getStreamingMetadata(videoTrack)
 		trackHLSMD := e.getHLSMetadata(videoTrack)
-		attrs := e.buildStreamInfAttributes(streamingMD)
-
-		// Get URI
-		uri := e.getStringOrDefault(trackHLSMD, "uri", videoTrack.Name()+".m3u8")
-
-		// Link to audio if available
-		linkedAdded := false
-		trackMetadata := videoTrack.Metadata()
-		if linkedTracks, ok := trackMetadata["linked_tracks"].([]interface{}); ok {
-			for _, audioTrack := range audioTracks {
-				for _, linkedName := range linkedTracks {
-					if linkedNameStr, ok := linkedName.(string); ok && linkedNameStr == audioTrack.Name() {
-						// Found a linked audio track
-						audioStreamingMD := e.getStreamingMetadata(audioTrack)
-						audioGroupID := e.getStringOrDefault(audioStreamingMD, "group_id", "audio1")
-						audioCodec := e.getStringOrDefault(audioStreamingMD, "codec", "")
-						audioBandwidth := e.getIntOrDefault(audioStreamingMD, "bandwidth", 0)
-
-						// Combine attributes
-						if audioCodec != "" {
-							if codec, ok := attrs["CODECS"]; ok {
-								attrs["CODECS"] = codec + "," + audioCodec
-							}
-						}
-						attrs["AUDIO"] = audioGroupID
-						if audioBandwidth > 0 {
-							if bw, ok := attrs.GetInt("BANDWIDTH"); ok == nil {
-								attrs["BANDWIDTH"] = fmt.Sprintf("%d", bw+audioBandwidth)
-							}
-						}
-
-						output.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:%s\n", attrs.String()))
-						output.WriteString(fmt.Sprintf("%s\n", uri))
-						linkedAdded = true
-						break
-					}
-				}
-				if linkedAdded {
-					break
-				}
-			}
+		v := e.buildVariant(e.getStreamingMetadata(videoTrack), trackHLSMD)
+		v.URI = e.trackURI(videoTrack, trackHLSMD)
+
+		// Without a group in the metadata, refer to the only one there is
+		if _, ok := trackHLSMD["subtitles"]; !ok {
+			v.Subtitles = onlyGroup(p.Renditions, MediaTypeSubtitles)
+		}
+		if _, ok := trackHLSMD["closed_captions"]; !ok {
+			v.ClosedCaptions = onlyGroup(p.Renditions, MediaTypeClosedCaptions)
 		}
 
-		// Write standalone entry if no audio was linked
-		if !linkedAdded {
-			output.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:%s\n", attrs.String()))
-			output.WriteString(fmt.Sprintf("%s\n", uri))
+		// One variant per audio group offered with the video
+		selected := e.variantGroups(videoTrack, audioTracks, groups)
+		if len(selected) == 0 {
+			p.Variants = append(p.Variants, v)
 		}
+		for _, g := range selected {
+			p.Variants = append(p.Variants, e.withAudioGroup(v, g))
+		}
+	}
 
-		output.WriteString("\n")
+	if err := e.setVersion(p, timelineMetadata); err != nil {
+		return nil, err
 	}
+	return p, nil
+}
 
-	// Write to output
-	_, err := e.w.Write([]byte(output.String()))
-	return err
+// trackMediaType classifies a track by its media_type metadata, or else
+// by its kind
+func trackMediaType(track *gotio.Track) MediaType {
+	if mediaType, ok := namespace(track.Metadata(), metadataNamespace)["media_type"].(string); ok {
+		return MediaType(mediaType)
+	}
+	switch track.Kind() {
+	case gotio.TrackKindVideo:
+		return MediaTypeVideo
+	case gotio.TrackKindAudio:
+		return MediaTypeAudio
+	}
+	return ""
 }
 
-// getStreamingMetadata extracts streaming metadata from track
-func (e *Encoder) getStreamingMetadata(track *gotio.Track) map[string]interface{} {
-	metadata := track.Metadata()
-	if metadata == nil {
-		return make(map[string]interface{})
+// renditionDefaultGroups are the GROUP-IDs of tracks without a group_id
+var renditionDefaultGroups = map[MediaType]string{
+	MediaTypeAudio:          defaultAudioGroup,
+	MediaTypeSubtitles:      "subs",
+	MediaTypeClosedCaptions: "cc",
+}
+
+// trackRendition builds the EXT-X-MEDIA rendition of an audio, subtitle or
+// closed-caption track from its streaming metadata. Caption services and
+// muxed audio are carried in the variant streams, so they have no URI.
+func (e *Encoder) trackRendition(track *gotio.Track, mediaType MediaType) *Rendition {
+	streamingMD := e.getStreamingMetadata(track)
+	trackHLSMD := e.getHLSMetadata(track)
+	attr := func(key string) string {
+		return e.getStringOrDefault(streamingMD, key, "")
+	}
+	flag := func(key string) bool {
+		value, _ := streamingMD[key].(bool)
+		return value
+	}
+
+	r := &Rendition{
+		Type:              mediaType,
+		GroupID:           e.getStringOrDefault(streamingMD, "group_id", renditionDefaultGroups[mediaType]),
+		Name:              e.getStringOrDefault(trackHLSMD, "name", track.Name()),
+		Language:          attr("language"),
+		AssocLanguage:     attr("assoc_language"),
+		StableRenditionID: attr("stable_rendition_id"),
+		Default:           flag("default"),
+		Autoselect:        flag("autoselect"),
+		Forced:            flag("forced"),
+		InstreamID:        attr("instream_id"),
+		Characteristics:   attr("characteristics"),
+		Channels:          attr("channels"),
+		BitDepth:          e.getIntOrDefault(streamingMD, "bit_depth", 0),
+		SampleRate:        e.getIntOrDefault(streamingMD, "sample_rate", 0),
+	}
+	if attrs, ok := trackHLSMD["attributes"].(string); ok {
+		r.Attrs = ParseAttributeList(attrs)
+	}
+	if mediaType != MediaTypeClosedCaptions && !flag("muxed") {
+		r.URI = e.trackURI(track, trackHLSMD)
 	}
+	return r
+}
 
-	if streamingData, ok := metadata[streamingMetadataNamespace]; ok {
-		if streamingMap, ok := streamingData.(map[string]interface{}); ok {
-			return streamingMap
+// onlyGroup returns the GROUP-ID of the renditions of a type when they
+// all share one
+func onlyGroup(renditions []*Rendition, mediaType MediaType) string {
+	group := ""
+	for _, r := range renditions {
+		if r.Type != mediaType {
+			continue
+		}
+		if group != "" && r.GroupID != group {
+			return ""
 		}
+		group = r.GroupID
 	}
+	return group
+}
 
-	return make(map[string]interface{})
+// trackURI returns the URI of a track's media playlist: where Package
+// writes it, its uri metadata, or the track name
+func (e *Encoder) trackURI(track *gotio.Track, trackHLSMD map[string]interface{}) string {
+	if pk := e.packaged[track]; pk != nil && pk.uri != "" {
+		return pk.uri
+	}
+	return e.getStringOrDefault(trackHLSMD, "uri", track.Name()+".m3u8")
 }
 
-// buildStreamInfAttributes builds attribute list for STREAM-INF tags
-func (e *Encoder) buildStreamInfAttributes(streamingMD map[string]interface{}) AttributeList {
-	attrs := make(AttributeList)
+// linkedAudioTrack returns the first audio track named in the video
+// track's linked_tracks metadata
+func (e *Encoder) linkedAudioTrack(videoTrack *gotio.Track, audioTracks []*gotio.Track) *gotio.Track {
+	linkedTracks := asStrings(videoTrack.Metadata()["linked_tracks"])
+	for _, audioTrack := range audioTracks {
+		for _, linkedName := range linkedTracks {
+			if linkedName == audioTrack.Name() {
+				return audioTrack
+			}
+		}
+	}
+	return nil
+}
 
-	if bandwidth, ok := streamingMD["bandwidth"]; ok {
-		attrs["BANDWIDTH"] = fmt.Sprintf("%v", bandwidth)
+// getStreamingMetadata extracts streaming metadata from track
+func (e *Encoder) getStreamingMetadata(track *gotio.Track) map[string]interface{} {
+	streamingMD := namespace(track.Metadata(), streamingMetadataNamespace)
+	if pk := e.packaged[track]; pk != nil && pk.bandwidth != nil {
+		streamingMD = maps.Clone(streamingMD)
+		streamingMD["bandwidth"] = pk.bandwidth.Peak
+		streamingMD["average_bandwidth"] = pk.bandwidth.Average
 	}
+	return streamingMD
+}
+
+// buildVariant builds a variant stream from track metadata
+func (e *Encoder) buildVariant(streamingMD, trackHLSMD map[string]interface{}) *Variant {
+	v := &Variant{}
 
-	if codec, ok := streamingMD["codec"].(string); ok {
-		attrs["CODECS"] = codec
+	if bandwidth, ok := asInt64(streamingMD["bandwidth"]); ok {
+		v.Bandwidth = bandwidth
 	}
+	if bandwidth, ok := asInt64(streamingMD["average_bandwidth"]); ok {
+		v.AverageBandwidth = bandwidth
+	}
+
+	v.Codecs = e.getStringOrDefault(streamingMD, "codec", "")
 
-	if frameRate, ok := streamingMD["frame_rate"]; ok {
-		attrs["FRAME-RATE"] = fmt.Sprintf("%v", frameRate)
+	if frameRate, ok := asFloat(streamingMD["frame_rate"]); ok {
+		v.FrameRate = frameRate
 	}
 
-	width, hasWidth := streamingMD["width"]
-	height, hasHeight := streamingMD["height"]
+	width, hasWidth := asInt64(streamingMD["width"])
+	height, hasHeight := asInt64(streamingMD["height"])
 	if hasWidth && hasHeight {
-		attrs["RESOLUTION"] = fmt.Sprintf("%v%s%v", width, "x", height)
+		v.Resolution = &Resolution{Width: int(width), Height: int(height)}
 	}
 
-	return attrs
+	v.HDCPLevel = e.getStringOrDefault(streamingMD, "hdcp_level", "")
+	if score, ok := asFloat(streamingMD["score"]); ok {
+		v.Score = score
+	}
+	v.SupplementalCodecs = e.getStringOrDefault(streamingMD, "supplemental_codecs", "")
+	v.VideoRange = VideoRange(e.getStringOrDefault(streamingMD, "video_range", ""))
+	v.AllowedCPC = e.getStringOrDefault(streamingMD, "allowed_cpc", "")
+	v.StableVariantID = e.getStringOrDefault(streamingMD, "stable_variant_id", "")
+	v.PathwayID = e.getStringOrDefault(streamingMD, "pathway_id", "")
+	v.ReqVideoLayout = e.getStringOrDefault(streamingMD, "req_video_layout", "")
+	v.Video = e.getStringOrDefault(trackHLSMD, "video", "")
+	v.Subtitles = e.getStringOrDefault(trackHLSMD, "subtitles", "")
+	v.ClosedCaptions = e.getStringOrDefault(trackHLSMD, "closed_captions", "")
+	if attrs, ok := trackHLSMD["attributes"].(string); ok {
+		v.Attrs = ParseAttributeList(attrs)
+	}
+
+	return v
 }
 
 // Helper functions for metadata extraction
@@ -420,10 +712,7 @@ func (e *Encoder) getStringOrDefault(m map[string]interface{}, key, defaultVal s
 }
 
 func (e *Encoder) getIntOrDefault(m map[string]interface{}, key string, defaultVal int) int {
-	if val, ok := m[key].(int); ok {
-		return val
-	}
-	if val, ok := m[key].(float64); ok {
+	if val, ok := asInt64(m[key]); ok {
 		return int(val)
 	}
 	return defaultVal
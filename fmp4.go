@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/gotio/opentime"
+	"github.com/Avalanche-io/otio-hls/internal/isobmff"
+)
+
+// subsegment is a byterange of a single-file fMP4 with its duration in
+// timescale units
+type subsegment struct {
+	offset, size int64
+	duration     uint64
+	independent  bool
+}
+
+// TrackFromFMP4 builds a track of byterange clips from a single fragmented
+// MP4 file opened from fsys. The clips follow the file's sidx box, or
+// else its moof/mdat pairs, starting a new clip at each fragment that
+// begins with a sync sample. Clip durations are exact in the media
+// timescale and the initialization section is the file's ftyp and moov
+// boxes. Encoding the track writes a version 7 playlist of EXT-X-MAP and
+// EXT-X-BYTERANGE segments that refer to name.
+func TrackFromFMP4(fsys fs.FS, name string) (*gotio.Track, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	boxes, err := isobmff.ScanBoxes(r, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	moov, ok := isobmff.Find(boxes, "moov")
+	if !ok {
+		return nil, fmt.Errorf("%s: no moov box", name)
+	}
+	init := make([]byte, moov.End())
+	if _, err := r.ReadAt(init, 0); err != nil {
+		return nil, fmt.Errorf("%s: reading initialization section: %w", name, err)
+	}
+	tracks, err := isobmff.ParseInit(init)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%s: no tracks", name)
+	}
+
+	timescale, subsegments, err := sidxSubsegments(r, boxes)
+	if err == nil && subsegments == nil {
+		track := tracks[0]
+		for _, t := range tracks {
+			if t.Handler == isobmff.HandlerVideo {
+				track = t
+				break
+			}
+		}
+		timescale = track.Timescale
+		subsegments, err = fragmentSubsegments(r, boxes, track)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(subsegments) == 0 {
+		return nil, fmt.Errorf("%s: no movie fragments", name)
+	}
+	if timescale == 0 {
+		return nil, fmt.Errorf("%s: missing timescale", name)
+	}
+
+	kind := gotio.TrackKindVideo
+	if tracks[0].Handler == isobmff.HandlerAudio && len(tracks) == 1 {
+		kind = gotio.TrackKindAudio
+	}
+
+	p := &MediaPlaylist{
+		Version:             7,
+		PlaylistType:        PlaylistTypeVOD,
+		IndependentSegments: true,
+		EndList:             true,
+	}
+	initMap := &Map{URI: name, Byterange: &Byterange{Count: moov.End()}}
+	for _, sub := range subsegments {
+		p.IndependentSegments = p.IndependentSegments && sub.independent
+		p.Segments = append(p.Segments, &Segment{
+			URI:       name,
+			Duration:  float64(sub.duration) / float64(timescale),
+			Byterange: &Byterange{Count: sub.size, Offset: sub.offset},
+			Map:       initMap,
+		})
+	}
+
+	durations := make([]uint64, len(subsegments))
+	for i, sub := range subsegments {
+		durations[i] = sub.duration
+	}
+	trackName := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	return exactTrack(trackName, kind, p, durations, timescale), nil
+}
+
+// exactTrack converts a media playlist to a track whose clip source ranges
+// are the given durations in timescale units, rather than the rounded
+// seconds of the segments
+func exactTrack(name, kind string, p *MediaPlaylist, durations []uint64, timescale uint32) *gotio.Track {
+	d := &Decoder{rate: 1}
+	md := gotio.AnyDictionary{metadataNamespace: mediaPlaylistMetadata(p)}
+	track := gotio.NewTrack(name, nil, kind, md, nil)
+	rate := float64(timescale)
+	for i, seg := range p.Segments {
+		clip := d.createClip(seg, 0)
+		tr := opentime.NewTimeRange(
+			opentime.NewRationalTime(0, rate),
+			opentime.NewRationalTime(float64(durations[i]), rate),
+		)
+		clip.SetSourceRange(&tr)
+		track.AppendChild(clip)
+	}
+	return track
+}
+
+// sidxSubsegments returns the subsegments listed in the file's first sidx
+// box, or none when it has no usable index
+func sidxSubsegments(r io.ReaderAt, boxes []isobmff.Box) (uint32, []subsegment, error) {
+	box, ok := isobmff.Find(boxes, "sidx")
+	if !ok {
+		return 0, nil, nil
+	}
+	box, err := box.Load(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	idx, err := isobmff.ParseSegmentIndex(box)
+	if errors.Is(err, isobmff.ErrIndirectIndex) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	subsegments := make([]subsegment, len(idx.References))
+	for i, ref := range idx.References {
+		subsegments[i] = subsegment{
+			offset:      ref.Offset,
+			size:        int64(ref.Size),
+			duration:    uint64(ref.Duration),
+			independent: ref.StartsWithSAP,
+		}
+	}
+	return idx.Timescale, subsegments, nil
+}
+
+// fragmentSubsegments groups the file's movie fragments into subsegments,
+// starting one at every fragment of track that begins with a sync sample.
+// Boxes such as styp or emsg before a moof belong to its subsegment.
+func fragmentSubsegments(r io.ReaderAt, boxes []isobmff.Box, track *isobmff.Track) ([]subsegment, error) {
+	var subsegments []subsegment
+	start := int64(-1)
+	for _, box := range boxes {
+		switch box.Type {
+		case "moof":
+			if start < 0 {
+				start = box.Offset
+			}
+			moof, err := box.Load(r)
+			if err != nil {
+				return nil, err
+			}
+			fragments, err := isobmff.MovieFragment(moof, track)
+			if err != nil {
+				return nil, err
+			}
+			var duration uint64
+			sync := false
+			for i, f := range fragments {
+				duration += f.Duration()
+				if i == 0 && len(f.Samples) > 0 {
+					sync = f.Samples[0].Sync
+				}
+			}
+			if len(subsegments) == 0 || sync {
+				subsegments = append(subsegments, subsegment{offset: start, independent: sync})
+			}
+			last := &subsegments[len(subsegments)-1]
+			last.duration += duration
+			last.size = box.End() - last.offset
+			start = -1
+		case "mdat":
+			if len(subsegments) > 0 {
+				last := &subsegments[len(subsegments)-1]
+				last.size = box.End() - last.offset
+			}
+			start = -1
+		case "ftyp", "moov", "sidx", "mfra", "free", "skip":
+			start = -1
+		default:
+			if start < 0 {
+				start = box.Offset
+			}
+		}
+	}
+	return subsegments, nil
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// gopSamples returns a fragment's samples, the first one a sync sample
+// when key is set
+func gopSamples(n int, duration, size uint32, key bool) []mp4Sample {
+	samples := make([]mp4Sample, n)
+	for i := range samples {
+		samples[i] = mp4Sample{duration: duration, size: size, sync: key && i == 0}
+	}
+	return samples
+}
+
+func TestTrackFromFMP4WithSidx(t *testing.T) {
+	init := buildInit(24000)
+	fragments := [][]byte{
+		buildFragment(1, 0, gopSamples(24, 1001, 100, true)),
+		buildFragment(2, 24*1001, gopSamples(24, 1001, 100, true)),
+		buildFragment(3, 48*1001, gopSamples(12, 1001, 100, true)),
+	}
+	var sizes []int
+	for _, f := range fragments {
+		sizes = append(sizes, len(f))
+	}
+	sidx := buildSidx(24000, sizes, []uint32{24 * 1001, 24 * 1001, 12 * 1001})
+	file := append(append([]byte{}, init...), sidx...)
+	for _, f := range fragments {
+		file = append(file, f...)
+	}
+
+	track, err := TrackFromFMP4(fstest.MapFS{"media-video-1.mp4": {Data: file}}, "media-video-1.mp4")
+	if err != nil {
+		t.Fatalf("TrackFromFMP4 failed: %v", err)
+	}
+	if track.Name() != "media-video-1" || track.Kind() != gotio.TrackKindVideo {
+		t.Errorf("Unexpected track %q of kind %s", track.Name(), track.Kind())
+	}
+
+	clips := track.Children()
+	if len(clips) != 3 {
+		t.Fatalf("Expected 3 clips, got %d", len(clips))
+	}
+	duration, err := clips[0].(*gotio.Clip).Duration()
+	if err != nil {
+		t.Fatalf("Duration failed: %v", err)
+	}
+	if duration.Value() != 24*1001 || duration.Rate() != 24000 {
+		t.Errorf("Expected exact duration 24024/24000, got %v/%v", duration.Value(), duration.Rate())
+	}
+
+	timeline := gotio.NewTimeline("", nil, nil)
+	timeline.Tracks().AppendChild(track)
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	var media MediaPlaylist
+	if err := media.Unmarshal(buf.Bytes()); err != nil {
+		t.Fatalf("Unmarshal of encoded playlist failed: %v", err)
+	}
+	if media.Version != 7 || !media.IndependentSegments || media.PlaylistType != PlaylistTypeVOD {
+		t.Errorf("Unexpected header:\n%s", buf.String())
+	}
+	offset := int64(len(init) + len(sidx))
+	for i, seg := range media.Segments {
+		if seg.Map == nil || seg.Map.URI != "media-video-1.mp4" || *seg.Map.Byterange != (Byterange{Count: int64(len(init))}) {
+			t.Errorf("Segment %d: unexpected map %+v", i, seg.Map)
+		}
+		if *seg.Byterange != (Byterange{Count: int64(sizes[i]), Offset: offset}) {
+			t.Errorf("Segment %d: unexpected byterange %s", i, seg.Byterange)
+		}
+		offset += int64(sizes[i])
+	}
+	if media.Segments[2].Duration != 0.5005 {
+		t.Errorf("Expected last duration 0.5005, got %v", media.Segments[2].Duration)
+	}
+}
+
+func TestTrackFromFMP4WithoutSidx(t *testing.T) {
+	styp := mp4Box("styp", []byte("msdh"), u32s(0))
+	init := buildInit(1000)
+	// The second fragment continues the first GOP, so both form one clip
+	chunks := [][]byte{
+		buildFragment(1, 0, gopSamples(10, 100, 50, true)),
+		buildFragment(2, 1000, gopSamples(10, 100, 50, false)),
+		buildFragment(3, 2000, gopSamples(10, 100, 50, true)),
+	}
+	file := append(append([]byte{}, init...), styp...)
+	file = append(file, chunks[0]...)
+	file = append(file, chunks[1]...)
+	file = append(file, styp...)
+	file = append(file, chunks[2]...)
+
+	track, err := TrackFromFMP4(fstest.MapFS{"a.mp4": {Data: file}}, "a.mp4")
+	if err != nil {
+		t.Fatalf("TrackFromFMP4 failed: %v", err)
+	}
+
+	clips := track.Children()
+	if len(clips) != 2 {
+		t.Fatalf("Expected 2 clips, got %d", len(clips))
+	}
+	first := namespace(clips[0].(*gotio.Clip).Metadata(), streamingMetadataNamespace)
+	second := namespace(clips[1].(*gotio.Clip).Metadata(), streamingMetadataNamespace)
+	firstSize := int64(len(styp) + len(chunks[0]) + len(chunks[1]))
+	if first["byte_offset"] != int64(len(init)) || first["byte_count"] != firstSize {
+		t.Errorf("Unexpected first clip byterange: %v", first)
+	}
+	if second["byte_offset"] != int64(len(init))+firstSize || second["byte_count"] != int64(len(styp)+len(chunks[2])) {
+		t.Errorf("Unexpected second clip byterange: %v", second)
+	}
+	if d, _ := clips[0].(*gotio.Clip).Duration(); d.ToSeconds() != 2 {
+		t.Errorf("Expected first clip to last 2 seconds, got %v", d.ToSeconds())
+	}
+}
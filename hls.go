@@ -8,6 +8,7 @@ package hls
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -30,9 +31,6 @@ const (
 	tagEXTXProgramDateTime = "#EXT-X-PROGRAM-DATE-TIME:"
 	tagEXTXDiscontinuity   = "#EXT-X-DISCONTINUITY"
 
-	// Default HLS version
-	defaultHLSVersion = 3
-
 	// Metadata namespace for HLS-specific data
 	metadataNamespace = "HLS"
 
@@ -113,55 +111,67 @@ func ByterangeFromMetadata(m map[string]interface{}) *Byterange {
 // AttributeList represents HLS attribute list (key=value pairs)
 type AttributeList map[string]string
 
-var (
-	// Regex patterns for parsing attribute lists
-	reQuoted     = regexp.MustCompile(`(\w+)="([^"]*)"`)
-	reResolution = regexp.MustCompile(`(\w+)=(\d+x\d+)`)
-	reHex        = regexp.MustCompile(`(\w+)=(0x[0-9A-Fa-f]+)`)
-	reFloat      = regexp.MustCompile(`(\w+)=(\d+(?:\.\d+)?)`)
-	reEnum       = regexp.MustCompile(`(\w+)=([A-Z0-9-]+)`)
-)
-
-// ParseAttributeList parses an HLS attribute list string
+// ParseAttributeList parses an HLS attribute list string. Malformed pairs
+// are skipped.
 func ParseAttributeList(s string) AttributeList {
+	attrs, _ := parseAttributeList(s, 0)
+	return attrs
+}
+
+// parseAttributeList parses an attribute list, failing once it holds more
+// than max attributes (max <= 0 means unlimited)
+func parseAttributeList(s string, max int) (AttributeList, error) {
 	attrs := make(AttributeList)
 
-	// Parse each key=value pair by iterating through the string
-	// This prevents overlapping matches
 	remaining := s
 	for len(remaining) > 0 {
-		remaining = strings.TrimSpace(remaining)
+		remaining = strings.TrimLeft(remaining, " \t,")
 		if remaining == "" {
 			break
 		}
 
-		// Try each pattern in order
-		matched := false
-		for _, re := range []*regexp.Regexp{reQuoted, reResolution, reHex, reFloat, reEnum} {
-			if loc := re.FindStringIndex(remaining); loc != nil && loc[0] == 0 {
-				match := re.FindStringSubmatch(remaining)
-				if len(match) == 3 {
-					attrs[match[1]] = match[2]
-					remaining = remaining[loc[1]:]
-					// Skip comma if present
-					remaining = strings.TrimPrefix(remaining, ",")
-					matched = true
-					break
-				}
+		eq := strings.IndexAny(remaining, "=,")
+		if eq <= 0 || remaining[eq] == ',' {
+			// No name=value pair here, skip to the next comma
+			if eq < 0 {
+				break
 			}
+			remaining = remaining[eq+1:]
+			continue
 		}
-
-		if !matched {
-			// Skip to next comma or end
-			if idx := strings.Index(remaining, ","); idx > 0 {
-				remaining = remaining[idx+1:]
+		name := strings.TrimSpace(remaining[:eq])
+		remaining = remaining[eq+1:]
+
+		var value string
+		if strings.HasPrefix(remaining, `"`) {
+			// Quoted strings may contain commas
+			end := strings.IndexByte(remaining[1:], '"')
+			if end < 0 {
+				value = remaining[1:]
+				remaining = ""
 			} else {
-				break
+				value = remaining[1 : end+1]
+				remaining = remaining[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(remaining, ',')
+			if end < 0 {
+				end = len(remaining)
 			}
+			value = strings.TrimSpace(remaining[:end])
+			remaining = remaining[end:]
+		}
+
+		if name == "" {
+			continue
 		}
+		if _, exists := attrs[name]; !exists && max > 0 && len(attrs) >= max {
+			return attrs, &LimitError{Kind: LimitAttributes, Max: int64(max)}
+		}
+		attrs[name] = value
 	}
 
-	return attrs
+	return attrs, nil
 }
 
 // Get returns an attribute value
@@ -187,10 +197,18 @@ func (a AttributeList) GetFloat(key string) (float64, error) {
 	return strconv.ParseFloat(val, 64)
 }
 
-// String returns the attribute list as an HLS-formatted string
+// String returns the attribute list as an HLS-formatted string, with the
+// attributes in name order
 func (a AttributeList) String() string {
-	var parts []string
-	for k, v := range a {
+	names := make([]string, 0, len(a))
+	for k := range a {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		v := a[k]
 		// Quote string values
 		if needsQuoting(v) {
 			parts = append(parts, fmt.Sprintf(`%s="%s"`, k, v))
@@ -221,6 +239,8 @@ type PlaylistEntry struct {
 	Tag   string
 	Value string
 	URI   string
+	// Line is the 1-based line number the entry was read from, if known
+	Line int
 }
 
 // EntryType represents the type of playlist entry
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"github.com/Avalanche-io/gotio"
+)
+
+// IsTrickPlay reports whether a track was decoded from, and encodes as, an
+// I-frame only playlist
+func IsTrickPlay(track *gotio.Track) bool {
+	iframesOnly, _ := namespace(track.Metadata(), metadataNamespace)["iframes_only"].(bool)
+	return iframesOnly
+}
+
+// LinkIFrames links the clips of a trick-play track to the clips of the
+// main rendition they were cut from. An I-frame belongs to the main clip
+// with the same target URL whose byterange contains the I-frame's offset,
+// or to the one with the same URL when the main clip has no byterange.
+// The index of that clip is stored as "parent_clip" in the I-frame's HLS
+// metadata. LinkIFrames returns the number of I-frames linked.
+func LinkIFrames(iframes, main *gotio.Track) int {
+	type parent struct {
+		index         int
+		offset, count int64
+		ranged        bool
+	}
+
+	parents := make(map[string][]parent)
+	for i, child := range main.Children() {
+		clip, ok := child.(*gotio.Clip)
+		if !ok {
+			continue
+		}
+		p := parent{index: i}
+		streamingMD := namespace(clip.Metadata(), streamingMetadataNamespace)
+		if count, ok := asInt64(streamingMD["byte_count"]); ok {
+			p.offset, _ = asInt64(streamingMD["byte_offset"])
+			p.count = count
+			p.ranged = true
+		}
+		uri := targetURL(clip)
+		parents[uri] = append(parents[uri], p)
+	}
+
+	linked := 0
+	for _, child := range iframes.Children() {
+		clip, ok := child.(*gotio.Clip)
+		if !ok {
+			continue
+		}
+		offset, _ := asInt64(namespace(clip.Metadata(), streamingMetadataNamespace)["byte_offset"])
+		for _, p := range parents[targetURL(clip)] {
+			if p.ranged && (offset < p.offset || offset >= p.offset+p.count) {
+				continue
+			}
+			md := clip.Metadata()
+			if md == nil {
+				md = make(gotio.AnyDictionary)
+			}
+			hlsMD := namespace(md, metadataNamespace)
+			hlsMD["parent_clip"] = p.index
+			md[metadataNamespace] = hlsMD
+			clip.SetMetadata(md)
+			linked++
+			break
+		}
+	}
+	return linked
+}
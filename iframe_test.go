@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+const iframeTestPlaylist = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXT-X-I-FRAMES-ONLY
+#EXTINF:2.002,
+#EXT-X-BYTERANGE:9400@376
+main.ts
+#EXTINF:2.002,
+#EXT-X-BYTERANGE:8836@250000
+main.ts
+#EXTINF:4.004,
+#EXT-X-BYTERANGE:9212@620000
+main.ts
+#EXT-X-ENDLIST
+`
+
+const iframeMainPlaylist = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.004,
+#EXT-X-BYTERANGE:500000@0
+main.ts
+#EXTINF:4.004,
+#EXT-X-BYTERANGE:500000
+main.ts
+#EXT-X-ENDLIST
+`
+
+func TestDecodeIFramesOnly(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(iframeTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	track := firstTrack(t, timeline)
+	if !IsTrickPlay(track) {
+		t.Error("Expected a trick-play track")
+	}
+
+	clips := track.Children()
+	if len(clips) != 3 {
+		t.Fatalf("Expected 3 clips, got %d", len(clips))
+	}
+	streamingMD := namespace(clips[1].(*gotio.Clip).Metadata(), streamingMetadataNamespace)
+	if streamingMD["byte_count"] != int64(8836) || streamingMD["byte_offset"] != int64(250000) {
+		t.Errorf("Unexpected byterange metadata: %v", streamingMD)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	for _, want := range []string{
+		"#EXT-X-VERSION:4\n",
+		"#EXT-X-I-FRAMES-ONLY\n",
+		"#EXT-X-BYTERANGE:9212@620000\nmain.ts\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestEncodeIFramesOnlyRaisesVersion(t *testing.T) {
+	track := gotio.NewTrack("", nil, gotio.TrackKindVideo, gotio.AnyDictionary{
+		metadataNamespace: map[string]interface{}{"iframes_only": true},
+	}, nil)
+	timeline := gotio.NewTimeline("", nil, nil)
+	timeline.Tracks().AppendChild(track)
+
+	p, err := NewEncoder(nil).Playlist(timeline)
+	if err != nil {
+		t.Fatalf("Playlist failed: %v", err)
+	}
+	media := p.(*MediaPlaylist)
+	if !media.IFramesOnly || media.Version != 4 {
+		t.Errorf("Expected I-frame playlist at version 4, got %v at %d", media.IFramesOnly, media.Version)
+	}
+}
+
+func TestLinkIFrames(t *testing.T) {
+	iframes, err := NewDecoder(strings.NewReader(iframeTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode of I-frame playlist failed: %v", err)
+	}
+	main, err := NewDecoder(strings.NewReader(iframeMainPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode of main playlist failed: %v", err)
+	}
+
+	iframeTrack := firstTrack(t, iframes)
+	if n := LinkIFrames(iframeTrack, firstTrack(t, main)); n != 3 {
+		t.Fatalf("Expected 3 linked I-frames, got %d", n)
+	}
+
+	for i, want := range []int{0, 0, 1} {
+		clip := iframeTrack.Children()[i].(*gotio.Clip)
+		if got := namespace(clip.Metadata(), metadataNamespace)["parent_clip"]; got != want {
+			t.Errorf("I-frame %d: expected parent %d, got %v", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/url"
+	"path"
+
+	"github.com/Avalanche-io/otio-hls/internal/isobmff"
+	"github.com/Avalanche-io/otio-hls/internal/mpegts"
+)
+
+// ErrNoKeyframes is returned when the segments of a playlist contain no
+// keyframes to index
+var ErrNoKeyframes = errors.New("no keyframes found")
+
+// keyframe is an I-frame found in a segment
+type keyframe struct {
+	offset, size int64
+	// time is seconds from the start of the segment
+	time float64
+}
+
+// GenerateIFramePlaylist scans the segments of a media playlist for
+// keyframes and returns an I-frame only playlist indexing them. Segment
+// and initialization section URIs are opened from fsys relative to the
+// playlist. MPEG-TS segments are indexed by their H.264 IDR or H.265 IRAP
+// pictures, fragmented MP4 by the sync samples of the video track.
+//
+// Each I-frame lasts until the next one. Times are measured from the first
+// video sample of each segment and placed on the playlist's EXTINF
+// timeline, so the I-frames line up with the segments they came from.
+// I-frames from MPEG-TS segments get an EXT-X-MAP covering the program
+// association and map tables of their segment.
+func GenerateIFramePlaylist(fsys fs.FS, p *MediaPlaylist) (*MediaPlaylist, error) {
+	out := &MediaPlaylist{
+		Version:      4,
+		PlaylistType: p.PlaylistType,
+		IFramesOnly:  true,
+		EndList:      p.EndList,
+	}
+
+	var (
+		iframes []*Segment
+		times   []float64
+		start   float64
+		tracks  = make(map[string]*isobmff.Track)
+		// lastTables is the map of the last MPEG-TS segment
+		lastTables *Map
+	)
+	discontinuity := false
+	for _, seg := range p.Segments {
+		discontinuity = discontinuity || seg.Discontinuity
+		if seg.Key != nil {
+			return nil, fmt.Errorf("segment %q: encrypted segments cannot be scanned", seg.URI)
+		}
+
+		data, offset, err := readSegment(fsys, seg.URI, seg.Byterange)
+		if err != nil {
+			return nil, err
+		}
+
+		var keyframes []keyframe
+		segMap := seg.Map
+		if seg.Map != nil {
+			track, err := initVideoTrack(fsys, seg.Map, tracks)
+			if err != nil {
+				return nil, err
+			}
+			keyframes, err = fmp4Keyframes(data, offset, track)
+			if err != nil {
+				return nil, fmt.Errorf("segment %q: %w", seg.URI, err)
+			}
+			out.Version = 5
+		} else {
+			keyframes, err = tsKeyframes(data, offset)
+			if err != nil {
+				return nil, fmt.Errorf("segment %q: %w", seg.URI, err)
+			}
+			// I-frames leave out the program tables, so a map points at
+			// those of the segment
+			tablesStart, tablesEnd, ok := mpegts.Tables(data, offset)
+			if !ok && len(keyframes) > 0 {
+				return nil, fmt.Errorf("segment %q: no program tables", seg.URI)
+			}
+			tables := &Map{URI: seg.URI, Byterange: &Byterange{Count: tablesEnd - tablesStart, Offset: tablesStart}}
+			if sameMap(tables, lastTables) {
+				tables = lastTables
+			}
+			segMap, lastTables = tables, tables
+			out.Version = 5
+		}
+
+		for _, kf := range keyframes {
+			iframe := &Segment{
+				URI:           seg.URI,
+				Byterange:     &Byterange{Count: kf.size, Offset: kf.offset},
+				Discontinuity: discontinuity,
+				Map:           segMap,
+			}
+			discontinuity = false
+			iframes = append(iframes, iframe)
+			times = append(times, start+kf.time)
+		}
+		start += seg.Duration
+	}
+	if len(iframes) == 0 {
+		return nil, ErrNoKeyframes
+	}
+
+	// Each I-frame lasts until the next, the last until the playlist ends
+	for i, iframe := range iframes {
+		end := start
+		if i+1 < len(times) {
+			end = times[i+1]
+		}
+		iframe.Duration = math.Max(end-times[i], 0)
+		out.TargetDuration = max(out.TargetDuration, int(math.Round(iframe.Duration)))
+	}
+	out.TargetDuration = max(out.TargetDuration, 1)
+	out.Segments = iframes
+	return out, nil
+}
+
+// segmentPath maps a segment URI to a path in the file system
+func segmentPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", fmt.Errorf("segment %q is not a local file", uri)
+	}
+	name := path.Clean(u.Path)
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("segment %q is outside the playlist directory", uri)
+	}
+	return name, nil
+}
+
+// readSegment reads a segment, or the byterange of it, returning the data
+// and its position in the file
+func readSegment(fsys fs.FS, uri string, br *Byterange) ([]byte, int64, error) {
+	name, err := segmentPath(uri)
+	if err != nil {
+		return nil, 0, err
+	}
+	if br == nil {
+		data, err := fs.ReadFile(fsys, name)
+		return data, 0, err
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	data := make([]byte, br.Count)
+	if ra, ok := f.(io.ReaderAt); ok {
+		_, err = ra.ReadAt(data, br.Offset)
+	} else {
+		if _, err = io.CopyN(io.Discard, f, br.Offset); err == nil {
+			_, err = io.ReadFull(f, data)
+		}
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("segment %q: reading byterange %s: %w", uri, br, err)
+	}
+	return data, br.Offset, nil
+}
+
+// initVideoTrack returns the video track of an initialization section,
+// caching it by URI and byterange
+func initVideoTrack(fsys fs.FS, m *Map, cache map[string]*isobmff.Track) (*isobmff.Track, error) {
+	cacheKey := m.String()
+	if track, ok := cache[cacheKey]; ok {
+		return track, nil
+	}
+
+	data, _, err := readSegment(fsys, m.URI, m.Byterange)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := isobmff.ParseInit(data)
+	if err != nil {
+		return nil, fmt.Errorf("initialization section %q: %w", m.URI, err)
+	}
+	for _, track := range tracks {
+		if track.Handler == isobmff.HandlerVideo {
+			cache[cacheKey] = track
+			return track, nil
+		}
+	}
+	return nil, fmt.Errorf("initialization section %q has no video track", m.URI)
+}
+
+// fmp4Keyframes finds the sync samples of a track. An I-frame spans from
+// its moof box to the end of the sample, so a player can parse it alone.
+func fmp4Keyframes(data []byte, offset int64, track *isobmff.Track) ([]keyframe, error) {
+	fragments, err := isobmff.ParseFragments(data, offset, track)
+	if err != nil {
+		return nil, err
+	}
+	if track.Timescale == 0 {
+		return nil, fmt.Errorf("track %d has no timescale", track.ID)
+	}
+
+	var keyframes []keyframe
+	first, haveFirst := uint64(0), false
+	for _, f := range fragments {
+		for _, s := range f.Samples {
+			if !haveFirst {
+				first, haveFirst = s.DecodeTime, true
+			}
+			if !s.Sync {
+				continue
+			}
+			keyframes = append(keyframes, keyframe{
+				offset: f.Offset,
+				size:   s.Offset + int64(s.Size) - f.Offset,
+				time:   float64(s.DecodeTime-first) / float64(track.Timescale),
+			})
+		}
+	}
+	return keyframes, nil
+}
+
+// tsKeyframes finds the video PES packets holding a random access point.
+// An I-frame spans the transport packets of its PES packet.
+func tsKeyframes(data []byte, offset int64) ([]keyframe, error) {
+	var keyframes []keyframe
+	first := int64(mpegts.NoTimestamp)
+	err := mpegts.ReadPES(data, offset, func(pes *mpegts.PES) error {
+		if !pes.IsVideo() {
+			return nil
+		}
+		if first == mpegts.NoTimestamp {
+			first = pes.DTS
+		}
+		if !pes.IsKeyframe() {
+			return nil
+		}
+		kf := keyframe{offset: pes.Offset, size: pes.End - pes.Offset}
+		if first != mpegts.NoTimestamp && pes.DTS != mpegts.NoTimestamp {
+			kf.time = math.Max(float64(mpegts.TimestampDiff(first, pes.DTS))/90000, 0)
+		}
+		keyframes = append(keyframes, kf)
+		return nil
+	})
+	return keyframes, err
+}
+
+// IFrameBandwidth returns the BANDWIDTH of an I-frame only playlist: the
+// peak bit rate of a single I-frame over its duration
+func IFrameBandwidth(p *MediaPlaylist) int64 {
+	var peak float64
+	for _, seg := range p.Segments {
+		if seg.Byterange == nil || seg.Duration <= 0 {
+			continue
+		}
+		peak = math.Max(peak, float64(seg.Byterange.Count*8)/seg.Duration)
+	}
+	return int64(math.Ceil(peak))
+}
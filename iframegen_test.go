@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGenerateIFramePlaylistTS(t *testing.T) {
+	// Two 4 second segments at 25 fps with a keyframe every 2 seconds
+	segment := func(start int64) []byte {
+		var frames []tsFrame
+		for i := int64(0); i < 100; i++ {
+			frames = append(frames, tsFrame{pts: start + i*3600, key: i%50 == 0, size: 400})
+		}
+		return buildTS(frames)
+	}
+	fsys := fstest.MapFS{
+		"video/seg0.ts": {Data: segment(900000)},
+		"video/seg1.ts": {Data: segment(900000 + 360000)},
+	}
+
+	var media MediaPlaylist
+	err := media.Unmarshal([]byte(`#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.0,
+video/seg0.ts
+#EXTINF:4.0,
+video/seg1.ts
+#EXT-X-ENDLIST
+`))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	iframes, err := GenerateIFramePlaylist(fsys, &media)
+	if err != nil {
+		t.Fatalf("GenerateIFramePlaylist failed: %v", err)
+	}
+	if !iframes.IFramesOnly || iframes.Version != 5 || !iframes.EndList {
+		t.Errorf("Unexpected header: %+v", iframes)
+	}
+	if len(iframes.Segments) != 4 {
+		t.Fatalf("Expected 4 I-frames, got %d", len(iframes.Segments))
+	}
+
+	// Each frame is 3 packets after the PAT and PMT
+	const frameBytes = 3 * 188
+	for i, seg := range iframes.Segments {
+		if seg.Duration != 2 {
+			t.Errorf("I-frame %d: expected duration 2, got %v", i, seg.Duration)
+		}
+		wantOffset := int64(2*188 + (i%2)*50*frameBytes)
+		if seg.Byterange.Offset != wantOffset || seg.Byterange.Count != frameBytes {
+			t.Errorf("I-frame %d: unexpected byterange %s", i, seg.Byterange)
+		}
+	}
+	// The map covers the PAT and PMT at the start of each segment
+	for i, seg := range iframes.Segments {
+		m := seg.Map
+		if m == nil || m.URI != seg.URI || m.Byterange == nil || m.Byterange.Offset != 0 || m.Byterange.Count != 2*188 {
+			t.Errorf("I-frame %d: expected a map of the program tables of %s, got %v", i, seg.URI, m)
+		}
+	}
+	if iframes.Segments[2].URI != "video/seg1.ts" {
+		t.Errorf("Expected third I-frame in seg1.ts, got %s", iframes.Segments[2].URI)
+	}
+
+	out, err := iframes.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(out), "#EXT-X-I-FRAMES-ONLY\n") {
+		t.Errorf("Expected I-frames only tag:\n%s", out)
+	}
+	if !strings.Contains(string(out), `#EXT-X-MAP:URI="video/seg0.ts",BYTERANGE="376"`) {
+		t.Errorf("Expected a map of the program tables:\n%s", out)
+	}
+	if bw := IFrameBandwidth(iframes); bw != frameBytes*8/2 {
+		t.Errorf("Expected I-frame bandwidth %d, got %d", frameBytes*8/2, bw)
+	}
+}
+
+func TestGenerateIFramePlaylistFMP4(t *testing.T) {
+	gop := func() []mp4Sample {
+		samples := make([]mp4Sample, 48)
+		for i := range samples {
+			samples[i] = mp4Sample{duration: 512, size: 1000, sync: i == 0}
+		}
+		return samples
+	}
+	// One file with two fragments of two seconds each at 12288 Hz
+	first := buildFragment(1, 0, gop())
+	file := append(first, buildFragment(2, 48*512, gop())...)
+	init := buildInit(12288)
+
+	fsys := fstest.MapFS{
+		"init.mp4": {Data: init},
+		"main.mp4": {Data: file},
+	}
+
+	var media MediaPlaylist
+	err := media.Unmarshal([]byte(`#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:2.0,
+#EXT-X-BYTERANGE:` + strconv.Itoa(len(first)) + `@0
+main.mp4
+#EXTINF:2.0,
+#EXT-X-BYTERANGE:` + strconv.Itoa(len(file)-len(first)) + `
+main.mp4
+#EXT-X-ENDLIST
+`))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	iframes, err := GenerateIFramePlaylist(fsys, &media)
+	if err != nil {
+		t.Fatalf("GenerateIFramePlaylist failed: %v", err)
+	}
+	if iframes.Version != 5 || len(iframes.Segments) != 2 {
+		t.Fatalf("Expected 2 I-frames at version 5, got %d at %d", len(iframes.Segments), iframes.Version)
+	}
+
+	// An I-frame spans its moof, the mdat header and the first sample
+	moofSize := int64(len(first) - 8 - 48*1000)
+	for i, seg := range iframes.Segments {
+		want := Byterange{Count: moofSize + 8 + 1000, Offset: int64(i * len(first))}
+		if *seg.Byterange != want {
+			t.Errorf("I-frame %d: expected byterange %s, got %s", i, &want, seg.Byterange)
+		}
+		if seg.Duration != 2 || seg.Map == nil {
+			t.Errorf("I-frame %d: unexpected segment %+v", i, seg)
+		}
+	}
+}
+
+func TestGenerateIFramePlaylistErrors(t *testing.T) {
+	audioOnly := buildTS(nil)
+	fsys := fstest.MapFS{"a.ts": {Data: audioOnly}}
+
+	media := &MediaPlaylist{Segments: []*Segment{{URI: "a.ts", Duration: 4}}}
+	if _, err := GenerateIFramePlaylist(fsys, media); !errors.Is(err, ErrNoKeyframes) {
+		t.Errorf("Expected ErrNoKeyframes, got %v", err)
+	}
+
+	media.Segments[0].URI = "https://cdn.example.com/a.ts"
+	if _, err := GenerateIFramePlaylist(fsys, media); err == nil {
+		t.Error("Expected an error for a remote segment")
+	}
+
+	media.Segments[0].URI = "../a.ts"
+	if _, err := GenerateIFramePlaylist(fsys, media); err == nil {
+		t.Error("Expected an error for a segment outside the file system")
+	}
+}
@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package isobmff
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Handler types of a track
+const (
+	HandlerVideo = "vide"
+	HandlerAudio = "soun"
+)
+
+// nonSyncSample is the sample_is_non_sync_sample bit of sample flags
+const nonSyncSample = 0x10000
+
+// MaxSamples is the largest number of samples read from one track
+// fragment. It is far more than a segment holds, and keeps a trun box
+// without per-sample fields from claiming billions of samples.
+const MaxSamples = 1 << 20
+
+// Track describes a track of an initialization section
+type Track struct {
+	ID        uint32
+	Handler   string
+	Timescale uint32
+	// Defaults from the track extends box
+	DefaultDuration uint32
+	DefaultSize     uint32
+	DefaultFlags    uint32
+}
+
+// Sample is a sample of a movie fragment
+type Sample struct {
+	// Offset is the position of the sample data in the file
+	Offset int64
+	Size   uint32
+	// DecodeTime and Duration are in the track's timescale
+	DecodeTime        uint64
+	Duration          uint32
+	CompositionOffset int32
+	Sync              bool
+}
+
+// Fragment is a movie fragment: a moof box and the samples it describes
+// for one track
+type Fragment struct {
+	// Offset is the position of the moof box
+	Offset  int64
+	TrackID uint32
+	Samples []Sample
+}
+
+// ParseInit reads the tracks of an initialization section
+func ParseInit(data []byte) ([]*Track, error) {
+	boxes, err := ReadBoxes(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	moov, ok := Find(boxes, "moov")
+	if !ok {
+		return nil, errors.New("isobmff: no moov box")
+	}
+	children, err := moov.Children()
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []*Track
+	byID := make(map[uint32]*Track)
+	for _, box := range children {
+		if box.Type != "trak" {
+			continue
+		}
+		trak, err := box.Children()
+		if err != nil {
+			return nil, err
+		}
+		t := &Track{}
+		if tkhd, ok := Find(trak, "tkhd"); ok {
+			version, _, rest, _ := fullBox(tkhd.Payload)
+			c := cursor{b: rest}
+			if version == 1 {
+				c.skip(16)
+			} else {
+				c.skip(8)
+			}
+			t.ID = c.u32()
+		}
+		if hdlr, ok := FindPath(trak, "mdia", "hdlr"); ok && len(hdlr.Payload) >= 12 {
+			t.Handler = string(hdlr.Payload[8:12])
+		}
+		if mdhd, ok := FindPath(trak, "mdia", "mdhd"); ok {
+			version, _, rest, _ := fullBox(mdhd.Payload)
+			c := cursor{b: rest}
+			if version == 1 {
+				c.skip(16)
+			} else {
+				c.skip(8)
+			}
+			t.Timescale = c.u32()
+		}
+		tracks = append(tracks, t)
+		byID[t.ID] = t
+	}
+
+	if mvex, ok := Find(children, "mvex"); ok {
+		extends, err := mvex.Children()
+		if err != nil {
+			return nil, err
+		}
+		for _, box := range extends {
+			if box.Type != "trex" {
+				continue
+			}
+			_, _, rest, _ := fullBox(box.Payload)
+			c := cursor{b: rest}
+			id := c.u32()
+			c.skip(4) // default_sample_description_index
+			duration, size, flags := c.u32(), c.u32(), c.u32()
+			if t := byID[id]; t != nil && !c.bad {
+				t.DefaultDuration, t.DefaultSize, t.DefaultFlags = duration, size, flags
+			}
+		}
+	}
+	return tracks, nil
+}
+
+// ParseFragments reads the movie fragments in data for track t. offset is
+// the position of data in its file.
+func ParseFragments(data []byte, offset int64, t *Track) ([]*Fragment, error) {
+	boxes, err := ReadBoxes(data, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var fragments []*Fragment
+	for _, moof := range boxes {
+		if moof.Type != "moof" {
+			continue
+		}
+		f, err := MovieFragment(moof, t)
+		if err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, f...)
+	}
+	return fragments, nil
+}
+
+// MovieFragment reads the fragments of track t in a moof box
+func MovieFragment(moof Box, t *Track) ([]*Fragment, error) {
+	children, err := moof.Children()
+	if err != nil {
+		return nil, err
+	}
+	var fragments []*Fragment
+	for _, traf := range children {
+		if traf.Type != "traf" {
+			continue
+		}
+		f, err := parseTrackFragment(traf, moof.Offset, t)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			fragments = append(fragments, f)
+		}
+	}
+	return fragments, nil
+}
+
+// Duration returns the total duration of the fragment's samples
+func (f *Fragment) Duration() uint64 {
+	var d uint64
+	for _, s := range f.Samples {
+		d += uint64(s.Duration)
+	}
+	return d
+}
+
+// parseTrackFragment reads a traf box, returning nil when it belongs to
+// another track
+func parseTrackFragment(traf Box, moofOffset int64, t *Track) (*Fragment, error) {
+	boxes, err := traf.Children()
+	if err != nil {
+		return nil, err
+	}
+	tfhd, ok := Find(boxes, "tfhd")
+	if !ok {
+		return nil, fmt.Errorf("isobmff: traf at byte %d has no tfhd", traf.Offset)
+	}
+
+	_, flags, rest, _ := fullBox(tfhd.Payload)
+	c := cursor{b: rest}
+	if id := c.u32(); id != t.ID {
+		return nil, nil
+	}
+	base := moofOffset
+	duration, size, sampleFlags := t.DefaultDuration, t.DefaultSize, t.DefaultFlags
+	if flags&0x01 != 0 {
+		base = int64(c.u64())
+	}
+	if flags&0x02 != 0 {
+		c.skip(4) // sample_description_index
+	}
+	if flags&0x08 != 0 {
+		duration = c.u32()
+	}
+	if flags&0x10 != 0 {
+		size = c.u32()
+	}
+	if flags&0x20 != 0 {
+		sampleFlags = c.u32()
+	}
+	if c.bad {
+		return nil, fmt.Errorf("%w: tfhd at byte %d", ErrTruncated, tfhd.Offset)
+	}
+
+	var decodeTime uint64
+	if tfdt, ok := Find(boxes, "tfdt"); ok {
+		version, _, rest, _ := fullBox(tfdt.Payload)
+		c := cursor{b: rest}
+		if version == 1 {
+			decodeTime = c.u64()
+		} else {
+			decodeTime = uint64(c.u32())
+		}
+	}
+
+	f := &Fragment{Offset: moofOffset, TrackID: t.ID}
+	dataOffset := base
+	for _, trun := range boxes {
+		if trun.Type != "trun" {
+			continue
+		}
+		version, flags, rest, _ := fullBox(trun.Payload)
+		c := cursor{b: rest}
+		count := c.u32()
+		if flags&0x01 != 0 {
+			dataOffset = base + int64(int32(c.u32()))
+		}
+		firstFlags, hasFirstFlags := uint32(0), flags&0x04 != 0
+		if hasFirstFlags {
+			firstFlags = c.u32()
+		}
+		perSample := 0
+		for _, bit := range []uint32{0x100, 0x200, 0x400, 0x800} {
+			if flags&bit != 0 {
+				perSample += 4
+			}
+		}
+		if c.bad || uint64(count)*uint64(perSample) > uint64(len(c.b)) {
+			return nil, fmt.Errorf("%w: trun at byte %d", ErrTruncated, trun.Offset)
+		}
+		if uint64(len(f.Samples))+uint64(count) > MaxSamples {
+			return nil, fmt.Errorf("isobmff: trun at byte %d has %d samples, more than %d", trun.Offset, count, MaxSamples)
+		}
+		for i := uint32(0); i < count && !c.bad; i++ {
+			s := Sample{
+				Offset:     dataOffset,
+				DecodeTime: decodeTime,
+				Duration:   duration,
+				Size:       size,
+			}
+			sf := sampleFlags
+			if flags&0x100 != 0 {
+				s.Duration = c.u32()
+			}
+			if flags&0x200 != 0 {
+				s.Size = c.u32()
+			}
+			if flags&0x400 != 0 {
+				sf = c.u32()
+			} else if i == 0 && hasFirstFlags {
+				sf = firstFlags
+			}
+			if flags&0x800 != 0 {
+				cts := c.u32()
+				if version == 0 {
+					s.CompositionOffset = int32(min(cts, 1<<31-1))
+				} else {
+					s.CompositionOffset = int32(cts)
+				}
+			}
+			s.Sync = sf&nonSyncSample == 0
+			f.Samples = append(f.Samples, s)
+			dataOffset += int64(s.Size)
+			decodeTime += uint64(s.Duration)
+		}
+		if c.bad {
+			return nil, fmt.Errorf("%w: trun at byte %d", ErrTruncated, trun.Offset)
+		}
+	}
+	return f, nil
+}
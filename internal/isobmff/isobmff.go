@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+// Package isobmff reads the boxes of fragmented MP4 files needed to index
+// HLS segments: track setup from the initialization section and sample
+// tables from movie fragments.
+package isobmff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTruncated is returned when a box extends past the end of the data
+var ErrTruncated = errors.New("isobmff: truncated box")
+
+// Box is a box header with its payload
+type Box struct {
+	Type string
+	// Offset is the position of the box header and Size the size of the
+	// whole box, header included
+	Offset int64
+	Size   int64
+	// Payload is the box content after the header. Boxes from ScanBoxes
+	// have no payload until loaded.
+	Payload []byte
+
+	header int
+}
+
+// End returns the position just past the box
+func (b Box) End() int64 {
+	return b.Offset + b.Size
+}
+
+// ReadBoxes splits data into the boxes it contains. offset is the position
+// of data in its file and is added to the box offsets.
+func ReadBoxes(data []byte, offset int64) ([]Box, error) {
+	var boxes []Box
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 8 {
+			return boxes, fmt.Errorf("%w at byte %d", ErrTruncated, offset+int64(pos))
+		}
+		size := int64(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		header := 8
+		switch size {
+		case 0:
+			size = int64(len(data) - pos)
+		case 1:
+			if len(data)-pos < 16 {
+				return boxes, fmt.Errorf("%w at byte %d", ErrTruncated, offset+int64(pos))
+			}
+			size = int64(binary.BigEndian.Uint64(data[pos+8:]))
+			header = 16
+		}
+		if size < int64(header) || size > int64(len(data)-pos) {
+			return boxes, fmt.Errorf("%w: %s at byte %d", ErrTruncated, typ, offset+int64(pos))
+		}
+		boxes = append(boxes, Box{
+			Type:    typ,
+			Offset:  offset + int64(pos),
+			Size:    size,
+			Payload: data[pos+header : pos+int(size)],
+			header:  header,
+		})
+		pos += int(size)
+	}
+	return boxes, nil
+}
+
+// ScanBoxes reads the headers of the top-level boxes of a file of the given
+// size without reading their payloads
+func ScanBoxes(r io.ReaderAt, size int64) ([]Box, error) {
+	var boxes []Box
+	var buf [16]byte
+	for pos := int64(0); pos < size; {
+		if size-pos < 8 {
+			return boxes, fmt.Errorf("%w at byte %d", ErrTruncated, pos)
+		}
+		if _, err := r.ReadAt(buf[:8], pos); err != nil {
+			return boxes, err
+		}
+		box := Box{
+			Type:   string(buf[4:8]),
+			Offset: pos,
+			Size:   int64(binary.BigEndian.Uint32(buf[:])),
+			header: 8,
+		}
+		switch box.Size {
+		case 0:
+			box.Size = size - pos
+		case 1:
+			if _, err := r.ReadAt(buf[8:16], pos+8); err != nil {
+				return boxes, err
+			}
+			box.Size = int64(binary.BigEndian.Uint64(buf[8:]))
+			box.header = 16
+		}
+		if box.Size < int64(box.header) || box.Size > size-pos {
+			return boxes, fmt.Errorf("%w: %s at byte %d", ErrTruncated, box.Type, pos)
+		}
+		boxes = append(boxes, box)
+		pos += box.Size
+	}
+	return boxes, nil
+}
+
+// Load reads the payload of a box found by ScanBoxes
+func (b Box) Load(r io.ReaderAt) (Box, error) {
+	payload := make([]byte, b.Size-int64(b.header))
+	if _, err := r.ReadAt(payload, b.Offset+int64(b.header)); err != nil {
+		return b, fmt.Errorf("isobmff: reading %s at byte %d: %w", b.Type, b.Offset, err)
+	}
+	b.Payload = payload
+	return b, nil
+}
+
+// Children returns the boxes nested in b
+func (b Box) Children() ([]Box, error) {
+	return ReadBoxes(b.Payload, b.End()-int64(len(b.Payload)))
+}
+
+// Find returns the first box of the given type
+func Find(boxes []Box, typ string) (Box, bool) {
+	for _, b := range boxes {
+		if b.Type == typ {
+			return b, true
+		}
+	}
+	return Box{}, false
+}
+
+// FindPath descends through nested boxes, returning the first box at the
+// end of path
+func FindPath(boxes []Box, path ...string) (Box, bool) {
+	var box Box
+	for i, typ := range path {
+		var ok bool
+		if box, ok = Find(boxes, typ); !ok {
+			return Box{}, false
+		}
+		if i < len(path)-1 {
+			var err error
+			if boxes, err = box.Children(); err != nil {
+				return Box{}, false
+			}
+		}
+	}
+	return box, true
+}
+
+// fullBox returns the version, flags and remaining payload of a full box
+func fullBox(b []byte) (version uint8, flags uint32, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return 0, 0, nil, false
+	}
+	return b[0], uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]), b[4:], true
+}
+
+// cursor reads big-endian fields, remembering whether it ran out of data
+type cursor struct {
+	b   []byte
+	bad bool
+}
+
+func (c *cursor) u32() uint32 {
+	if len(c.b) < 4 {
+		c.bad = true
+		return 0
+	}
+	v := binary.BigEndian.Uint32(c.b)
+	c.b = c.b[4:]
+	return v
+}
+
+func (c *cursor) u64() uint64 {
+	if len(c.b) < 8 {
+		c.bad = true
+		return 0
+	}
+	v := binary.BigEndian.Uint64(c.b)
+	c.b = c.b[8:]
+	return v
+}
+
+func (c *cursor) skip(n int) {
+	if len(c.b) < n {
+		c.bad = true
+		c.b = nil
+		return
+	}
+	c.b = c.b[n:]
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package isobmff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// box builds a box of the given type around the concatenated payloads
+func box(typ string, payloads ...[]byte) []byte {
+	body := bytes.Join(payloads, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	b = append(b, typ...)
+	return append(b, body...)
+}
+
+// fullBoxHeader returns the version and flags of a full box
+func fullBoxHeader(version uint8, flags uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(version)<<24|flags)
+}
+
+func u32s(vs ...uint32) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+// testInit builds an initialization section with one video track
+func testInit(timescale uint32) []byte {
+	tkhd := box("tkhd", fullBoxHeader(0, 0), u32s(0, 0, 1))
+	mdhd := box("mdhd", fullBoxHeader(0, 0), u32s(0, 0, timescale))
+	hdlr := box("hdlr", fullBoxHeader(0, 0), u32s(0), []byte(HandlerVideo))
+	trex := box("trex", fullBoxHeader(0, 0), u32s(1, 1, 3000, 100, nonSyncSample))
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("iso6"), u32s(0)),
+		box("moov",
+			box("trak", tkhd, box("mdia", mdhd, hdlr)),
+			box("mvex", trex)),
+	}, nil)
+}
+
+// testFragment builds a moof for track 1 around the given trun
+func testFragment(decodeTime uint32, trun []byte) []byte {
+	return box("moof",
+		box("mfhd", fullBoxHeader(0, 0), u32s(1)),
+		box("traf",
+			box("tfhd", fullBoxHeader(0, 0x020000), u32s(1)),
+			box("tfdt", fullBoxHeader(0, 0), u32s(decodeTime)),
+			trun))
+}
+
+func TestReadBoxes(t *testing.T) {
+	data := append(box("ftyp", []byte("iso6")), box("free")...)
+	boxes, err := ReadBoxes(data, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 2 || boxes[0].Type != "ftyp" || boxes[1].Type != "free" {
+		t.Fatalf("boxes = %+v", boxes)
+	}
+	if boxes[1].Offset != 112 || boxes[1].End() != 120 {
+		t.Errorf("free at %d-%d, want 112-120", boxes[1].Offset, boxes[1].End())
+	}
+
+	for _, bad := range [][]byte{
+		data[:len(data)-1],
+		data[:len(data)-5],
+		append(u32s(4), "free"...),
+	} {
+		if _, err := ReadBoxes(bad, 0); !errors.Is(err, ErrTruncated) {
+			t.Errorf("ReadBoxes(% x) error = %v, want ErrTruncated", bad, err)
+		}
+	}
+}
+
+func TestParseInit(t *testing.T) {
+	tracks, err := ParseInit(testInit(90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 {
+		t.Fatalf("got %d tracks", len(tracks))
+	}
+	want := Track{ID: 1, Handler: HandlerVideo, Timescale: 90000, DefaultDuration: 3000, DefaultSize: 100, DefaultFlags: nonSyncSample}
+	if *tracks[0] != want {
+		t.Errorf("track = %+v, want %+v", *tracks[0], want)
+	}
+
+	if _, err := ParseInit(box("ftyp", []byte("iso6"))); err == nil {
+		t.Error("ParseInit without moov succeeded")
+	}
+}
+
+func TestParseFragments(t *testing.T) {
+	tracks, err := ParseInit(testInit(90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sample sizes with the first sample flagged as sync
+	trun := box("trun", fullBoxHeader(0, 0x204), u32s(3, 0, 10, 20, 30))
+	data := testFragment(9000, trun)
+	fragments, err := ParseFragments(data, 0, tracks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fragments) != 1 {
+		t.Fatalf("got %d fragments", len(fragments))
+	}
+	f := fragments[0]
+	if f.Duration() != 9000 {
+		t.Errorf("duration = %d, want 9000", f.Duration())
+	}
+	var sizes []uint32
+	var syncs []bool
+	for _, s := range f.Samples {
+		sizes = append(sizes, s.Size)
+		syncs = append(syncs, s.Sync)
+	}
+	if len(f.Samples) != 3 || f.Samples[2].DecodeTime != 15000 ||
+		sizes[0] != 10 || sizes[2] != 30 || !syncs[0] || syncs[1] || syncs[2] {
+		t.Errorf("samples = %+v", f.Samples)
+	}
+}
+
+func TestParseFragmentsSampleCount(t *testing.T) {
+	tracks, err := ParseInit(testInit(90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, trun := range map[string][]byte{
+		// Sizes for 2^32-1 samples in a box holding one
+		"per-sample": box("trun", fullBoxHeader(0, 0x200), u32s(0xffffffff, 10)),
+		// No per-sample fields, every sample taking the defaults
+		"defaults": box("trun", fullBoxHeader(0, 0), u32s(0xffffffff)),
+	} {
+		if _, err := ParseFragments(testFragment(0, trun), 0, tracks[0]); err == nil {
+			t.Errorf("%s: ParseFragments accepted %d samples", name, uint32(0xffffffff))
+		}
+	}
+}
+
+func TestParseSegmentIndex(t *testing.T) {
+	sidx := box("sidx", fullBoxHeader(0, 0),
+		u32s(1, 90000, 0, 0, 2, 1000, 180000, 0x90000000, 500, 90000, 0))
+	boxes, err := ReadBoxes(sidx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := ParseSegmentIndex(boxes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.References) != 2 {
+		t.Fatalf("got %d references", len(idx.References))
+	}
+	first, second := idx.References[0], idx.References[1]
+	if first.Offset != int64(len(sidx)) || first.Size != 1000 || !first.StartsWithSAP {
+		t.Errorf("first reference = %+v", first)
+	}
+	if second.Offset != first.Offset+1000 || second.Duration != 90000 || second.StartsWithSAP {
+		t.Errorf("second reference = %+v", second)
+	}
+}
+
+func FuzzParseFragments(f *testing.F) {
+	f.Add(testFragment(0, box("trun", fullBoxHeader(0, 0x204), u32s(3, 0, 10, 20, 30))))
+	f.Add(testFragment(0, box("trun", fullBoxHeader(0, 0), u32s(0xffffffff))))
+	track := &Track{ID: 1, Timescale: 90000, DefaultDuration: 3000}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseFragments(data, 0, track)
+	})
+}
+
+func FuzzParseInit(f *testing.F) {
+	f.Add(testInit(90000))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseInit(data)
+	})
+}
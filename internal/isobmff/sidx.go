@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package isobmff
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIndirectIndex is returned for a segment index that refers to other
+// segment indexes instead of media
+var ErrIndirectIndex = errors.New("isobmff: hierarchical sidx is not supported")
+
+// SegmentIndex is a sidx box
+type SegmentIndex struct {
+	ReferenceID uint32
+	Timescale   uint32
+	// EarliestPresentationTime is in the index's timescale
+	EarliestPresentationTime uint64
+	References               []Reference
+}
+
+// Reference is a subsegment listed in a segment index
+type Reference struct {
+	// Offset is the position of the subsegment in the file
+	Offset int64
+	Size   uint32
+	// Duration is in the index's timescale
+	Duration      uint32
+	StartsWithSAP bool
+	SAPType       uint8
+	SAPDeltaTime  uint32
+}
+
+// ParseSegmentIndex reads a sidx box. Subsegment offsets are resolved from
+// the end of the box.
+func ParseSegmentIndex(sidx Box) (*SegmentIndex, error) {
+	version, _, rest, ok := fullBox(sidx.Payload)
+	if !ok {
+		return nil, fmt.Errorf("%w: sidx at byte %d", ErrTruncated, sidx.Offset)
+	}
+	c := cursor{b: rest}
+	idx := &SegmentIndex{ReferenceID: c.u32(), Timescale: c.u32()}
+	var firstOffset uint64
+	if version == 0 {
+		idx.EarliestPresentationTime = uint64(c.u32())
+		firstOffset = uint64(c.u32())
+	} else {
+		idx.EarliestPresentationTime = c.u64()
+		firstOffset = c.u64()
+	}
+	count := c.u32() & 0xffff // reserved(16), reference_count(16)
+
+	offset := sidx.End() + int64(firstOffset)
+	for i := uint32(0); i < count && !c.bad; i++ {
+		typeSize, duration, sap := c.u32(), c.u32(), c.u32()
+		if typeSize&0x80000000 != 0 {
+			return nil, ErrIndirectIndex
+		}
+		ref := Reference{
+			Offset:        offset,
+			Size:          typeSize & 0x7fffffff,
+			Duration:      duration,
+			StartsWithSAP: sap&0x80000000 != 0,
+			SAPType:       uint8(sap >> 28 & 0x7),
+			SAPDeltaTime:  sap & 0x0fffffff,
+		}
+		idx.References = append(idx.References, ref)
+		offset += int64(ref.Size)
+	}
+	if c.bad {
+		return nil, fmt.Errorf("%w: sidx at byte %d", ErrTruncated, sidx.Offset)
+	}
+	return idx, nil
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+// Package metadata reads OTIO metadata values, which may be Go literals or
+// decoded JSON.
+package metadata
+
+import "github.com/Avalanche-io/gotio"
+
+// AsMap returns a metadata dictionary from a Go literal or decoded JSON
+func AsMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case gotio.AnyDictionary:
+		return map[string]interface{}(m), true
+	}
+	return nil, false
+}
+
+// AsInt64 returns a numeric metadata value as an integer
+func AsInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	case float32:
+		return int64(n), true
+	}
+	return 0, false
+}
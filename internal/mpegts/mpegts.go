@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+// Package mpegts reads the parts of MPEG transport streams needed to index
+// HLS segments: program tables, PES packets and their timestamps.
+package mpegts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// PacketSize is the size of a transport stream packet
+const PacketSize = 188
+
+const syncByte = 0x47
+
+// Stream types from the program map table
+const (
+	StreamTypeMPEG1Audio = 0x03
+	StreamTypeMPEG2Audio = 0x04
+	StreamTypeAAC        = 0x0F
+	StreamTypeH264       = 0x1B
+	StreamTypeH265       = 0x24
+	StreamTypeAC3        = 0x81
+	StreamTypeEAC3       = 0x87
+)
+
+// NoTimestamp marks a PES packet without a PTS or DTS
+const NoTimestamp = -1
+
+// ErrSync is returned when data does not start with a sync byte where a
+// packet is expected
+var ErrSync = errors.New("mpegts: lost sync")
+
+// PES is a packetized elementary stream packet reassembled from transport
+// stream packets
+type PES struct {
+	PID        uint16
+	StreamType uint8
+	// Offset is the position of the first transport packet carrying the
+	// PES and End the position just past the last one
+	Offset, End int64
+	// PTS and DTS are in 90 kHz units, or NoTimestamp. DTS equals PTS
+	// when the stream does not carry it.
+	PTS, DTS int64
+	// Payload is the elementary stream data after the PES header
+	Payload []byte
+}
+
+// IsVideo reports whether the PES carries H.264 or H.265 video
+func (p *PES) IsVideo() bool {
+	return p.StreamType == StreamTypeH264 || p.StreamType == StreamTypeH265
+}
+
+// IsKeyframe reports whether the PES carries a random access point: an IDR
+// picture for H.264 or an IRAP picture for H.265
+func (p *PES) IsKeyframe() bool {
+	switch p.StreamType {
+	case StreamTypeH264:
+		return hasNAL(p.Payload, func(b byte) bool { return b&0x1f == 5 })
+	case StreamTypeH265:
+		return hasNAL(p.Payload, func(b byte) bool {
+			t := (b >> 1) & 0x3f
+			return t >= 16 && t <= 21
+		})
+	}
+	return false
+}
+
+// hasNAL reports whether any NAL unit header in an Annex B byte stream
+// matches
+func hasNAL(data []byte, match func(header byte) bool) bool {
+	for i := 0; i+3 < len(data); i++ {
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 1 {
+			if match(data[i+3]) {
+				return true
+			}
+			i += 2
+		}
+	}
+	return false
+}
+
+// ReadPES walks the transport stream in data and calls fn with every PES
+// packet of the elementary streams listed in the program map table as
+// each one completes. offset is added to the positions reported so they
+// can refer to a larger file.
+func ReadPES(data []byte, offset int64, fn func(*PES) error) error {
+	r := reader{
+		streams: make(map[uint16]uint8),
+		pmtPIDs: make(map[uint16]bool),
+		pending: make(map[uint16]*PES),
+	}
+	emit := func(p *PES) error {
+		parsePESHeader(p)
+		return fn(p)
+	}
+
+	for pos := 0; pos+PacketSize <= len(data); pos += PacketSize {
+		pkt := data[pos : pos+PacketSize]
+		if pkt[0] != syncByte {
+			return fmt.Errorf("%w at byte %d", ErrSync, offset+int64(pos))
+		}
+
+		start := pkt[1]&0x40 != 0
+		pid := uint16(pkt[1]&0x1f)<<8 | uint16(pkt[2])
+		payload := packetPayload(pkt)
+		if payload == nil {
+			continue
+		}
+
+		switch {
+		case pid == 0:
+			if start {
+				r.parsePAT(payload)
+			}
+		case r.pmtPIDs[pid]:
+			if start {
+				r.parsePMT(payload)
+			}
+		default:
+			streamType, ok := r.streams[pid]
+			if !ok {
+				continue
+			}
+			if start {
+				if p := r.pending[pid]; p != nil {
+					if err := emit(p); err != nil {
+						return err
+					}
+				}
+				r.pending[pid] = &PES{PID: pid, StreamType: streamType, Offset: offset + int64(pos)}
+			}
+			if p := r.pending[pid]; p != nil {
+				p.Payload = append(p.Payload, payload...)
+				p.End = offset + int64(pos+PacketSize)
+			}
+		}
+	}
+
+	// Packets still open at the end of the data, in order of appearance
+	open := make([]*PES, 0, len(r.pending))
+	for _, p := range r.pending {
+		open = append(open, p)
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].Offset < open[j].Offset })
+	for _, p := range open {
+		if err := emit(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tables returns the position of the transport packets from the first
+// program association table up to and including the first program map
+// table it lists, which a decoder needs before any PES packet. offset is
+// added to the positions reported. ok is false when data holds no such pair.
+func Tables(data []byte, offset int64) (start, end int64, ok bool) {
+	r := reader{pmtPIDs: make(map[uint16]bool)}
+	pat := -1
+	for pos := 0; pos+PacketSize <= len(data); pos += PacketSize {
+		pkt := data[pos : pos+PacketSize]
+		if pkt[0] != syncByte {
+			return 0, 0, false
+		}
+		if pkt[1]&0x40 == 0 {
+			continue
+		}
+		pid := uint16(pkt[1]&0x1f)<<8 | uint16(pkt[2])
+		payload := packetPayload(pkt)
+		if payload == nil {
+			continue
+		}
+		switch {
+		case pid == 0 && pat < 0:
+			r.parsePAT(payload)
+			if len(r.pmtPIDs) > 0 {
+				pat = pos
+			}
+		case pat >= 0 && r.pmtPIDs[pid]:
+			return offset + int64(pat), offset + int64(pos+PacketSize), true
+		}
+	}
+	return 0, 0, false
+}
+
+type reader struct {
+	streams map[uint16]uint8
+	pmtPIDs map[uint16]bool
+	pending map[uint16]*PES
+}
+
+// packetPayload returns the payload of a packet, or nil if it has none
+func packetPayload(pkt []byte) []byte {
+	control := (pkt[3] >> 4) & 0x3
+	if control&0x1 == 0 {
+		return nil
+	}
+	start := 4
+	if control&0x2 != 0 {
+		start += 1 + int(pkt[4])
+	}
+	if start >= PacketSize {
+		return nil
+	}
+	return pkt[start:]
+}
+
+// section returns the table section following the pointer field
+func section(payload []byte, tableID byte) []byte {
+	if len(payload) < 1 {
+		return nil
+	}
+	pointer := int(payload[0])
+	if 1+pointer+3 > len(payload) {
+		return nil
+	}
+	s := payload[1+pointer:]
+	if s[0] != tableID {
+		return nil
+	}
+	length := int(s[1]&0x0f)<<8 | int(s[2])
+	if 3+length > len(s) || length < 4 {
+		return nil
+	}
+	// Drop the trailing CRC
+	return s[:3+length-4]
+}
+
+func (r *reader) parsePAT(payload []byte) {
+	s := section(payload, 0x00)
+	if len(s) < 8 {
+		return
+	}
+	for i := 8; i+4 <= len(s); i += 4 {
+		program := uint16(s[i])<<8 | uint16(s[i+1])
+		pid := uint16(s[i+2]&0x1f)<<8 | uint16(s[i+3])
+		if program != 0 {
+			r.pmtPIDs[pid] = true
+		}
+	}
+}
+
+func (r *reader) parsePMT(payload []byte) {
+	s := section(payload, 0x02)
+	if len(s) < 12 {
+		return
+	}
+	infoLength := int(s[10]&0x0f)<<8 | int(s[11])
+	for i := 12 + infoLength; i+5 <= len(s); {
+		streamType := s[i]
+		pid := uint16(s[i+1]&0x1f)<<8 | uint16(s[i+2])
+		esInfoLength := int(s[i+3]&0x0f)<<8 | int(s[i+4])
+		r.streams[pid] = streamType
+		i += 5 + esInfoLength
+	}
+}
+
+// parsePESHeader reads the timestamps and strips the header from the
+// payload of p
+func parsePESHeader(p *PES) {
+	p.PTS, p.DTS = NoTimestamp, NoTimestamp
+	b := p.Payload
+	if len(b) < 9 || b[0] != 0 || b[1] != 0 || b[2] != 1 {
+		return
+	}
+	headerLength := int(b[8])
+	if 9+headerLength > len(b) {
+		return
+	}
+	flags := b[7] >> 6
+	if flags&0x2 != 0 && headerLength >= 5 {
+		p.PTS = Timestamp(b[9:14])
+		p.DTS = p.PTS
+	}
+	if flags == 0x3 && headerLength >= 10 {
+		p.DTS = Timestamp(b[14:19])
+	}
+	p.Payload = b[9+headerLength:]
+}
+
+// Timestamp decodes a 33-bit PES timestamp from its 5 byte form
+func Timestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 |
+		int64(b[1])<<22 |
+		int64(b[2]>>1)<<15 |
+		int64(b[3])<<7 |
+		int64(b[4]>>1)
+}
+
+// TimestampDiff returns b - a for 33-bit timestamps, allowing for one
+// wrap of the counter between them
+func TimestampDiff(a, b int64) int64 {
+	const wrap = 1 << 33
+	d := b - a
+	if d < -wrap/2 {
+		d += wrap
+	} else if d > wrap/2 {
+		d -= wrap
+	}
+	return d
+}
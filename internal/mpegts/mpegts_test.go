@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package mpegts
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+const (
+	pmtPID   = 0x1000
+	videoPID = 0x100
+)
+
+// packet builds a transport packet, padding the payload with an adaptation
+// field
+func packet(pid uint16, start bool, payload []byte) []byte {
+	pkt := []byte{syncByte, byte(pid >> 8 & 0x1f), byte(pid), 0x10}
+	if start {
+		pkt[1] |= 0x40
+	}
+	if pad := PacketSize - 4 - len(payload); pad > 0 {
+		pkt[3] = 0x30
+		field := make([]byte, pad)
+		field[0] = byte(pad - 1)
+		for i := 2; i < pad; i++ {
+			field[i] = 0xff
+		}
+		pkt = append(pkt, field...)
+	}
+	return append(pkt, payload...)
+}
+
+// table builds a section with a pointer field and a dummy CRC
+func table(id byte, body []byte) []byte {
+	length := len(body) + 5 + 4
+	s := []byte{0, id, 0xb0 | byte(length>>8), byte(length), 0, 1, 0xc1, 0, 0}
+	s = append(s, body...)
+	return append(s, 0, 0, 0, 0)
+}
+
+func pat() []byte {
+	return packet(0, true, table(0x00, []byte{0, 1, 0xe0 | pmtPID>>8, pmtPID & 0xff}))
+}
+
+func pmt() []byte {
+	return packet(pmtPID, true, table(0x02, []byte{
+		0xe0 | videoPID>>8, videoPID & 0xff, 0xf0, 0, // PCR PID, program info
+		StreamTypeH264, 0xe0 | videoPID>>8, videoPID & 0xff, 0xf0, 0,
+	}))
+}
+
+// timestamp encodes a 33-bit PES timestamp with the given prefix bits
+func timestamp(prefix byte, ts int64) []byte {
+	return []byte{
+		prefix<<4 | byte(ts>>29&0x0e) | 1,
+		byte(ts >> 22),
+		byte(ts>>14) | 1,
+		byte(ts >> 7),
+		byte(ts<<1) | 1,
+	}
+}
+
+// pes builds the start of a video PES with a PTS and the given payload
+func pes(pts int64, payload []byte) []byte {
+	b := []byte{0, 0, 1, 0xe0, 0, 0, 0x80, 0x80, 5}
+	b = append(b, timestamp(0x2, pts)...)
+	return append(b, payload...)
+}
+
+func TestTables(t *testing.T) {
+	data := bytes.Join([][]byte{
+		packet(videoPID, true, pes(0, nil)),
+		pat(),
+		pmt(),
+	}, nil)
+	start, end, ok := Tables(data, 1000)
+	if !ok || start != 1000+PacketSize || end != 1000+3*PacketSize {
+		t.Errorf("Tables = %d, %d, %v, want %d, %d, true", start, end, ok, 1000+PacketSize, 1000+3*PacketSize)
+	}
+	if _, _, ok := Tables(pmt(), 0); ok {
+		t.Error("Tables found a PMT without a PAT")
+	}
+}
+
+func TestReadPES(t *testing.T) {
+	idr := []byte{0, 0, 1, 0x65, 0xaa}
+	slice := []byte{0, 0, 1, 0x41, 0xbb}
+	data := bytes.Join([][]byte{
+		pat(),
+		pmt(),
+		packet(videoPID, true, pes(1<<33-1, idr)),
+		packet(videoPID, false, []byte{0xcc}),
+		packet(videoPID, true, pes(3000, slice)),
+	}, nil)
+
+	var got []*PES
+	err := ReadPES(data, 0, func(p *PES) error {
+		got = append(got, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d PES packets", len(got))
+	}
+	first, second := got[0], got[1]
+	if first.PTS != 1<<33-1 || first.DTS != first.PTS || !first.IsVideo() || !first.IsKeyframe() {
+		t.Errorf("first PES = %+v", first)
+	}
+	if !bytes.Equal(first.Payload, append(idr, 0xcc)) {
+		t.Errorf("first payload = % x", first.Payload)
+	}
+	if first.Offset != 2*PacketSize || first.End != 4*PacketSize {
+		t.Errorf("first PES at %d-%d", first.Offset, first.End)
+	}
+	if second.PTS != 3000 || second.IsKeyframe() {
+		t.Errorf("second PES = %+v", second)
+	}
+	if d := TimestampDiff(first.PTS, second.PTS); d != 3001 {
+		t.Errorf("TimestampDiff across the wrap = %d, want 3001", d)
+	}
+
+	data[PacketSize] = 0
+	if err := ReadPES(data, 0, func(*PES) error { return nil }); !errors.Is(err, ErrSync) {
+		t.Errorf("ReadPES without sync error = %v, want ErrSync", err)
+	}
+}
+
+func FuzzReadPES(f *testing.F) {
+	f.Add(bytes.Join([][]byte{pat(), pmt(), packet(videoPID, true, pes(0, []byte{0, 0, 1, 0x65}))}, nil))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ReadPES(data, 0, func(p *PES) error {
+			p.IsKeyframe()
+			return nil
+		})
+		Tables(data, 0)
+	})
+}
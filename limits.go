@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrLimitExceeded is matched by every LimitError via errors.Is
+var ErrLimitExceeded = errors.New("decoder limit exceeded")
+
+// LimitKind identifies which decoder limit was exceeded
+type LimitKind string
+
+const (
+	LimitLineLength LimitKind = "line length"
+	LimitSegments   LimitKind = "segment count"
+	LimitAttributes LimitKind = "attribute count"
+	LimitBytes      LimitKind = "playlist size"
+	LimitNesting    LimitKind = "nesting depth"
+)
+
+// Limits bounds the resources a Decoder will spend on a single playlist.
+// A zero or negative field disables the corresponding check.
+type Limits struct {
+	// MaxLineLength is the longest line accepted, in bytes
+	MaxLineLength int
+	// MaxSegments is the largest number of media segments accepted
+	MaxSegments int
+	// MaxAttributes is the largest number of attributes in one attribute list
+	MaxAttributes int
+	// MaxBytes is the largest total playlist size accepted, in bytes
+	MaxBytes int64
+	// MaxNesting is the longest chain of playlist references a Resolver
+	// follows from the playlist it starts at
+	MaxNesting int
+}
+
+// DefaultLimits returns the limits used by NewDecoder. They are generous
+// enough for long live windows but stop pathological input early.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxLineLength: 1 << 20,
+		MaxSegments:   500000,
+		MaxAttributes: 256,
+		MaxBytes:      256 << 20,
+		MaxNesting:    4,
+	}
+}
+
+// LimitError reports that a playlist exceeded one of the decoder limits
+type LimitError struct {
+	Kind LimitKind
+	Max  int64
+	// Line is the 1-based playlist line where the limit was hit, or 0
+	// when the limit is not tied to a single line
+	Line int
+}
+
+func (e *LimitError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d exceeds maximum %s of %d", e.Line, e.Kind, e.Max)
+	}
+	return fmt.Sprintf("playlist exceeds maximum %s of %d", e.Kind, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// limitedReader fails with a LimitError once more than max bytes are read
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, &LimitError{Kind: LimitBytes, Max: l.max}
+	}
+	return n, err
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func decodeWithLimits(playlist string, limits Limits) error {
+	decoder := NewDecoder(strings.NewReader(playlist))
+	decoder.SetLimits(limits)
+	_, err := decoder.Decode()
+	return err
+}
+
+func expectLimitError(t *testing.T, err error, kind LimitKind) {
+	t.Helper()
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Expected ErrLimitExceeded, got: %v", err)
+	}
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expected *LimitError, got %T", err)
+	}
+	if limitErr.Kind != kind {
+		t.Errorf("Expected limit kind %q, got %q", kind, limitErr.Kind)
+	}
+}
+
+func TestLimitLineLength(t *testing.T) {
+	playlist := "#EXTM3U\n#EXTINF:9.9,\n" + strings.Repeat("a", 200) + ".ts\n#EXT-X-ENDLIST\n"
+
+	limits := DefaultLimits()
+	limits.MaxLineLength = 100
+	err := decodeWithLimits(playlist, limits)
+	expectLimitError(t, err, LimitLineLength)
+
+	var limitErr *LimitError
+	errors.As(err, &limitErr)
+	if limitErr.Line != 3 {
+		t.Errorf("Expected error on line 3, got line %d", limitErr.Line)
+	}
+}
+
+func TestDefaultLimitsAcceptLongLines(t *testing.T) {
+	// Lines beyond bufio.Scanner's 64 KB default must not fail
+	playlist := "#EXTM3U\n#EXTINF:9.9," + strings.Repeat("t", 100*1024) + "\nsegment1.ts\n#EXT-X-ENDLIST\n"
+
+	if err := decodeWithLimits(playlist, DefaultLimits()); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+}
+
+func TestLimitSegments(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:10\n")
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&b, "#EXTINF:9.9,\nsegment%d.ts\n", i)
+	}
+
+	limits := DefaultLimits()
+	limits.MaxSegments = 4
+	expectLimitError(t, decodeWithLimits(b.String(), limits), LimitSegments)
+
+	limits.MaxSegments = 5
+	if err := decodeWithLimits(b.String(), limits); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+}
+
+func TestLimitAttributes(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="652@0",A=1,B=2
+#EXTINF:9.9,
+segment.m4s
+`
+
+	limits := DefaultLimits()
+	limits.MaxAttributes = 3
+	expectLimitError(t, decodeWithLimits(playlist, limits), LimitAttributes)
+}
+
+func TestLimitBytes(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "#EXTINF:9.9,\nsegment%d.ts\n", i)
+	}
+
+	limits := DefaultLimits()
+	limits.MaxBytes = 512
+	expectLimitError(t, decodeWithLimits(b.String(), limits), LimitBytes)
+}
+
+func TestDecodeContextCancelled(t *testing.T) {
+	playlist := `#EXTM3U
+#EXTINF:9.9,
+segment1.ts
+`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewDecoder(strings.NewReader(playlist)).DecodeContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestResolverNesting(t *testing.T) {
+	master := func(uri string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\n" + uri + "\n")}
+	}
+	fsys := fstest.MapFS{
+		"master.m3u8":        master("nested/master.m3u8"),
+		"nested/master.m3u8": master("media.m3u8"),
+		"nested/media.m3u8":  {Data: []byte("#EXTM3U\n#EXTINF:4,\nseg.ts\n#EXT-X-ENDLIST\n")},
+		"loop.m3u8":          master("loop.m3u8"),
+	}
+	ctx := context.Background()
+
+	limits := DefaultLimits()
+	limits.MaxNesting = 1
+	r := NewResolver(fsys, WithLimits(limits))
+	if _, err := r.Playlist(ctx, "master.m3u8"); err != nil {
+		t.Fatalf("Playlist failed: %v", err)
+	}
+	if _, name, err := r.Reference(ctx, "master.m3u8", "nested/master.m3u8"); err != nil || name != "nested/master.m3u8" {
+		t.Fatalf("Reference failed: %v", err)
+	}
+	_, _, err := r.Reference(ctx, "nested/master.m3u8", "media.m3u8")
+	expectLimitError(t, err, LimitNesting)
+
+	// A playlist referring to itself stops at the default limit
+	r = NewResolver(fsys)
+	if _, err := r.Playlist(ctx, "loop.m3u8"); err != nil {
+		t.Fatalf("Playlist failed: %v", err)
+	}
+	for i := 0; ; i++ {
+		if _, _, err = r.Reference(ctx, "loop.m3u8", "loop.m3u8"); err != nil {
+			break
+		}
+		if i > DefaultLimits().MaxNesting {
+			t.Fatal("Expected the reference loop to stop")
+		}
+	}
+	expectLimitError(t, err, LimitNesting)
+}
@@ -0,0 +1,405 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateTimeLayout is the ISO 8601 form written for PROGRAM-DATE-TIME and
+// DATERANGE dates, and dateTimeNanoLayout the form for dates with more
+// than millisecond precision, so that none is lost
+const (
+	dateTimeLayout     = "2006-01-02T15:04:05.000Z07:00"
+	dateTimeNanoLayout = "2006-01-02T15:04:05.999999999Z07:00"
+)
+
+func formatDateTime(t time.Time) string {
+	if t.Nanosecond()%int(time.Millisecond) != 0 {
+		return t.Format(dateTimeNanoLayout)
+	}
+	return t.Format(dateTimeLayout)
+}
+
+// formatFloat writes a decimal-floating-point value without exponent,
+// using the shortest exact form when precision is negative
+func formatFloat(v float64, precision int) string {
+	return strconv.FormatFloat(v, 'f', precision, 64)
+}
+
+// attrWriter builds an attribute list in a fixed attribute order, quoting
+// each value according to its attribute type
+type attrWriter struct {
+	parts []string
+}
+
+// quoted writes a quoted-string attribute unless value is empty
+func (a *attrWriter) quoted(name, value string) {
+	if value != "" {
+		a.parts = append(a.parts, name+`="`+value+`"`)
+	}
+}
+
+// enum writes an unquoted attribute unless value is empty
+func (a *attrWriter) enum(name, value string) {
+	if value != "" {
+		a.parts = append(a.parts, name+"="+value)
+	}
+}
+
+// int writes a decimal-integer attribute unless value is zero
+func (a *attrWriter) int(name string, value int64) {
+	if value != 0 {
+		a.parts = append(a.parts, name+"="+strconv.FormatInt(value, 10))
+	}
+}
+
+// float writes a decimal-floating-point attribute
+func (a *attrWriter) float(name string, value float64) {
+	a.parts = append(a.parts, name+"="+formatFloat(value, -1))
+}
+
+// yes writes an attribute as YES when set
+func (a *attrWriter) yes(name string, value bool) {
+	if value {
+		a.parts = append(a.parts, name+"=YES")
+	}
+}
+
+// other writes attributes without a known type in name order, quoting
+// anything that is not a number, hex sequence, resolution or enumeration
+func (a *attrWriter) other(attrs AttributeList) {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := attrs[name]
+		if isUnquotedValue(value) {
+			a.parts = append(a.parts, name+"="+value)
+		} else {
+			a.parts = append(a.parts, name+`="`+value+`"`)
+		}
+	}
+}
+
+func (a *attrWriter) String() string {
+	return strings.Join(a.parts, ",")
+}
+
+func isEnumValue(s string) bool {
+	for _, r := range s {
+		if !((r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isUnquotedValue(s string) bool {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	var w, h int
+	if n, _ := fmt.Sscanf(s, "%dx%d", &w, &h); n == 2 && s == fmt.Sprintf("%dx%d", w, h) {
+		return true
+	}
+	return isEnumValue(s)
+}
+
+func startString(s *Start) string {
+	var a attrWriter
+	a.float("TIME-OFFSET", s.TimeOffset)
+	a.yes("PRECISE", s.Precise)
+	return a.String()
+}
+
+func writeStart(b *strings.Builder, s *Start) {
+	b.WriteString("#EXT-X-START:" + startString(s) + "\n")
+}
+
+func writeDefines(b *strings.Builder, defines []Define) {
+	for _, d := range defines {
+		var a attrWriter
+		switch {
+		case d.Import != "":
+			a.quoted("IMPORT", d.Import)
+		case d.QueryParam != "":
+			a.quoted("QUERYPARAM", d.QueryParam)
+		default:
+			a.quoted("NAME", d.Name)
+			a.parts = append(a.parts, `VALUE="`+d.Value+`"`)
+		}
+		b.WriteString("#EXT-X-DEFINE:" + a.String() + "\n")
+	}
+}
+
+func writeTags(b *strings.Builder, tags []Tag) {
+	for _, t := range tags {
+		b.WriteString(t.String() + "\n")
+	}
+}
+
+func partialSegmentString(p *PartialSegment) string {
+	var a attrWriter
+	a.float("DURATION", p.Duration)
+	a.quoted("URI", p.URI)
+	a.yes("INDEPENDENT", p.Independent)
+	if p.Byterange != nil {
+		a.quoted("BYTERANGE", p.Byterange.String())
+	}
+	a.yes("GAP", p.Gap)
+	return a.String()
+}
+
+func sameKey(a, b *Key) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameMap(a, b *Map) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.URI != b.URI || (a.Byterange == nil) != (b.Byterange == nil) {
+		return false
+	}
+	return a.Byterange == nil || *a.Byterange == *b.Byterange
+}
+
+// formatDuration formats an EXTINF duration. Versions before 3 only
+// allow integer durations.
+func formatDuration(seconds float64, version, precision int) string {
+	if version > 0 && version < 3 {
+		return strconv.Itoa(int(math.Round(seconds)))
+	}
+	return formatFloat(seconds, precision)
+}
+
+// writeMediaPlaylist renders a media playlist. A negative precision writes
+// EXTINF durations in their shortest exact form.
+func writeMediaPlaylist(b *strings.Builder, p *MediaPlaylist, precision int) error {
+	b.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		b.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", p.Version))
+	}
+	if p.IndependentSegments {
+		b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	if p.TargetDuration > 0 {
+		b.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", p.TargetDuration))
+	}
+	if p.MediaSequence > 0 {
+		b.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence))
+	}
+	if p.DiscontinuitySequence > 0 {
+		b.WriteString(fmt.Sprintf("#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.DiscontinuitySequence))
+	}
+	if p.PlaylistType != "" {
+		b.WriteString(fmt.Sprintf("#EXT-X-PLAYLIST-TYPE:%s\n", p.PlaylistType))
+	}
+	if p.IFramesOnly {
+		b.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	}
+	if p.PartTarget > 0 {
+		b.WriteString("#EXT-X-PART-INF:PART-TARGET=" + formatFloat(p.PartTarget, -1) + "\n")
+	}
+	if p.Start != nil {
+		writeStart(b, p.Start)
+	}
+	writeDefines(b, p.Defines)
+	writeTags(b, p.Tags)
+
+	var (
+		lastKey     *Key
+		lastMap     *Map
+		lastBitrate int64
+	)
+	for i, seg := range p.Segments {
+		if seg.URI == "" {
+			return fmt.Errorf("segment %d has no URI", i)
+		}
+
+		writeTags(b, seg.Tags)
+		if seg.Discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		key := seg.Key
+		if key != nil && key.Method == "NONE" {
+			key = nil
+		}
+		if !sameKey(key, lastKey) {
+			if key == nil {
+				b.WriteString("#EXT-X-KEY:METHOD=NONE\n")
+			} else {
+				b.WriteString("#EXT-X-KEY:" + key.String() + "\n")
+			}
+			lastKey = key
+		}
+		if seg.Map != nil && !sameMap(seg.Map, lastMap) {
+			b.WriteString("#EXT-X-MAP:" + seg.Map.String() + "\n")
+		}
+		lastMap = seg.Map
+		if !seg.ProgramDateTime.IsZero() {
+			b.WriteString("#EXT-X-PROGRAM-DATE-TIME:" + formatDateTime(seg.ProgramDateTime) + "\n")
+		}
+		for _, dr := range seg.DateRanges {
+			b.WriteString("#EXT-X-DATERANGE:" + dr.String() + "\n")
+		}
+		if seg.Gap {
+			b.WriteString("#EXT-X-GAP\n")
+		}
+		// EXT-X-BITRATE holds until the next one and cannot be cleared, so
+		// every segment it would apply to after the first needs its own.
+		// It does not apply to byte ranges, and gaps carry no media.
+		switch {
+		case seg.Bitrate > 0 && seg.Bitrate != lastBitrate:
+			b.WriteString(fmt.Sprintf("#EXT-X-BITRATE:%d\n", seg.Bitrate))
+			lastBitrate = seg.Bitrate
+		case seg.Bitrate == 0 && lastBitrate > 0 && seg.Byterange == nil && !seg.Gap:
+			return fmt.Errorf("segment %d has no EXT-X-BITRATE after one with %d", i, lastBitrate)
+		}
+		for _, part := range seg.Parts {
+			b.WriteString("#EXT-X-PART:" + partialSegmentString(part) + "\n")
+		}
+
+		b.WriteString("#EXTINF:" + formatDuration(seg.Duration, p.Version, precision) + "," + seg.Title + "\n")
+		if seg.Byterange != nil {
+			b.WriteString(fmt.Sprintf("#EXT-X-BYTERANGE:%d@%d\n", seg.Byterange.Count, seg.Byterange.Offset))
+		}
+		b.WriteString(seg.URI + "\n")
+	}
+
+	for _, part := range p.Parts {
+		b.WriteString("#EXT-X-PART:" + partialSegmentString(part) + "\n")
+	}
+	if p.EndList {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return nil
+}
+
+func variantString(v *Variant) string {
+	var a attrWriter
+	a.int("BANDWIDTH", v.Bandwidth)
+	a.int("AVERAGE-BANDWIDTH", v.AverageBandwidth)
+	if v.Score > 0 {
+		a.float("SCORE", v.Score)
+	}
+	a.quoted("CODECS", v.Codecs)
+	a.quoted("SUPPLEMENTAL-CODECS", v.SupplementalCodecs)
+	if v.Resolution != nil {
+		a.enum("RESOLUTION", v.Resolution.String())
+	}
+	if v.FrameRate > 0 && !v.IFrame {
+		a.enum("FRAME-RATE", formatFloat(v.FrameRate, 3))
+	}
+	a.enum("HDCP-LEVEL", v.HDCPLevel)
+	a.quoted("ALLOWED-CPC", v.AllowedCPC)
+	a.enum("VIDEO-RANGE", string(v.VideoRange))
+	a.quoted("REQ-VIDEO-LAYOUT", v.ReqVideoLayout)
+	a.quoted("STABLE-VARIANT-ID", v.StableVariantID)
+	if !v.IFrame {
+		a.quoted("AUDIO", v.Audio)
+	}
+	a.quoted("VIDEO", v.Video)
+	if !v.IFrame {
+		a.quoted("SUBTITLES", v.Subtitles)
+		if v.ClosedCaptions == "NONE" {
+			a.enum("CLOSED-CAPTIONS", v.ClosedCaptions)
+		} else {
+			a.quoted("CLOSED-CAPTIONS", v.ClosedCaptions)
+		}
+	}
+	a.quoted("PATHWAY-ID", v.PathwayID)
+	a.other(v.Attrs)
+	if v.IFrame {
+		a.quoted("URI", v.URI)
+	}
+	return a.String()
+}
+
+func renditionString(r *Rendition) string {
+	var a attrWriter
+	a.enum("TYPE", string(r.Type))
+	a.quoted("GROUP-ID", r.GroupID)
+	a.quoted("NAME", r.Name)
+	a.quoted("LANGUAGE", r.Language)
+	a.quoted("ASSOC-LANGUAGE", r.AssocLanguage)
+	a.quoted("STABLE-RENDITION-ID", r.StableRenditionID)
+	a.yes("DEFAULT", r.Default)
+	a.yes("AUTOSELECT", r.Autoselect)
+	a.yes("FORCED", r.Forced)
+	a.quoted("INSTREAM-ID", r.InstreamID)
+	a.quoted("CHARACTERISTICS", r.Characteristics)
+	a.quoted("CHANNELS", r.Channels)
+	a.int("BIT-DEPTH", int64(r.BitDepth))
+	a.int("SAMPLE-RATE", int64(r.SampleRate))
+	a.other(r.Attrs)
+	a.quoted("URI", r.URI)
+	return a.String()
+}
+
+// writeMultivariantPlaylist renders a multivariant playlist
+func writeMultivariantPlaylist(b *strings.Builder, p *MultivariantPlaylist) error {
+	b.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		b.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", p.Version))
+	}
+	if p.IndependentSegments {
+		b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	if p.Start != nil {
+		writeStart(b, p.Start)
+	}
+	writeDefines(b, p.Defines)
+	writeTags(b, p.Tags)
+
+	for _, r := range p.Renditions {
+		if r.Type == "" || r.GroupID == "" || r.Name == "" {
+			return fmt.Errorf("rendition %q is missing TYPE, GROUP-ID or NAME", r.Name)
+		}
+		b.WriteString("#EXT-X-MEDIA:" + renditionString(r) + "\n")
+	}
+	if len(p.Renditions) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, v := range p.IFrameVariants {
+		if v.URI == "" {
+			return fmt.Errorf("I-frame variant has no URI")
+		}
+		if err := checkVariant(v); err != nil {
+			return fmt.Errorf("I-frame variant %q: %w", v.URI, err)
+		}
+		b.WriteString("#EXT-X-I-FRAME-STREAM-INF:" + variantString(v) + "\n")
+	}
+	if len(p.IFrameVariants) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, v := range p.Variants {
+		if v.URI == "" {
+			return fmt.Errorf("variant has no URI")
+		}
+		if err := checkVariant(v); err != nil {
+			return fmt.Errorf("variant %q: %w", v.URI, err)
+		}
+		b.WriteString("#EXT-X-STREAM-INF:" + variantString(v) + "\n")
+		b.WriteString(v.URI + "\n")
+		b.WriteString("\n")
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Builders for small synthetic media files used by the media scanning
+// tests
+
+// tsFrame is a video access unit of a synthetic transport stream
+type tsFrame struct {
+	pts  int64
+	key  bool
+	size int
+}
+
+const tsVideoPID = 0x100
+
+// buildTS writes a transport stream with a PAT, a PMT for one H.264 stream
+// and one PES packet per frame
+func buildTS(frames []tsFrame) []byte {
+	var b bytes.Buffer
+	b.Write(tsPSIPacket(0, []byte{
+		0x00, 0xB0, 13, 0x00, 0x01, 0xC1, 0x00, 0x00,
+		0x00, 0x01, 0xF0, 0x00, // program 1 on PID 0x1000
+		0, 0, 0, 0, // CRC, not checked
+	}))
+	b.Write(tsPSIPacket(0x1000, []byte{
+		0x02, 0xB0, 18, 0x00, 0x01, 0xC1, 0x00, 0x00,
+		0xE1, 0x00, 0xF0, 0x00,
+		0x1B, 0xE1, 0x00, 0xF0, 0x00, // H.264 on PID 0x100
+		0, 0, 0, 0,
+	}))
+	for _, f := range frames {
+		b.Write(tsPES(tsVideoPID, f))
+	}
+	return b.Bytes()
+}
+
+func tsPSIPacket(pid uint16, section []byte) []byte {
+	pkt := bytes.Repeat([]byte{0xFF}, 188)
+	pkt[0], pkt[1], pkt[2], pkt[3] = 0x47, 0x40|byte(pid>>8), byte(pid), 0x10
+	pkt[4] = 0 // pointer field
+	copy(pkt[5:], section)
+	return pkt
+}
+
+// tsPES packetizes one access unit, stuffing the last packet through its
+// adaptation field
+func tsPES(pid uint16, f tsFrame) []byte {
+	nal := byte(0x41)
+	if f.key {
+		nal = 0x65
+	}
+	pes := []byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x80, 5}
+	pes = append(pes, tsTimestamp(0x2, f.pts)...)
+	pes = append(pes, 0, 0, 0, 1, 0x09, 0xF0) // access unit delimiter
+	pes = append(pes, 0, 0, 1, nal)
+	for len(pes) < f.size {
+		pes = append(pes, 0xAA)
+	}
+
+	var out bytes.Buffer
+	for first := true; len(pes) > 0; first = false {
+		pkt := make([]byte, 4, 188)
+		pkt[0], pkt[1], pkt[2] = 0x47, byte(pid>>8), byte(pid)
+		if first {
+			pkt[1] |= 0x40
+		}
+		n := min(len(pes), 184)
+		if n < 184 {
+			// Adaptation field with stuffing
+			pkt[3] = 0x30
+			stuffing := 184 - n - 1
+			pkt = append(pkt, byte(stuffing))
+			if stuffing > 0 {
+				pkt = append(pkt, 0x00)
+				pkt = append(pkt, bytes.Repeat([]byte{0xFF}, stuffing-1)...)
+			}
+		} else {
+			pkt[3] = 0x10
+		}
+		pkt = append(pkt, pes[:n]...)
+		pes = pes[n:]
+		out.Write(pkt)
+	}
+	return out.Bytes()
+}
+
+func tsTimestamp(prefix byte, ts int64) []byte {
+	return []byte{
+		prefix<<4 | byte(ts>>29)&0x0E | 1,
+		byte(ts >> 22),
+		byte(ts>>14) | 1,
+		byte(ts >> 7),
+		byte(ts<<1) | 1,
+	}
+}
+
+// mp4Sample is a sample of a synthetic movie fragment
+type mp4Sample struct {
+	duration uint32
+	size     uint32
+	sync     bool
+}
+
+func mp4Box(typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, p := range payloads {
+		size += len(p)
+	}
+	b := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+	for _, p := range payloads {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func u32s(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// buildInit writes an initialization section with one video track
+func buildInit(timescale uint32) []byte {
+	tkhd := mp4Box("tkhd", u32s(0, 0, 0, 1, 0, 0)) // version 0, track 1
+	mdhd := mp4Box("mdhd", u32s(0, 0, 0, timescale, 0, 0))
+	hdlr := mp4Box("hdlr", u32s(0, 0), []byte("vide"), u32s(0, 0, 0), []byte{0})
+	trak := mp4Box("trak", tkhd, mp4Box("mdia", mdhd, hdlr))
+	trex := mp4Box("trex", u32s(0, 1, 1, 0, 0, 0x10000))
+	return append(mp4Box("ftyp", []byte("iso6"), u32s(0)),
+		mp4Box("moov", mp4Box("mvhd", u32s(0, 0, 0, 1000, 0)), trak, mp4Box("mvex", trex))...)
+}
+
+// buildFragment writes a moof and mdat for track 1
+func buildFragment(sequence uint32, decodeTime uint64, samples []mp4Sample) []byte {
+	moof := func(dataOffset uint32) []byte {
+		trun := u32s(0x000701, uint32(len(samples)), dataOffset)
+		for _, s := range samples {
+			flags := uint32(0x10000)
+			if s.sync {
+				flags = 0x2000000
+			}
+			trun = append(trun, u32s(s.duration, s.size, flags)...)
+		}
+		tfdt := append(u32s(0x01000000), make([]byte, 8)...)
+		binary.BigEndian.PutUint64(tfdt[4:], decodeTime)
+		traf := mp4Box("traf",
+			mp4Box("tfhd", u32s(0x020000, 1)),
+			mp4Box("tfdt", tfdt),
+			mp4Box("trun", trun))
+		return mp4Box("moof", mp4Box("mfhd", u32s(0, sequence)), traf)
+	}
+	box := moof(uint32(len(moof(0)) + 8))
+
+	var data []byte
+	for _, s := range samples {
+		data = append(data, bytes.Repeat([]byte{0xAB}, int(s.size))...)
+	}
+	return append(box, mp4Box("mdat", data)...)
+}
+
+// buildSidx writes a version 0 segment index for track 1 whose first
+// subsegment follows it directly
+func buildSidx(timescale uint32, sizes []int, durations []uint32) []byte {
+	payload := u32s(0, 1, timescale, 0, 0, uint32(len(sizes)))
+	for i, size := range sizes {
+		payload = append(payload, u32s(uint32(size), durations[i], 0x90000000)...)
+	}
+	return mp4Box("sidx", payload)
+}
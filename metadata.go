@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/gotio/opentime"
+	"github.com/Avalanche-io/otio-hls/internal/metadata"
+)
+
+// Metadata values may come from this package or from an .otio file, so
+// the helpers below accept both the Go literal types used here and the
+// types produced by JSON decoding.
+
+// asMap and asInt64 are shared with the validate package
+var (
+	asMap   = metadata.AsMap
+	asInt64 = metadata.AsInt64
+)
+
+// namespace returns the dictionary stored under ns, or an empty one
+func namespace(md gotio.AnyDictionary, ns string) map[string]interface{} {
+	if md == nil {
+		return make(map[string]interface{})
+	}
+	if m, ok := asMap(md[ns]); ok {
+		return m
+	}
+	return make(map[string]interface{})
+}
+
+// asFloat returns a numeric metadata value as a float
+func asFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// asStrings returns a list metadata value as strings
+func asStrings(v interface{}) []string {
+	switch l := v.(type) {
+	case []string:
+		return l
+	case []interface{}:
+		out := make([]string, 0, len(l))
+		for _, item := range l {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// stringList converts strings to a metadata list
+func stringList(items []string) []interface{} {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[i] = item
+	}
+	return out
+}
+
+// setNamespace stores ns on md when it is not empty
+func setNamespace(md gotio.AnyDictionary, ns string, values map[string]interface{}) {
+	if len(values) > 0 {
+		md[ns] = values
+	}
+}
+
+// copyMetadata returns a deep copy of a metadata value, so that edits to
+// the copy leave the original untouched
+func copyMetadata(v interface{}) interface{} {
+	switch v := v.(type) {
+	case gotio.AnyDictionary:
+		out := make(gotio.AnyDictionary, len(v))
+		for k, item := range v {
+			out[k] = copyMetadata(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = copyMetadata(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = copyMetadata(item)
+		}
+		return out
+	case []string:
+		return append([]string(nil), v...)
+	}
+	return v
+}
+
+// copyClip returns a copy of a clip and its external reference, with edit
+// applied to a copy of the HLS metadata of each
+func copyClip(clip *gotio.Clip, edit func(hlsMD map[string]interface{})) *gotio.Clip {
+	copyEdited := func(md gotio.AnyDictionary) gotio.AnyDictionary {
+		if md == nil {
+			md = make(gotio.AnyDictionary)
+		} else {
+			md = copyMetadata(md).(gotio.AnyDictionary)
+		}
+		hlsMD, ok := asMap(md[metadataNamespace])
+		if !ok {
+			hlsMD = make(map[string]interface{})
+		}
+		edit(hlsMD)
+		delete(md, metadataNamespace)
+		setNamespace(md, metadataNamespace, hlsMD)
+		return md
+	}
+
+	var ref gotio.MediaReference
+	if ext, ok := clip.MediaReference().(*gotio.ExternalReference); ok {
+		ref = gotio.NewExternalReference(ext.Name(), ext.TargetURL(), nil, copyEdited(ext.Metadata()))
+	}
+	var sourceRange *opentime.TimeRange
+	if r := clip.SourceRange(); r != nil {
+		copied := *r
+		sourceRange = &copied
+	}
+	return gotio.NewClip(clip.Name(), ref, sourceRange, copyEdited(clip.Metadata()), nil, nil, "", nil)
+}
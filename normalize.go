@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// normalizePrecision is the number of decimal places Normalize keeps in
+// EXTINF durations, the Encoder's default precision
+const normalizePrecision = 6
+
+// Normalize rewrites a playlist in place to a canonical form, so that
+// playlists that play the same compare and cache the same once written.
+// It drops comments, rounds EXTINF durations to microseconds, drops
+// METHOD=NONE keys that only repeat the absence of one, makes segments
+// with equal keys and maps share them, and sorts variants by BANDWIDTH.
+//
+// Attribute order and byte range offsets need no rewriting: playlists are
+// always written with attributes in a fixed order and with explicit
+// EXT-X-BYTERANGE offsets. Normalizing a decoded playlist again, after
+// writing it, changes nothing.
+func Normalize(p Playlist) {
+	switch p := p.(type) {
+	case *MediaPlaylist:
+		normalizeMedia(p)
+	case *MultivariantPlaylist:
+		p.Tags = dropComments(p.Tags)
+		// Stable, so that variants of the same bandwidth keep their order
+		byBandwidth := func(variants []*Variant) {
+			sort.SliceStable(variants, func(i, j int) bool { return variants[i].Bandwidth < variants[j].Bandwidth })
+		}
+		byBandwidth(p.Variants)
+		byBandwidth(p.IFrameVariants)
+	}
+}
+
+// normalizedCopy returns a normalized copy of a playlist, leaving p unchanged.
+// The copy shares the keys, maps and other values Normalize does not
+// rewrite.
+func normalizedCopy(p Playlist) Playlist {
+	switch p := p.(type) {
+	case *MediaPlaylist:
+		c := *p
+		c.Tags = slices.Clone(p.Tags)
+		c.Segments = make([]*Segment, len(p.Segments))
+		for i, seg := range p.Segments {
+			s := *seg
+			s.Tags = slices.Clone(seg.Tags)
+			c.Segments[i] = &s
+		}
+		normalizeMedia(&c)
+		return &c
+	case *MultivariantPlaylist:
+		c := *p
+		c.Tags = slices.Clone(p.Tags)
+		c.Variants = slices.Clone(p.Variants)
+		c.IFrameVariants = slices.Clone(p.IFrameVariants)
+		Normalize(&c)
+		return &c
+	}
+	return p
+}
+
+func normalizeMedia(p *MediaPlaylist) {
+	p.Tags = dropComments(p.Tags)
+	scale := math.Pow10(normalizePrecision)
+
+	var lastKey *Key
+	var lastMap *Map
+	for _, seg := range p.Segments {
+		seg.Tags = dropComments(seg.Tags)
+		seg.Duration = math.Round(seg.Duration*scale) / scale
+
+		if seg.Key != nil && seg.Key.Method == "NONE" {
+			seg.Key = nil
+		}
+		if sameKey(seg.Key, lastKey) {
+			seg.Key = lastKey
+		}
+		lastKey = seg.Key
+
+		if sameMap(seg.Map, lastMap) {
+			seg.Map = lastMap
+		}
+		lastMap = seg.Map
+	}
+}
+
+// dropComments returns the tags that are not comments
+func dropComments(tags []Tag) []Tag {
+	out := tags[:0]
+	for _, t := range tags {
+		if t.Name != "" {
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
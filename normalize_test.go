@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const normalizeTestPlaylist = `#EXTM3U
+# packaged by an older tool
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:6
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:6.006006006,
+#EXT-X-BYTERANGE:1000@0
+main.ts
+#EXT-X-KEY:URI="key.bin",METHOD=AES-128
+#EXTINF:6.006006006,
+#EXT-X-BYTERANGE:1000
+main.ts
+# key repeated for no reason
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin"
+#EXTINF:2.5,
+#EXT-X-BYTERANGE:400
+main.ts
+#EXT-X-ENDLIST
+`
+
+func normalized(t *testing.T, data []byte) []byte {
+	t.Helper()
+	p, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	Normalize(p)
+	out, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return out
+}
+
+func TestNormalize(t *testing.T) {
+	once := normalized(t, []byte(normalizeTestPlaylist))
+	want := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.006006,
+#EXT-X-BYTERANGE:1000@0
+main.ts
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin"
+#EXTINF:6.006006,
+#EXT-X-BYTERANGE:1000@1000
+main.ts
+#EXTINF:2.5,
+#EXT-X-BYTERANGE:400@2000
+main.ts
+#EXT-X-ENDLIST
+`
+	if string(once) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, once)
+	}
+	if twice := normalized(t, once); !bytes.Equal(once, twice) {
+		t.Errorf("Expected normalizing to be idempotent, got:\n%s", twice)
+	}
+}
+
+func TestNormalizeVariants(t *testing.T) {
+	out := normalized(t, []byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080
+hi.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360
+lo.m3u8
+#EXT-X-STREAM-INF:RESOLUTION=1280x720,BANDWIDTH=2000000
+mid.m3u8
+`))
+	lo, mid, hi := bytes.Index(out, []byte("lo.m3u8")), bytes.Index(out, []byte("mid.m3u8")), bytes.Index(out, []byte("hi.m3u8"))
+	if !(lo < mid && mid < hi) {
+		t.Errorf("Expected variants by bandwidth, got:\n%s", out)
+	}
+}
+
+func TestEncodeNormalizeIdempotent(t *testing.T) {
+	encode := func(data string) string {
+		timeline, err := NewDecoder(strings.NewReader(data)).Decode()
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, WithNormalize()).Encode(timeline); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		return buf.String()
+	}
+	once := encode(normalizeTestPlaylist)
+	if strings.Contains(once, "#EXT-X-KEY:METHOD=NONE") || strings.Contains(once, "# ") {
+		t.Errorf("Expected no-op keys and comments dropped, got:\n%s", once)
+	}
+	if twice := encode(once); twice != once {
+		t.Errorf("Expected decode, normalize, encode to be idempotent:\n%s\nthen:\n%s", once, twice)
+	}
+}
+
+func TestEncodePlaylistNormalizeLeavesInput(t *testing.T) {
+	p := mustUnmarshalMedia(t, normalizeTestPlaylist)
+	before, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithNormalize()).EncodePlaylist(p); err != nil {
+		t.Fatalf("EncodePlaylist failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "METHOD=NONE") {
+		t.Errorf("Expected a normalized playlist, got:\n%s", buf.String())
+	}
+	if after, _ := Marshal(p); !bytes.Equal(before, after) {
+		t.Errorf("Expected the playlist unchanged, got:\n%s", after)
+	}
+}
+
+func TestAttributeListStringOrder(t *testing.T) {
+	attrs := ParseAttributeList(`TYPE=AUDIO,BANDWIDTH=100,NAME="English (US)"`)
+	if got, want := attrs.String(), `BANDWIDTH=100,NAME="English (US)",TYPE=AUDIO`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
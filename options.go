@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+// DecoderOption configures a Decoder
+type DecoderOption func(*Decoder)
+
+// WithBaseURL resolves relative segment and playlist URIs against base.
+// An invalid base URL is reported by Decode.
+func WithBaseURL(base string) DecoderOption {
+	return func(d *Decoder) {
+		d.baseURL = base
+	}
+}
+
+// WithRate sets the rate of the time ranges built for each segment.
+// The default of 1 expresses durations directly in seconds.
+func WithRate(rate float64) DecoderOption {
+	return func(d *Decoder) {
+		if rate > 0 {
+			d.rate = rate
+		}
+	}
+}
+
+// Strict makes the decoder fail on malformed tags and attribute values
+// instead of skipping them
+func Strict() DecoderOption {
+	return func(d *Decoder) {
+		d.strict = true
+	}
+}
+
+// WithLimits replaces the default decoder limits
+func WithLimits(l Limits) DecoderOption {
+	return func(d *Decoder) {
+		d.limits = l
+	}
+}
+
+// EncoderOption configures an Encoder
+type EncoderOption func(*Encoder)
+
+// WithVersion sets the EXT-X-VERSION written to every playlist, overriding
+// any version found in the timeline metadata. Playlists are down-leveled
+// to it as Downlevel does, and encoding fails with a *VersionError when a
+// feature cannot be expressed at that version.
+func WithVersion(version int) EncoderOption {
+	return func(e *Encoder) {
+		e.version = version
+	}
+}
+
+// WithDurationPrecision sets the number of decimal places written for
+// EXTINF durations. The default is 6.
+func WithDurationPrecision(digits int) EncoderOption {
+	return func(e *Encoder) {
+		if digits >= 0 {
+			e.precision = digits
+		}
+	}
+}
+
+// WithMasterPlaylist forces (true) or suppresses (false) master playlist
+// output, overriding the track count and the "master_playlist" metadata
+func WithMasterPlaylist(master bool) EncoderOption {
+	return func(e *Encoder) {
+		e.master = &master
+	}
+}
+
+// WithVariantRules writes one EXT-X-STREAM-INF for every pair of video
+// track and audio group that any of the rules accepts, instead of linking
+// each video track to the first audio track in its linked_tracks
+func WithVariantRules(rules ...VariantRule) EncoderOption {
+	return func(e *Encoder) {
+		e.variantRules = append(e.variantRules, rules...)
+	}
+}
+
+// WithNormalize writes every playlist in the canonical form of Normalize
+func WithNormalize() EncoderOption {
+	return func(e *Encoder) {
+		e.normalize = true
+	}
+}
+
+// WithPreflight runs check on every playlist before it is written, and
+// writes nothing when it fails. The validate package provides checks.
+func WithPreflight(check func(Playlist) error) EncoderOption {
+	return func(e *Encoder) {
+		e.preflight = check
+	}
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+const optionsTestPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXTINF:9.5,
+seg/segment1.ts
+#EXTINF:4.25,
+seg/segment2.ts
+#EXT-X-ENDLIST
+`
+
+func firstTrack(t *testing.T, timeline *gotio.Timeline) *gotio.Track {
+	t.Helper()
+	children := timeline.Tracks().Children()
+	if len(children) == 0 {
+		t.Fatal("Expected at least one track")
+	}
+	track, ok := children[0].(*gotio.Track)
+	if !ok {
+		t.Fatalf("Expected Track, got %T", children[0])
+	}
+	return track
+}
+
+func TestDecoderWithRate(t *testing.T) {
+	decoder := NewDecoder(strings.NewReader(optionsTestPlaylist), WithRate(24))
+	timeline, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	clip := firstTrack(t, timeline).Children()[0].(*gotio.Clip)
+	duration, err := clip.Duration()
+	if err != nil {
+		t.Fatalf("Failed to get duration: %v", err)
+	}
+	if duration.Rate() != 24 || duration.Value() != 228 {
+		t.Errorf("Expected 228@24, got %v@%v", duration.Value(), duration.Rate())
+	}
+}
+
+func TestDecoderWithBaseURL(t *testing.T) {
+	decoder := NewDecoder(strings.NewReader(optionsTestPlaylist), WithBaseURL("https://cdn.example.com/vod/index.m3u8"))
+	timeline, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	clip := firstTrack(t, timeline).Children()[0].(*gotio.Clip)
+	ref := clip.MediaReference().(*gotio.ExternalReference)
+	if ref.TargetURL() != "https://cdn.example.com/vod/seg/segment1.ts" {
+		t.Errorf("Expected resolved URL, got %s", ref.TargetURL())
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXTINF:abc,
+segment1.ts
+`
+
+	// Lenient decoding skips the bad duration
+	if _, err := NewDecoder(strings.NewReader(playlist)).Decode(); err != nil {
+		t.Fatalf("Lenient decode failed: %v", err)
+	}
+
+	_, err := NewDecoder(strings.NewReader(playlist), Strict()).Decode()
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Expected *SyntaxError, got: %v", err)
+	}
+	if syntaxErr.Line != 3 || syntaxErr.Tag != "EXTINF" {
+		t.Errorf("Expected error at line 3 on EXTINF, got line %d on %s", syntaxErr.Line, syntaxErr.Tag)
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(optionsTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf, WithVersion(4), WithDurationPrecision(3))
+	if err := encoder.Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "#EXT-X-VERSION:4\n") {
+		t.Errorf("Expected version 4, got:\n%s", output)
+	}
+	if !strings.Contains(output, "#EXTINF:4.250,\n") {
+		t.Errorf("Expected 3 decimal EXTINF, got:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := NewEncoder(&buf, WithMasterPlaylist(true)).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "#EXTINF") {
+		t.Errorf("Expected master playlist output, got:\n%s", buf.String())
+	}
+}
+
+func TestEncodeContextCancelled(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(optionsTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	err = NewEncoder(&buf).EncodeContext(ctx, timeline)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output after cancellation, got %d bytes", buf.Len())
+	}
+}
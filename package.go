@@ -0,0 +1,418 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// PackageLayout names the files of a package. Templates may use {name},
+// the track name made safe for paths, {index}, the track's position
+// counting from 1, and {kind}, video or audio. Segment templates may also
+// use {file}, the segment's file name, and {seq}, its position in the
+// media playlist counting from 1. Initialization sections keep their file
+// name in the directory of the segment template.
+type PackageLayout struct {
+	Multivariant string
+	Media        string
+	IFrame       string
+	Segment      string
+}
+
+// DefaultPackageLayout returns a layout with one directory per track
+func DefaultPackageLayout() PackageLayout {
+	return PackageLayout{
+		Multivariant: "master.m3u8",
+		Media:        "{name}/index.m3u8",
+		IFrame:       "{name}/iframes.m3u8",
+		Segment:      "{name}/{file}",
+	}
+}
+
+// PackageOption configures Package
+type PackageOption func(*packager)
+
+// WithLayout sets the file names Package writes
+func WithLayout(l PackageLayout) PackageOption {
+	return func(p *packager) {
+		p.layout = l
+	}
+}
+
+// WithSegmentCopy copies the segments and initialization sections the
+// clips refer to from src into the package, rewriting their URIs
+func WithSegmentCopy(src fs.FS) PackageOption {
+	return func(p *packager) {
+		p.src = src
+		p.linkDir = ""
+	}
+}
+
+// WithSegmentLinks is like WithSegmentCopy for segments in the OS directory
+// dir, but hard links them when the destination supports it
+func WithSegmentLinks(dir string) PackageOption {
+	return func(p *packager) {
+		p.src = os.DirFS(dir)
+		p.linkDir = dir
+	}
+}
+
+// WithIFramePlaylists generates an I-frame playlist for every video track
+// from its copied or linked segments
+func WithIFramePlaylists() PackageOption {
+	return func(p *packager) {
+		p.iframes = true
+	}
+}
+
+// WithComputedBandwidth measures the bandwidth of every track with clips
+// from its copied or linked segments, as ComputeBandwidth does, before the
+// playlists are written. The timeline's metadata is left unchanged.
+func WithComputedBandwidth() PackageOption {
+	return func(p *packager) {
+		p.bandwidth = true
+	}
+}
+
+// WithEncoderOptions sets the options of the encoder writing the playlists
+func WithEncoderOptions(opts ...EncoderOption) PackageOption {
+	return func(p *packager) {
+		p.encoderOpts = append(p.encoderOpts, opts...)
+	}
+}
+
+type packager struct {
+	dst         WritableFS
+	layout      PackageLayout
+	src         fs.FS
+	linkDir     string
+	iframes     bool
+	bandwidth   bool
+	encoderOpts []EncoderOption
+
+	enc *Encoder
+	// copied maps source URIs to their path in the package
+	copied map[string]string
+	// written maps package paths to what wrote them, to catch collisions
+	written map[string]string
+	// created lists the package paths that did not exist before they were
+	// written, which are all remove may delete
+	created []string
+}
+
+// Package writes a playable HLS package for a timeline to dst: the
+// multivariant playlist and the media playlist of every track with clips,
+// at the paths of the layout. Audio renditions and variants without clips
+// keep the URIs in their metadata. A timeline that Encode would write as a
+// single media playlist is written at the layout's multivariant path.
+//
+// With WithSegmentCopy or WithSegmentLinks the referenced segments are
+// copied into the package as well; otherwise segment URIs are written
+// unchanged and must resolve from the new playlist locations.
+//
+// When packaging fails, the files it created are removed again if dst can
+// remove files, as DirFS and MemFS can. Files that were there before are
+// left, even when the package overwrote them.
+func Package(ctx context.Context, t *gotio.Timeline, dst WritableFS, opts ...PackageOption) error {
+	p := &packager{
+		dst:     dst,
+		layout:  DefaultPackageLayout(),
+		copied:  make(map[string]string),
+		written: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.run(ctx, t); err != nil {
+		p.remove()
+		return err
+	}
+	return nil
+}
+
+// run writes the package
+func (p *packager) run(ctx context.Context, t *gotio.Timeline) error {
+	if p.iframes && p.src == nil {
+		return fmt.Errorf("I-frame playlists need WithSegmentCopy or WithSegmentLinks")
+	}
+	p.enc = NewEncoder(nil, p.encoderOpts...)
+	p.enc.packaged = make(map[*gotio.Track]*packagedTrack)
+	if p.bandwidth {
+		if p.src == nil {
+			return fmt.Errorf("computed bandwidth needs WithSegmentCopy or WithSegmentLinks")
+		}
+		// The encoder takes the measured values over the metadata, so the
+		// caller's timeline stays as it was
+		for _, child := range t.Tracks().Children() {
+			track, ok := child.(*gotio.Track)
+			if !ok || len(track.Children()) == 0 {
+				continue
+			}
+			bw, bitrates, err := measureBandwidth(ctx, track, FileSizes(p.src))
+			if err != nil {
+				return fmt.Errorf("track %q: %w", track.Name(), err)
+			}
+			p.enc.packaged[track] = &packagedTrack{bandwidth: bw, bitrates: bitrates}
+		}
+	}
+
+	top, err := p.enc.playlist(ctx, t)
+	if err != nil {
+		return err
+	}
+	if media, ok := top.(*MediaPlaylist); ok {
+		track := t.Tracks().Children()[0].(*gotio.Track)
+		return p.writeMedia(ctx, media, track, p.layout.Multivariant, 1)
+	}
+
+	masterDir := path.Dir(p.layout.Multivariant)
+	for i, child := range t.Tracks().Children() {
+		track, ok := child.(*gotio.Track)
+		if !ok || len(track.Children()) == 0 {
+			continue
+		}
+		media, err := p.enc.encodeMediaPlaylist(ctx, track)
+		if err != nil {
+			return err
+		}
+		name := expandTrack(p.layout.Media, track, i+1)
+		pk := p.enc.packaged[track]
+		if pk == nil {
+			pk = &packagedTrack{}
+		}
+		pk.uri = relativeURI(masterDir, name)
+
+		// The I-frame playlist is generated from the source segments, but
+		// written after the media playlist so that the segments they share
+		// are named by their place in the media playlist
+		var iframes *MediaPlaylist
+		if p.iframes && trackMediaType(track) == MediaTypeVideo && !IsTrickPlay(track) {
+			iframes, err = GenerateIFramePlaylist(p.src, media)
+			if err != nil {
+				return fmt.Errorf("track %q: %w", track.Name(), err)
+			}
+			// The version of WithVersion applies to it like to the
+			// playlists the encoder builds
+			if err := p.enc.setVersion(iframes, nil); err != nil {
+				return fmt.Errorf("track %q: I-frame playlist: %w", track.Name(), err)
+			}
+		}
+		if err := p.writeMedia(ctx, media, track, name, i+1); err != nil {
+			return err
+		}
+		if iframes != nil {
+			iframeName := expandTrack(p.layout.IFrame, track, i+1)
+			pk.iframeURI = relativeURI(masterDir, iframeName)
+			pk.iframeBandwidth = IFrameBandwidth(iframes)
+			if err := p.writeMedia(ctx, iframes, track, iframeName, i+1); err != nil {
+				return err
+			}
+		}
+		p.enc.packaged[track] = pk
+	}
+
+	master, err := p.enc.encodeMasterPlaylist(ctx, t)
+	if err != nil {
+		return err
+	}
+	checked, err := p.enc.check(master)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if err := writeMultivariantPlaylist(&b, checked.(*MultivariantPlaylist)); err != nil {
+		return err
+	}
+	return p.write(p.layout.Multivariant, "multivariant playlist", []byte(b.String()))
+}
+
+// remove removes the files created so far, when dst can
+func (p *packager) remove() {
+	r, ok := p.dst.(removeFS)
+	if !ok {
+		return
+	}
+	for _, name := range p.created {
+		r.Remove(name)
+	}
+}
+
+// creating records name as created by the package unless it exists
+// already. It is called before the file is written, so that a partly
+// written file is removed as well.
+func (p *packager) creating(name string) {
+	if r, ok := p.dst.(removeFS); ok && !r.Exists(name) {
+		p.created = append(p.created, name)
+	}
+}
+
+// writeMedia writes a media playlist to name, first copying its segments
+// into the package when requested
+func (p *packager) writeMedia(ctx context.Context, media *MediaPlaylist, track *gotio.Track, name string, index int) error {
+	if p.src != nil {
+		dir := path.Dir(name)
+		// Segments share maps with their neighbours and with the I-frame
+		// playlist, so each map is replaced by one rewritten copy
+		maps := make(map[*Map]*Map)
+		for i, seg := range media.Segments {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			target, err := p.copySegment(seg.URI, track, index, i+1)
+			if err != nil {
+				return err
+			}
+			seg.URI = relativeURI(dir, target)
+			if seg.Map == nil {
+				continue
+			}
+			if m, ok := maps[seg.Map]; ok {
+				seg.Map = m
+				continue
+			}
+			target, err = p.copyInit(seg.Map.URI, track, index, i+1)
+			if err != nil {
+				return err
+			}
+			m := *seg.Map
+			m.URI = relativeURI(dir, target)
+			maps[seg.Map] = &m
+			seg.Map = &m
+		}
+	}
+
+	// The preflight check sees the playlist as written, with the URIs of
+	// the package
+	checked, err := p.enc.check(media)
+	if err != nil {
+		return fmt.Errorf("track %q: %w", track.Name(), err)
+	}
+	var b strings.Builder
+	if err := writeMediaPlaylist(&b, checked.(*MediaPlaylist), p.enc.precision); err != nil {
+		return err
+	}
+	return p.write(name, fmt.Sprintf("track %q", track.Name()), []byte(b.String()))
+}
+
+// copySegment copies or links a segment into the package once, returning
+// its path there
+func (p *packager) copySegment(uri string, track *gotio.Track, index, seq int) (string, error) {
+	if target, ok := p.copied[uri]; ok {
+		return target, nil
+	}
+	name, err := segmentPath(uri)
+	if err != nil {
+		return "", err
+	}
+	target := strings.NewReplacer(
+		"{file}", path.Base(name),
+		"{seq}", strconv.Itoa(seq),
+	).Replace(expandTrack(p.layout.Segment, track, index))
+	return target, p.copyFile(uri, name, target)
+}
+
+// copyInit is copySegment for an initialization section, which keeps its
+// own file name in the directory of the segment that first uses it, as
+// {seq} would give it the name of that segment
+func (p *packager) copyInit(uri string, track *gotio.Track, index, seq int) (string, error) {
+	if target, ok := p.copied[uri]; ok {
+		return target, nil
+	}
+	name, err := segmentPath(uri)
+	if err != nil {
+		return "", err
+	}
+	dir := strings.NewReplacer(
+		"{file}", path.Base(name),
+		"{seq}", strconv.Itoa(seq),
+	).Replace(path.Dir(expandTrack(p.layout.Segment, track, index)))
+	target := path.Join(dir, path.Base(name))
+	return target, p.copyFile(uri, name, target)
+}
+
+// copyFile copies or links the source file name to target, for the
+// segment or initialization section uri
+func (p *packager) copyFile(uri, name, target string) error {
+	if prev, ok := p.written[target]; ok {
+		return fmt.Errorf("%s and segment %q both write %s", prev, uri, target)
+	}
+
+	p.creating(target)
+	linked := false
+	if l, ok := p.dst.(linkFS); ok && p.linkDir != "" {
+		linked = l.Link(filepath.Join(p.linkDir, filepath.FromSlash(name)), target) == nil
+	}
+	if !linked {
+		data, err := fs.ReadFile(p.src, name)
+		if err != nil {
+			return err
+		}
+		if err := p.dst.WriteFile(target, data); err != nil {
+			return err
+		}
+	}
+	p.written[target] = fmt.Sprintf("segment %q", uri)
+	p.copied[uri] = target
+	return nil
+}
+
+// write writes a file once, reporting a collision with an earlier file
+func (p *packager) write(name, what string, data []byte) error {
+	if prev, ok := p.written[name]; ok {
+		return fmt.Errorf("%s and %s both write %s", prev, what, name)
+	}
+	p.written[name] = what
+	p.creating(name)
+	return p.dst.WriteFile(name, data)
+}
+
+// expandTrack fills the track placeholders of a layout template
+func expandTrack(tmpl string, track *gotio.Track, index int) string {
+	name := safeName(track.Name())
+	if name == "" {
+		name = "track" + strconv.Itoa(index)
+	}
+	return strings.NewReplacer(
+		"{name}", name,
+		"{index}", strconv.Itoa(index),
+		"{kind}", strings.ToLower(track.Kind()),
+	).Replace(tmpl)
+}
+
+// safeName replaces the characters of a track name that do not belong in
+// a path element
+func safeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, strings.Trim(name, "."))
+}
+
+// relativeURI returns the URI of target relative to the directory dir,
+// both slash-separated paths in the package
+func relativeURI(dir, target string) string {
+	if dir == "." {
+		return target
+	}
+	from := strings.Split(dir, "/")
+	to := strings.Split(target, "/")
+	i := 0
+	for i < len(from) && i < len(to)-1 && from[i] == to[i] {
+		i++
+	}
+	return strings.Repeat("../", len(from)-i) + strings.Join(to[i:], "/")
+}
@@ -0,0 +1,374 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/gotio/opentime"
+)
+
+// segmentTrack returns a track with a four second clip per URI
+func segmentTrack(name, kind string, md gotio.AnyDictionary, uris ...string) *gotio.Track {
+	track := gotio.NewTrack(name, nil, kind, md, nil)
+	for _, uri := range uris {
+		tr := opentime.NewTimeRange(opentime.NewRationalTime(0, 1), opentime.NewRationalTime(4, 1))
+		ref := gotio.NewExternalReference("", uri, nil, nil)
+		track.AppendChild(gotio.NewClip(uri, ref, &tr, nil, nil, nil, "", nil))
+	}
+	return track
+}
+
+// packageTestTimeline has two variants sharing an audio rendition, each
+// with two TS segments in src
+func packageTestTimeline() (*gotio.Timeline, fstest.MapFS) {
+	src := fstest.MapFS{}
+	for _, name := range []string{"hi_0.ts", "hi_1.ts", "lo_0.ts", "lo_1.ts", "en_0.ts", "en_1.ts"} {
+		src["media/"+name] = &fstest.MapFile{Data: tsSegment(0, 100)}
+	}
+
+	timeline := gotio.NewTimeline("Feature", nil, nil)
+	for _, v := range []struct {
+		name      string
+		bandwidth int
+	}{{"hi", 5000000}, {"lo", 1000000}} {
+		md := gotio.AnyDictionary{
+			streamingMetadataNamespace: map[string]interface{}{"bandwidth": v.bandwidth, "codec": "avc1.640028"},
+			"linked_tracks":            []interface{}{"English"},
+		}
+		timeline.Tracks().AppendChild(segmentTrack(v.name, gotio.TrackKindVideo, md,
+			"media/"+v.name+"_0.ts", "media/"+v.name+"_1.ts"))
+	}
+	audioMD := gotio.AnyDictionary{
+		streamingMetadataNamespace: map[string]interface{}{"group_id": "aac", "codec": "mp4a.40.2", "default": true},
+	}
+	timeline.Tracks().AppendChild(segmentTrack("English", gotio.TrackKindAudio, audioMD, "media/en_0.ts", "media/en_1.ts"))
+	return timeline, src
+}
+
+func TestPackage(t *testing.T) {
+	timeline, src := packageTestTimeline()
+	dst := MemFS{}
+	err := Package(context.Background(), timeline, dst, WithSegmentCopy(src), WithIFramePlaylists())
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+
+	for _, name := range []string{
+		"master.m3u8",
+		"hi/index.m3u8", "hi/iframes.m3u8", "hi/hi_0.ts", "hi/hi_1.ts",
+		"lo/index.m3u8", "lo/iframes.m3u8",
+		"English/index.m3u8", "English/en_0.ts",
+	} {
+		if _, ok := dst[name]; !ok {
+			t.Errorf("Expected %s in the package", name)
+		}
+	}
+
+	p, err := Unmarshal(dst["master.m3u8"])
+	if err != nil {
+		t.Fatalf("Unmarshal of master failed: %v", err)
+	}
+	master := p.(*MultivariantPlaylist)
+	if master.Variants[0].URI != "hi/index.m3u8" || master.Renditions[0].URI != "English/index.m3u8" {
+		t.Errorf("Unexpected playlist URIs:\n%s", dst["master.m3u8"])
+	}
+	if len(master.IFrameVariants) != 2 || master.IFrameVariants[1].URI != "lo/iframes.m3u8" || master.IFrameVariants[1].Bandwidth == 0 {
+		t.Errorf("Unexpected I-frame variants:\n%s", dst["master.m3u8"])
+	}
+
+	// Every URI in every playlist resolves inside the package
+	for name, data := range dst {
+		if !strings.HasSuffix(name, ".m3u8") || name == "master.m3u8" {
+			continue
+		}
+		var media MediaPlaylist
+		if err := media.Unmarshal(data); err != nil {
+			t.Fatalf("Unmarshal of %s failed: %v", name, err)
+		}
+		for _, seg := range media.Segments {
+			if _, ok := dst[path.Join(path.Dir(name), seg.URI)]; !ok {
+				t.Errorf("%s: segment %s is not in the package", name, seg.URI)
+			}
+		}
+	}
+}
+
+func TestPackageLayoutAndLinks(t *testing.T) {
+	timeline, src := packageTestTimeline()
+	srcDir := t.TempDir()
+	for name, f := range src {
+		target := filepath.Join(srcDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, f.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dstDir := t.TempDir()
+	layout := PackageLayout{
+		Multivariant: "hls/main.m3u8",
+		Media:        "hls/{kind}_{index}.m3u8",
+		IFrame:       "hls/{kind}_{index}_iframes.m3u8",
+		Segment:      "segments/{name}_{seq}.ts",
+	}
+	err := Package(context.Background(), timeline, DirFS(dstDir), WithLayout(layout), WithSegmentLinks(srcDir))
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+
+	master, err := os.ReadFile(filepath.Join(dstDir, "hls", "main.m3u8"))
+	if err != nil {
+		t.Fatalf("Reading master failed: %v", err)
+	}
+	if !strings.Contains(string(master), "\nvideo_2.m3u8\n") {
+		t.Errorf("Expected video_2.m3u8 in master:\n%s", master)
+	}
+	media, err := os.ReadFile(filepath.Join(dstDir, "hls", "audio_3.m3u8"))
+	if err != nil {
+		t.Fatalf("Reading audio playlist failed: %v", err)
+	}
+	if !strings.Contains(string(media), "\n../segments/English_2.ts\n") {
+		t.Errorf("Expected relative segment URI in:\n%s", media)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "segments", "hi_1.ts")); err != nil {
+		t.Errorf("Expected linked segment: %v", err)
+	}
+}
+
+func TestPackageCollision(t *testing.T) {
+	timeline, src := packageTestTimeline()
+	layout := DefaultPackageLayout()
+	layout.Media = "{kind}.m3u8"
+	dst := MemFS{}
+	err := Package(context.Background(), timeline, dst, WithLayout(layout), WithSegmentCopy(src))
+	if err == nil || !strings.Contains(err.Error(), "both write video.m3u8") {
+		t.Errorf("Expected a collision error, got %v", err)
+	}
+	if len(dst) != 0 {
+		t.Errorf("Expected the partial package removed, got %d files", len(dst))
+	}
+}
+
+func TestPackageIntoSourceDir(t *testing.T) {
+	// Segments already where the default layout puts them
+	segment := tsSegment(0, 100)
+	timeline := gotio.NewTimeline("Feature", nil, nil)
+	md := gotio.AnyDictionary{
+		streamingMetadataNamespace: map[string]interface{}{"bandwidth": 1000000, "codec": "avc1.640028"},
+	}
+	timeline.Tracks().AppendChild(segmentTrack("hi", gotio.TrackKindVideo, md, "hi/hi_0.ts", "hi/hi_1.ts"))
+	timeline.Tracks().AppendChild(segmentTrack("lo", gotio.TrackKindVideo, md, "lo/lo_0.ts"))
+
+	for _, test := range []struct {
+		name string
+		opt  func(dir string) PackageOption
+	}{
+		{"links", WithSegmentLinks},
+		{"copies", func(dir string) PackageOption { return WithSegmentCopy(os.DirFS(dir)) }},
+	} {
+		dir := t.TempDir()
+		for _, name := range []string{"hi/hi_0.ts", "hi/hi_1.ts", "lo/lo_0.ts"} {
+			target := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(target, segment, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		checkSegments := func(when string) {
+			for _, name := range []string{"hi/hi_0.ts", "hi/hi_1.ts", "lo/lo_0.ts"} {
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil || len(data) != len(segment) {
+					t.Errorf("%s: %s %s: %d bytes, %v", test.name, when, name, len(data), err)
+				}
+			}
+		}
+
+		if err := Package(context.Background(), timeline, DirFS(dir), test.opt(dir)); err != nil {
+			t.Fatalf("%s: Package failed: %v", test.name, err)
+		}
+		checkSegments("after packaging")
+		if _, err := os.Stat(filepath.Join(dir, "hi", "index.m3u8")); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+
+		// A failing package removes its playlists but not the segments
+		layout := DefaultPackageLayout()
+		layout.Multivariant = "hi/index.m3u8"
+		os.Remove(filepath.Join(dir, "master.m3u8"))
+		os.RemoveAll(filepath.Join(dir, "lo", "index.m3u8"))
+		if err := Package(context.Background(), timeline, DirFS(dir), WithLayout(layout), test.opt(dir)); err == nil {
+			t.Fatalf("%s: expected a collision error", test.name)
+		}
+		checkSegments("after a failure")
+		if _, err := os.Stat(filepath.Join(dir, "lo", "index.m3u8")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: expected the new lo playlist removed, got %v", test.name, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "hi", "index.m3u8")); err != nil {
+			t.Errorf("%s: expected the existing hi playlist kept: %v", test.name, err)
+		}
+	}
+}
+
+func TestPackageComputedBandwidth(t *testing.T) {
+	timeline, src := packageTestTimeline()
+	dst := MemFS{}
+	if err := Package(context.Background(), timeline, dst, WithSegmentCopy(src), WithComputedBandwidth()); err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+	if !strings.Contains(string(dst["hi/index.m3u8"]), "#EXT-X-BITRATE:") {
+		t.Errorf("Expected bitrate hints in:\n%s", dst["hi/index.m3u8"])
+	}
+	if strings.Contains(string(dst["master.m3u8"]), "BANDWIDTH=5000000,") {
+		t.Errorf("Expected the measured bandwidth in:\n%s", dst["master.m3u8"])
+	}
+
+	// The timeline keeps its own values
+	hi := timeline.Tracks().Children()[0].(*gotio.Track)
+	if bandwidth := namespace(hi.Metadata(), streamingMetadataNamespace)["bandwidth"]; bandwidth != 5000000 {
+		t.Errorf("Expected the track bandwidth unchanged, got %v", bandwidth)
+	}
+	for _, child := range hi.Children() {
+		if _, ok := namespace(child.(*gotio.Clip).Metadata(), metadataNamespace)["bitrate"]; ok {
+			t.Errorf("Expected no bitrate added to clip %s", child.Name())
+		}
+	}
+}
+
+func TestPackagePreflightSeesPackageURIs(t *testing.T) {
+	timeline, src := packageTestTimeline()
+	var uris []string
+	preflight := func(p Playlist) error {
+		if media, ok := p.(*MediaPlaylist); ok {
+			uris = append(uris, media.Segments[0].URI)
+		}
+		return nil
+	}
+	err := Package(context.Background(), timeline, MemFS{}, WithSegmentCopy(src), WithEncoderOptions(WithPreflight(preflight)))
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+	if strings.Join(uris, " ") != "hi_0.ts lo_0.ts en_0.ts" {
+		t.Errorf("Expected the preflight check on package URIs, got %v", uris)
+	}
+}
+
+func TestPackageIFrameVersion(t *testing.T) {
+	timeline, src := packageTestTimeline()
+	dst := MemFS{}
+	err := Package(context.Background(), timeline, dst, WithSegmentCopy(src), WithIFramePlaylists(),
+		WithEncoderOptions(WithVersion(6)))
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+	if !strings.Contains(string(dst["hi/iframes.m3u8"]), "#EXT-X-VERSION:6\n") {
+		t.Errorf("Expected the I-frame playlist at version 6:\n%s", dst["hi/iframes.m3u8"])
+	}
+
+	timeline, src = packageTestTimeline()
+	err = Package(context.Background(), timeline, MemFS{}, WithSegmentCopy(src), WithIFramePlaylists(),
+		WithEncoderOptions(WithVersion(3)))
+	var versionErr *VersionError
+	if !errors.As(err, &versionErr) {
+		t.Errorf("Expected a VersionError for I-frame playlists at version 3, got %v", err)
+	}
+}
+
+func TestPackageSeqFMP4(t *testing.T) {
+	samples := make([]mp4Sample, 48)
+	for i := range samples {
+		samples[i] = mp4Sample{duration: 512, size: 1000, sync: i == 0}
+	}
+	src := fstest.MapFS{
+		"media/init.mp4": {Data: buildInit(12288)},
+		"media/a.m4s":    {Data: buildFragment(1, 0, samples)},
+		"media/b.m4s":    {Data: buildFragment(2, 48*512, samples)},
+	}
+	track := gotio.NewTrack("v", nil, gotio.TrackKindVideo, nil, nil)
+	for _, uri := range []string{"media/a.m4s", "media/b.m4s"} {
+		tr := opentime.NewTimeRange(opentime.NewRationalTime(0, 1), opentime.NewRationalTime(2, 1))
+		ref := gotio.NewExternalReference("", uri, nil, nil)
+		md := gotio.AnyDictionary{streamingMetadataNamespace: map[string]interface{}{"init_uri": "media/init.mp4"}}
+		track.AppendChild(gotio.NewClip(uri, ref, &tr, md, nil, nil, "", nil))
+	}
+	timeline := gotio.NewTimeline("Feature", nil, nil)
+	timeline.Tracks().AppendChild(track)
+
+	dst := MemFS{}
+	layout := DefaultPackageLayout()
+	layout.Multivariant = "v/index.m3u8"
+	layout.Segment = "{name}/seg{seq}.bin"
+	if err := Package(context.Background(), timeline, dst, WithLayout(layout), WithSegmentCopy(src)); err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+	for _, name := range []string{"v/init.mp4", "v/seg1.bin", "v/seg2.bin"} {
+		if _, ok := dst[name]; !ok {
+			t.Errorf("Expected %s in the package", name)
+		}
+	}
+	if !strings.Contains(string(dst["v/index.m3u8"]), `#EXT-X-MAP:URI="init.mp4"`) {
+		t.Errorf("Expected the map under its own name:\n%s", dst["v/index.m3u8"])
+	}
+}
+
+func TestPackageSeqIFrames(t *testing.T) {
+	timeline, src := packageTestTimeline()
+	// Two keyframes in the first segment put the second segment third in
+	// the I-frame playlist
+	var frames []tsFrame
+	for i := 0; i < 100; i++ {
+		frames = append(frames, tsFrame{pts: int64(i) * 3600, key: i%50 == 0, size: 200})
+	}
+	src["media/hi_0.ts"] = &fstest.MapFile{Data: buildTS(frames)}
+
+	dst := MemFS{}
+	layout := DefaultPackageLayout()
+	layout.Segment = "{name}/{seq}.ts"
+	err := Package(context.Background(), timeline, dst, WithLayout(layout), WithSegmentCopy(src), WithIFramePlaylists())
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+
+	var iframes MediaPlaylist
+	if err := iframes.Unmarshal(dst["hi/iframes.m3u8"]); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	var uris []string
+	for _, seg := range iframes.Segments {
+		uris = append(uris, seg.URI)
+	}
+	if strings.Join(uris, " ") != "1.ts 1.ts 2.ts" {
+		t.Errorf("Expected segments named by the media playlist, got %v", uris)
+	}
+	if _, ok := dst["hi/3.ts"]; ok {
+		t.Error("Expected no segment named by its I-frame position")
+	}
+}
+
+func TestRelativeURI(t *testing.T) {
+	tests := []struct{ dir, target, want string }{
+		{".", "a/b.ts", "a/b.ts"},
+		{"a", "a/b.ts", "b.ts"},
+		{"a/b", "a/c/d.ts", "../c/d.ts"},
+		{"x", "y.ts", "../y.ts"},
+	}
+	for _, tt := range tests {
+		if got := relativeURI(tt.dir, tt.target); got != tt.want {
+			t.Errorf("relativeURI(%q, %q) = %q, want %q", tt.dir, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Playlist is a parsed M3U8 document: either a *MediaPlaylist or a
+// *MultivariantPlaylist
+type Playlist interface {
+	// Marshal renders the playlist as M3U8
+	Marshal() ([]byte, error)
+	isPlaylist()
+}
+
+// MediaType is the TYPE of an EXT-X-MEDIA rendition
+type MediaType string
+
+const (
+	MediaTypeAudio          MediaType = "AUDIO"
+	MediaTypeVideo          MediaType = "VIDEO"
+	MediaTypeSubtitles      MediaType = "SUBTITLES"
+	MediaTypeClosedCaptions MediaType = "CLOSED-CAPTIONS"
+)
+
+// Tag is a playlist line the typed fields do not model. It is kept
+// verbatim so that it survives a round trip. Comments are Tags with an
+// empty Name.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// String returns the tag as a playlist line without the line terminator
+func (t Tag) String() string {
+	switch {
+	case t.Name == "":
+		return "#" + t.Value
+	case t.Value == "":
+		return "#" + t.Name
+	}
+	return "#" + t.Name + ":" + t.Value
+}
+
+// Start is the EXT-X-START preferred start point
+type Start struct {
+	TimeOffset float64
+	Precise    bool
+}
+
+// Define is an EXT-X-DEFINE variable declaration. Exactly one of Value,
+// Import and QueryParam is used, as indicated by the set field.
+type Define struct {
+	Name       string
+	Value      string
+	Import     string
+	QueryParam string
+}
+
+// Key is an EXT-X-KEY segment encryption declaration
+type Key struct {
+	Method            string
+	URI               string
+	IV                string
+	KeyFormat         string
+	KeyFormatVersions string
+}
+
+// String returns the key as an attribute list
+func (k *Key) String() string {
+	var a attrWriter
+	a.enum("METHOD", k.Method)
+	a.quoted("URI", k.URI)
+	a.enum("IV", k.IV)
+	a.quoted("KEYFORMAT", k.KeyFormat)
+	a.quoted("KEYFORMATVERSIONS", k.KeyFormatVersions)
+	return a.String()
+}
+
+// Map is an EXT-X-MAP media initialization section
+type Map struct {
+	URI       string
+	Byterange *Byterange
+}
+
+// String returns the map as an attribute list
+func (m *Map) String() string {
+	var a attrWriter
+	a.quoted("URI", m.URI)
+	if m.Byterange != nil {
+		a.quoted("BYTERANGE", m.Byterange.String())
+	}
+	return a.String()
+}
+
+// DateRange is an EXT-X-DATERANGE tag
+type DateRange struct {
+	ID              string
+	Class           string
+	StartDate       time.Time
+	Cue             string
+	EndDate         time.Time
+	Duration        *float64
+	PlannedDuration *float64
+	SCTE35Cmd       string
+	SCTE35Out       string
+	SCTE35In        string
+	EndOnNext       bool
+	// ClientAttributes holds the X- prefixed attributes by name
+	ClientAttributes map[string]string
+}
+
+// String returns the date range as an attribute list
+func (r *DateRange) String() string {
+	var a attrWriter
+	a.quoted("ID", r.ID)
+	a.quoted("CLASS", r.Class)
+	if !r.StartDate.IsZero() {
+		a.quoted("START-DATE", formatDateTime(r.StartDate))
+	}
+	a.quoted("CUE", r.Cue)
+	if !r.EndDate.IsZero() {
+		a.quoted("END-DATE", formatDateTime(r.EndDate))
+	}
+	if r.Duration != nil {
+		a.float("DURATION", *r.Duration)
+	}
+	if r.PlannedDuration != nil {
+		a.float("PLANNED-DURATION", *r.PlannedDuration)
+	}
+	a.enum("SCTE35-CMD", r.SCTE35Cmd)
+	a.enum("SCTE35-OUT", r.SCTE35Out)
+	a.enum("SCTE35-IN", r.SCTE35In)
+	a.yes("END-ON-NEXT", r.EndOnNext)
+	a.other(AttributeList(r.ClientAttributes))
+	return a.String()
+}
+
+// PartialSegment is an EXT-X-PART low-latency partial segment
+type PartialSegment struct {
+	URI         string
+	Duration    float64
+	Independent bool
+	Byterange   *Byterange
+	Gap         bool
+}
+
+// Segment is one media segment of a media playlist
+type Segment struct {
+	URI      string
+	Duration float64
+	Title    string
+	// Byterange is the sub-range of URI, with the offset always resolved
+	Byterange     *Byterange
+	Discontinuity bool
+	// Key and Map are the declarations in effect for this segment, shared
+	// with neighbouring segments they also apply to. Key is nil for
+	// unencrypted segments.
+	Key             *Key
+	Map             *Map
+	ProgramDateTime time.Time
+	Gap             bool
+	// Bitrate is the EXT-X-BITRATE hint in kbit/s, or 0. After a segment
+	// with a hint, every later segment that is not a gap or byterange needs
+	// one too.
+	Bitrate    int64
+	DateRanges []*DateRange
+	Parts      []*PartialSegment
+	// Tags are unmodelled tags that appeared before the segment URI
+	Tags []Tag
+	// Line is the 1-based line number of the segment URI, if known
+	Line int
+}
+
+// MediaPlaylist is a playlist of media segments
+type MediaPlaylist struct {
+	Version               int
+	TargetDuration        int
+	MediaSequence         int
+	DiscontinuitySequence int
+	PlaylistType          PlaylistType
+	IFramesOnly           bool
+	IndependentSegments   bool
+	EndList               bool
+	// PartTarget is the EXT-X-PART-INF PART-TARGET, or 0
+	PartTarget float64
+	Start      *Start
+	Defines    []Define
+	Segments   []*Segment
+	// Parts are partial segments of a segment not yet complete
+	Parts []*PartialSegment
+	// Tags are unmodelled playlist-level tags
+	Tags []Tag
+}
+
+func (p *MediaPlaylist) isPlaylist() {}
+
+// Unmarshal parses M3U8 data into p using DefaultLimits
+func (p *MediaPlaylist) Unmarshal(data []byte) error {
+	entries, err := readEntries(context.Background(), bytes.NewReader(data), DefaultLimits())
+	if err != nil {
+		return err
+	}
+	parsed, err := newParser(DefaultLimits(), false).parseMediaPlaylist(context.Background(), entries)
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
+
+// Marshal renders the playlist as M3U8
+func (p *MediaPlaylist) Marshal() ([]byte, error) {
+	var b strings.Builder
+	if err := writeMediaPlaylist(&b, p, -1); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// Duration returns the sum of the segment durations in seconds
+func (p *MediaPlaylist) Duration() float64 {
+	var total float64
+	for _, seg := range p.Segments {
+		total += seg.Duration
+	}
+	return total
+}
+
+// Resolution is a RESOLUTION attribute value
+type Resolution struct {
+	Width  int
+	Height int
+}
+
+// String returns the resolution in WIDTHxHEIGHT form
+func (r Resolution) String() string {
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+// Variant is an EXT-X-STREAM-INF or EXT-X-I-FRAME-STREAM-INF entry
+type Variant struct {
+	URI string
+	// IFrame marks an EXT-X-I-FRAME-STREAM-INF entry
+	IFrame           bool
+	Bandwidth        int64
+	AverageBandwidth int64
+	Codecs           string
+	Resolution       *Resolution
+	FrameRate        float64
+	HDCPLevel        string
+	Audio            string
+	Video            string
+	Subtitles        string
+	ClosedCaptions   string
+	// Score ranks variants for the client, or is 0 when absent
+	Score              float64
+	SupplementalCodecs string
+	VideoRange         VideoRange
+	// AllowedCPC lists content protection configurations per KEYFORMAT,
+	// as in "com.apple.streamingkeydelivery:AppleMain/Main"
+	AllowedCPC      string
+	StableVariantID string
+	PathwayID       string
+	// ReqVideoLayout lists the video layouts required to play the
+	// variant, as in "CH-STEREO,CH-MONO"
+	ReqVideoLayout string
+	// Attrs holds attributes the typed fields do not model
+	Attrs AttributeList
+	// Line is the 1-based line number of the tag, if known
+	Line int
+}
+
+// VideoRange is the VIDEO-RANGE of a variant
+type VideoRange string
+
+const (
+	VideoRangeSDR VideoRange = "SDR"
+	VideoRangePQ  VideoRange = "PQ"
+	VideoRangeHLG VideoRange = "HLG"
+)
+
+// HDCP-LEVEL values
+const (
+	HDCPLevelType0 = "TYPE-0"
+	HDCPLevelType1 = "TYPE-1"
+	HDCPLevelNone  = "NONE"
+)
+
+// videoLayoutSpecifiers are the specifiers allowed in REQ-VIDEO-LAYOUT
+var videoLayoutSpecifiers = map[string]bool{
+	"CH-STEREO": true,
+	"CH-MONO":   true,
+	"PROJ-RECT": true,
+	"PROJ-EQUI": true,
+	"PROJ-HEQU": true,
+	"PROJ-PRIM": true,
+	"PROJ-AIV":  true,
+}
+
+// checkVariant reports an enumerated attribute with a value the
+// specification does not define
+func checkVariant(v *Variant) error {
+	switch v.VideoRange {
+	case "", VideoRangeSDR, VideoRangePQ, VideoRangeHLG:
+	default:
+		return fmt.Errorf("invalid VIDEO-RANGE %q", v.VideoRange)
+	}
+	switch v.HDCPLevel {
+	case "", HDCPLevelType0, HDCPLevelType1, HDCPLevelNone:
+	default:
+		return fmt.Errorf("invalid HDCP-LEVEL %q", v.HDCPLevel)
+	}
+	if v.Score < 0 {
+		return fmt.Errorf("invalid SCORE %v", v.Score)
+	}
+	if v.ReqVideoLayout != "" {
+		for _, layout := range strings.Split(v.ReqVideoLayout, ",") {
+			for _, specifier := range strings.Split(layout, "/") {
+				if !videoLayoutSpecifiers[specifier] {
+					return fmt.Errorf("invalid REQ-VIDEO-LAYOUT %q", v.ReqVideoLayout)
+				}
+			}
+		}
+	}
+	if v.AllowedCPC != "" {
+		for _, entry := range strings.Split(v.AllowedCPC, ",") {
+			if keyFormat, cpcs, ok := strings.Cut(entry, ":"); !ok || keyFormat == "" || cpcs == "" {
+				return fmt.Errorf("invalid ALLOWED-CPC %q", v.AllowedCPC)
+			}
+		}
+	}
+	return nil
+}
+
+// Rendition is an EXT-X-MEDIA entry
+type Rendition struct {
+	Type            MediaType
+	URI             string
+	GroupID         string
+	Language        string
+	AssocLanguage   string
+	Name            string
+	Default         bool
+	Autoselect      bool
+	Forced          bool
+	InstreamID      string
+	Characteristics string
+	Channels        string
+	// StableRenditionID identifies the rendition across playlist updates
+	StableRenditionID string
+	BitDepth          int
+	SampleRate        int
+	// Attrs holds attributes the typed fields do not model
+	Attrs AttributeList
+	// Line is the 1-based line number of the tag, if known
+	Line int
+}
+
+// MultivariantPlaylist is a playlist of variant streams and renditions,
+// also known as a master playlist
+type MultivariantPlaylist struct {
+	Version             int
+	IndependentSegments bool
+	Start               *Start
+	Defines             []Define
+	Renditions          []*Rendition
+	Variants            []*Variant
+	IFrameVariants      []*Variant
+	// Tags are unmodelled tags such as EXT-X-SESSION-DATA
+	Tags []Tag
+}
+
+func (p *MultivariantPlaylist) isPlaylist() {}
+
+// Unmarshal parses M3U8 data into p using DefaultLimits
+func (p *MultivariantPlaylist) Unmarshal(data []byte) error {
+	entries, err := readEntries(context.Background(), bytes.NewReader(data), DefaultLimits())
+	if err != nil {
+		return err
+	}
+	parsed, err := newParser(DefaultLimits(), false).parseMultivariantPlaylist(context.Background(), entries)
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
+
+// Marshal renders the playlist as M3U8
+func (p *MultivariantPlaylist) Marshal() ([]byte, error) {
+	var b strings.Builder
+	if err := writeMultivariantPlaylist(&b, p); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// Group returns the renditions of the given type in a group
+func (p *MultivariantPlaylist) Group(mediaType MediaType, groupID string) []*Rendition {
+	var group []*Rendition
+	for _, r := range p.Renditions {
+		if r.Type == mediaType && r.GroupID == groupID {
+			group = append(group, r)
+		}
+	}
+	return group
+}
+
+// Unmarshal parses M3U8 data into a *MediaPlaylist or *MultivariantPlaylist
+// using DefaultLimits
+func Unmarshal(data []byte) (Playlist, error) {
+	entries, err := readEntries(context.Background(), bytes.NewReader(data), DefaultLimits())
+	if err != nil {
+		return nil, err
+	}
+	return newParser(DefaultLimits(), false).parse(context.Background(), entries)
+}
+
+// Marshal renders a playlist as M3U8
+func Marshal(p Playlist) ([]byte, error) {
+	return p.Marshal()
+}
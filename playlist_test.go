@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+const multivariantTestPlaylist = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Example"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="French",LANGUAGE="fr",AUTOSELECT=YES,URI="audio/fr.m3u8"
+
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,CODECS="avc1.640028",RESOLUTION=1920x1080,URI="v1080/iframe.m3u8"
+
+#EXT-X-STREAM-INF:BANDWIDTH=6000000,AVERAGE-BANDWIDTH=5000000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=23.976,AUDIO="aac"
+v1080/prog_index.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=23.976,AUDIO="aac"
+v720/prog_index.m3u8
+`
+
+func TestUnmarshalMediaPlaylist(t *testing.T) {
+	data, err := os.ReadFile("testdata/v1_prog_index.m3u8")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+
+	playlist, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	media, ok := playlist.(*MediaPlaylist)
+	if !ok {
+		t.Fatalf("Expected *MediaPlaylist, got %T", playlist)
+	}
+
+	if media.Version != 7 || !media.IndependentSegments || !media.EndList {
+		t.Errorf("Unexpected header: version %d, independent %v, endlist %v", media.Version, media.IndependentSegments, media.EndList)
+	}
+	if len(media.Segments) != 50 {
+		t.Fatalf("Expected 50 segments, got %d", len(media.Segments))
+	}
+
+	first := media.Segments[0]
+	if first.Map == nil || first.Map.URI != "media-video-1.mp4" || first.Map.Byterange.Count != 729 {
+		t.Errorf("Unexpected map: %+v", first.Map)
+	}
+	if first.Byterange.Count != 534220 || first.Byterange.Offset != 1361 {
+		t.Errorf("Unexpected byterange: %+v", first.Byterange)
+	}
+	if first.Duration != 1.001 {
+		t.Errorf("Expected duration 1.001, got %v", first.Duration)
+	}
+
+	// Marshal and parse again
+	out, err := media.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Count(string(out), "#EXT-X-MAP:") != 1 {
+		t.Errorf("Expected a single EXT-X-MAP, got:\n%s", out)
+	}
+	if !strings.Contains(string(out), "#EXT-X-BYTERANGE:489061@29183823\n") {
+		t.Errorf("Expected explicit byterange in output")
+	}
+
+	var again MediaPlaylist
+	if err := again.Unmarshal(out); err != nil {
+		t.Fatalf("Unmarshal of marshalled playlist failed: %v", err)
+	}
+	for i := range media.Segments {
+		media.Segments[i].Line = 0
+		again.Segments[i].Line = 0
+	}
+	if !reflect.DeepEqual(media, &again) {
+		t.Error("Playlist changed across a marshal round trip")
+	}
+}
+
+func TestUnmarshalImplicitByteranges(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:10
+#EXTINF:10,
+#EXT-X-BYTERANGE:1000@0
+main.ts
+#EXTINF:10,
+#EXT-X-BYTERANGE:500
+main.ts
+`
+
+	var media MediaPlaylist
+	if err := media.Unmarshal([]byte(playlist)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if media.Segments[1].Byterange.Offset != 1000 {
+		t.Errorf("Expected implicit offset 1000, got %d", media.Segments[1].Byterange.Offset)
+	}
+	if media.EndList {
+		t.Error("Expected live playlist without EXT-X-ENDLIST")
+	}
+}
+
+func TestUnmarshalImplicitByterangeOtherURI(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:10
+#EXTINF:10,
+#EXT-X-BYTERANGE:1000@0
+a.ts
+#EXTINF:10,
+#EXT-X-BYTERANGE:500
+b.ts
+`
+
+	var media MediaPlaylist
+	if err := media.Unmarshal([]byte(playlist)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if media.Segments[1].Byterange.Offset != 0 {
+		t.Errorf("Expected no offset carried over from another resource, got %d", media.Segments[1].Byterange.Offset)
+	}
+
+	_, err := NewDecoder(strings.NewReader(playlist), Strict()).DecodePlaylist(context.Background())
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 9 {
+		t.Errorf("Expected a syntax error on line 9 in strict mode, got %v", err)
+	}
+}
+
+func TestProgramDateTimeKeepsPrecision(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.123456Z
+#EXTINF:10,
+a.ts
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:10.000Z
+#EXTINF:10,
+b.ts
+`
+
+	timeline, err := NewDecoder(strings.NewReader(playlist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	for _, want := range []string{"2024-01-01T00:00:00.123456Z", "2024-01-01T00:00:10.000Z"} {
+		if !strings.Contains(buf.String(), "#EXT-X-PROGRAM-DATE-TIME:"+want+"\n") {
+			t.Errorf("Expected date-time %s kept, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestBitrateRoundTrip(t *testing.T) {
+	p := &MediaPlaylist{
+		TargetDuration: 4,
+		Segments: []*Segment{
+			{URI: "a.ts", Duration: 4, Bitrate: 800},
+			{URI: "b.ts", Duration: 4, Bitrate: 800},
+			{URI: "c.ts", Duration: 4, Bitrate: 1200},
+			{URI: "d.mp4", Duration: 4, Byterange: &Byterange{Count: 1000}},
+			{URI: "e.ts", Duration: 4, Bitrate: 1200},
+		},
+	}
+	out, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var back MediaPlaylist
+	if err := back.Unmarshal(out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for i, seg := range back.Segments {
+		if seg.Byterange == nil && seg.Bitrate != p.Segments[i].Bitrate {
+			t.Errorf("Segment %d: bitrate %d, want %d in:\n%s", i, seg.Bitrate, p.Segments[i].Bitrate, out)
+		}
+	}
+
+	// A hint cannot be cleared, so it would carry over to e.ts
+	p.Segments[4].Bitrate = 0
+	if _, err := p.Marshal(); err == nil || !strings.Contains(err.Error(), "segment 4") {
+		t.Errorf("Expected an error for the segment without a hint, got %v", err)
+	}
+}
+
+func TestMediaPlaylistKeepsUnknownTags(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-CUSTOM-HEADER:1
+#EXTINF:10,
+segment1.ts
+#EXT-X-CUE-OUT:30
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin"
+#EXTINF:10,
+segment2.ts
+#EXT-X-ENDLIST
+`
+
+	var media MediaPlaylist
+	if err := media.Unmarshal([]byte(playlist)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(media.Tags) != 1 || media.Tags[0].Name != "EXT-X-CUSTOM-HEADER" {
+		t.Errorf("Expected header tag, got %+v", media.Tags)
+	}
+	seg := media.Segments[1]
+	if len(seg.Tags) != 1 || seg.Tags[0].String() != "#EXT-X-CUE-OUT:30" {
+		t.Errorf("Expected segment tag, got %+v", seg.Tags)
+	}
+	if seg.Key == nil || seg.Key.URI != "key.bin" || media.Segments[0].Key != nil {
+		t.Errorf("Unexpected keys: %+v, %+v", media.Segments[0].Key, seg.Key)
+	}
+
+	out, err := Marshal(&media)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := "segment1.ts\n#EXT-X-CUE-OUT:30\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:10,\nsegment2.ts\n"
+	if !strings.Contains(string(out), expected) {
+		t.Errorf("Expected segment tags in order, got:\n%s", out)
+	}
+}
+
+func TestUnmarshalMultivariantPlaylist(t *testing.T) {
+	playlist, err := Unmarshal([]byte(multivariantTestPlaylist))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	mv, ok := playlist.(*MultivariantPlaylist)
+	if !ok {
+		t.Fatalf("Expected *MultivariantPlaylist, got %T", playlist)
+	}
+
+	if len(mv.Variants) != 2 || len(mv.IFrameVariants) != 1 || len(mv.Renditions) != 2 {
+		t.Fatalf("Unexpected counts: %d variants, %d I-frame variants, %d renditions", len(mv.Variants), len(mv.IFrameVariants), len(mv.Renditions))
+	}
+
+	v := mv.Variants[0]
+	if v.Bandwidth != 6000000 || v.AverageBandwidth != 5000000 || v.Audio != "aac" || v.FrameRate != 23.976 {
+		t.Errorf("Unexpected variant: %+v", v)
+	}
+	if v.Resolution == nil || *v.Resolution != (Resolution{1920, 1080}) {
+		t.Errorf("Unexpected resolution: %+v", v.Resolution)
+	}
+	if v.Codecs != "avc1.640028,mp4a.40.2" {
+		t.Errorf("Unexpected codecs: %s", v.Codecs)
+	}
+	if group := mv.Group(MediaTypeAudio, "aac"); len(group) != 2 || !group[0].Default || group[1].Language != "fr" {
+		t.Errorf("Unexpected audio group: %+v", group)
+	}
+
+	out, err := mv.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var again MultivariantPlaylist
+	if err := again.Unmarshal(out); err != nil {
+		t.Fatalf("Unmarshal of marshalled playlist failed: %v", err)
+	}
+	for _, p := range []*MultivariantPlaylist{mv, &again} {
+		for _, v := range append(p.Variants, p.IFrameVariants...) {
+			v.Line = 0
+		}
+		for _, r := range p.Renditions {
+			r.Line = 0
+		}
+	}
+	if !reflect.DeepEqual(mv, &again) {
+		t.Errorf("Playlist changed across a marshal round trip:\n%s", out)
+	}
+}
+
+func TestDecodeMultivariantPlaylist(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(multivariantTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	tracks := timeline.Tracks().Children()
+	if len(tracks) != 4 {
+		t.Fatalf("Expected 2 video and 2 audio tracks, got %d", len(tracks))
+	}
+
+	video := tracks[0].(*gotio.Track)
+	if video.Kind() != gotio.TrackKindVideo {
+		t.Errorf("Expected video track first, got %s", video.Kind())
+	}
+	hlsMD := namespace(video.Metadata(), metadataNamespace)
+	if hlsMD["uri"] != "v1080/prog_index.m3u8" || hlsMD["iframe_uri"] != "v1080/iframe.m3u8" {
+		t.Errorf("Unexpected video HLS metadata: %v", hlsMD)
+	}
+	if linked := asStrings(video.Metadata()["linked_tracks"]); len(linked) != 2 {
+		t.Errorf("Expected two linked audio tracks, got %v", linked)
+	}
+
+	// Encoding the timeline again yields an equivalent master playlist
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Example"`,
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="audio/en.m3u8"`,
+		`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,CODECS="avc1.640028",RESOLUTION=1920x1080,URI="v1080/iframe.m3u8"`,
+		`#EXT-X-STREAM-INF:BANDWIDTH=6000000,AVERAGE-BANDWIDTH=5000000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=23.976,AUDIO="aac"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+}
+
+func TestTimelineRoundTripKeepsSegmentTags(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-KEY:METHOD=AES-128,URI="key1.bin"
+#EXT-X-PROGRAM-DATE-TIME:2023-01-01T00:00:00.000Z
+#EXTINF:9.9,
+segment1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=AES-128,URI="key2.bin"
+#EXTINF:9.9,
+segment2.ts
+#EXT-X-ENDLIST
+`
+
+	timeline, err := NewDecoder(strings.NewReader(playlist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	expected := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-KEY:METHOD=AES-128,URI="key1.bin"
+#EXT-X-PROGRAM-DATE-TIME:2023-01-01T00:00:00.000Z
+#EXTINF:9.900000,
+segment1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=AES-128,URI="key2.bin"
+#EXTINF:9.900000,
+segment2.ts
+#EXT-X-ENDLIST
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+	"sort"
+
+	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/gotio/opentime"
+	"github.com/Avalanche-io/otio-hls/internal/mpegts"
+)
+
+// ptsRate is the clock rate of MPEG-TS timestamps
+const ptsRate = 90000
+
+// ProbeOption configures Probe
+type ProbeOption func(*prober)
+
+// RewriteDurations makes Probe replace each clip's source range duration
+// with the measured one, keeping the range's start and rate
+func RewriteDurations() ProbeOption {
+	return func(p *prober) {
+		p.rewrite = true
+	}
+}
+
+// DiscontinuityTolerance sets how far, in seconds, a segment may start from
+// where the previous one ended before it counts as a PTS discontinuity.
+// The default is 0.1.
+func DiscontinuityTolerance(seconds float64) ProbeOption {
+	return func(p *prober) {
+		p.tolerance = seconds
+	}
+}
+
+// SegmentProbe is the measured timing of one segment
+type SegmentProbe struct {
+	Track *gotio.Track
+	Clip  *gotio.Clip
+	URI   string
+	// Start is the first presentation time in seconds and Duration the
+	// span of presentation times, last frame included
+	Start    float64
+	Duration float64
+	// Declared is the duration from the playlist and Drift the measured
+	// duration minus the declared one
+	Declared float64
+	Drift    float64
+	// Jump is how far the segment starts from where the previous one
+	// ended, in seconds
+	Jump float64
+	// Discontinuity reports an EXT-X-DISCONTINUITY before the segment;
+	// UnmarkedDiscontinuity a jump beyond the tolerance without one
+	Discontinuity         bool
+	UnmarkedDiscontinuity bool
+}
+
+// ProbeReport lists the measured segments of a timeline in track order
+type ProbeReport struct {
+	Segments []*SegmentProbe
+}
+
+// MaxDrift returns the largest absolute drift between measured and
+// declared durations
+func (r *ProbeReport) MaxDrift() float64 {
+	var drift float64
+	for _, s := range r.Segments {
+		drift = math.Max(drift, math.Abs(s.Drift))
+	}
+	return drift
+}
+
+// UnmarkedDiscontinuities returns the segments whose timestamps jump
+// without an EXT-X-DISCONTINUITY
+func (r *ProbeReport) UnmarkedDiscontinuities() []*SegmentProbe {
+	var out []*SegmentProbe
+	for _, s := range r.Segments {
+		if s.UnmarkedDiscontinuity {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+type prober struct {
+	fsys      fs.FS
+	rewrite   bool
+	tolerance float64
+}
+
+// Probe reads the MPEG-TS segments referenced by the clips of a decoded
+// timeline from fsys and measures their timing from the PES timestamps of
+// the video stream, or of the first stream when there is no video. It
+// reports each segment's drift against its EXTINF duration and the
+// timestamp jumps that no EXT-X-DISCONTINUITY announces.
+func Probe(ctx context.Context, fsys fs.FS, t *gotio.Timeline, opts ...ProbeOption) (*ProbeReport, error) {
+	p := &prober{fsys: fsys, tolerance: 0.1}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	report := &ProbeReport{}
+	for _, child := range t.Tracks().Children() {
+		track, ok := child.(*gotio.Track)
+		if !ok {
+			continue
+		}
+		if err := p.probeTrack(ctx, track, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+func (p *prober) probeTrack(ctx context.Context, track *gotio.Track, report *ProbeReport) error {
+	lastSeq, _ := asInt64(namespace(track.Metadata(), metadataNamespace)["discontinuity_sequence"])
+
+	var prevEnd int64
+	first := true
+	for _, child := range track.Children() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		clip, ok := child.(*gotio.Clip)
+		if !ok {
+			continue
+		}
+
+		seg := clipSegment(clip)
+		data, offset, err := readSegment(p.fsys, seg.URI, seg.Byterange)
+		if err != nil {
+			return err
+		}
+		start, duration, err := measureTS(data, offset)
+		if err != nil {
+			return fmt.Errorf("segment %q: %w", seg.URI, err)
+		}
+
+		probe := &SegmentProbe{
+			Track:    track,
+			Clip:     clip,
+			URI:      seg.URI,
+			Start:    float64(start) / ptsRate,
+			Duration: float64(duration) / ptsRate,
+			Declared: seg.Duration,
+		}
+		probe.Drift = probe.Duration - probe.Declared
+
+		seq, ok := asInt64(namespace(clip.Metadata(), metadataNamespace)["discontinuity_sequence"])
+		if !ok {
+			seq = lastSeq
+		}
+		probe.Discontinuity = seq > lastSeq
+		lastSeq = seq
+		if !first {
+			probe.Jump = float64(mpegts.TimestampDiff(prevEnd, start)) / ptsRate
+			probe.UnmarkedDiscontinuity = !probe.Discontinuity && math.Abs(probe.Jump) > p.tolerance
+		}
+		prevEnd = (start + duration) % (1 << 33)
+		first = false
+
+		if p.rewrite {
+			rewriteDuration(clip, duration)
+		}
+		report.Segments = append(report.Segments, probe)
+	}
+	return nil
+}
+
+// measureTS returns the first presentation time of a segment and the span
+// to the end of its last frame, both in 90 kHz ticks. The frame duration
+// is the smallest step between presentation times.
+func measureTS(data []byte, offset int64) (start, duration int64, err error) {
+	streams := make(map[uint16][]int64)
+	var video, firstPID uint16
+	haveFirst := false
+	err = mpegts.ReadPES(data, offset, func(pes *mpegts.PES) error {
+		if pes.PTS == mpegts.NoTimestamp {
+			return nil
+		}
+		if pes.IsVideo() && video == 0 {
+			video = pes.PID
+		}
+		if !haveFirst {
+			firstPID, haveFirst = pes.PID, true
+		}
+		streams[pes.PID] = append(streams[pes.PID], pes.PTS)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if !haveFirst {
+		return 0, 0, fmt.Errorf("no timestamps found")
+	}
+
+	pid := firstPID
+	if video != 0 {
+		pid = video
+	}
+	pts := streams[pid]
+
+	// Timestamps relative to the first, so a wrap inside the segment
+	// keeps them in order
+	base := pts[0]
+	rel := make([]int64, len(pts))
+	for i, v := range pts {
+		rel[i] = mpegts.TimestampDiff(base, v)
+	}
+	sort.Slice(rel, func(i, j int) bool { return rel[i] < rel[j] })
+
+	var frame int64
+	for i := 1; i < len(rel); i++ {
+		if step := rel[i] - rel[i-1]; step > 0 && (frame == 0 || step < frame) {
+			frame = step
+		}
+	}
+
+	start = (base + rel[0] + 1<<33) % (1 << 33)
+	return start, rel[len(rel)-1] - rel[0] + frame, nil
+}
+
+// rewriteDuration sets a clip's source range duration from 90 kHz ticks
+func rewriteDuration(clip *gotio.Clip, ticks int64) {
+	measured := opentime.NewRationalTime(float64(ticks), ptsRate)
+	tr := opentime.NewTimeRange(opentime.NewRationalTime(0, ptsRate), measured)
+	if sr := clip.SourceRange(); sr != nil {
+		rate := sr.Duration().Rate()
+		tr = opentime.NewTimeRange(sr.StartTime(), measured.RescaledTo(rate))
+	}
+	clip.SetSourceRange(&tr)
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// tsSegment returns a segment of 25 fps video starting at pts
+func tsSegment(pts int64, frames int) []byte {
+	var out []tsFrame
+	for i := 0; i < frames; i++ {
+		out = append(out, tsFrame{pts: pts + int64(i)*3600, key: i == 0, size: 200})
+	}
+	return buildTS(out)
+}
+
+func TestProbe(t *testing.T) {
+	fsys := fstest.MapFS{
+		"seg0.ts": {Data: tsSegment(126000, 100)},
+		"seg1.ts": {Data: tsSegment(126000+360000, 100)},
+		// Jumps ahead 10 seconds without a discontinuity
+		"seg2.ts": {Data: tsSegment(126000+1620000, 50)},
+		// Restarts with a discontinuity
+		"seg3.ts": {Data: tsSegment(0, 100)},
+	}
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.0,
+seg0.ts
+#EXTINF:3.5,
+seg1.ts
+#EXTINF:2.0,
+seg2.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:4.0,
+seg3.ts
+#EXT-X-ENDLIST
+`
+	timeline, err := NewDecoder(strings.NewReader(playlist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	report, err := Probe(context.Background(), fsys, timeline, RewriteDurations())
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if len(report.Segments) != 4 {
+		t.Fatalf("Expected 4 probed segments, got %d", len(report.Segments))
+	}
+
+	first := report.Segments[0]
+	if first.Start != 1.4 || first.Duration != 4 || first.Drift != 0 {
+		t.Errorf("Unexpected first segment: %+v", first)
+	}
+	if drift := report.Segments[1].Drift; drift != 0.5 {
+		t.Errorf("Expected drift 0.5 for the second segment, got %v", drift)
+	}
+	if report.MaxDrift() != 0.5 {
+		t.Errorf("Expected max drift 0.5, got %v", report.MaxDrift())
+	}
+
+	unmarked := report.UnmarkedDiscontinuities()
+	if len(unmarked) != 1 || unmarked[0].URI != "seg2.ts" || math.Abs(unmarked[0].Jump-10) > 1e-9 {
+		t.Errorf("Expected one unmarked 10 second jump at seg2.ts, got %+v", unmarked)
+	}
+	if last := report.Segments[3]; !last.Discontinuity || last.UnmarkedDiscontinuity {
+		t.Errorf("Expected a marked discontinuity, got %+v", last)
+	}
+
+	// Source ranges now hold the measured durations
+	clip := firstTrack(t, timeline).Children()[1].(*gotio.Clip)
+	if d, _ := clip.Duration(); d.ToSeconds() != 4 {
+		t.Errorf("Expected rewritten duration 4, got %v", d.ToSeconds())
+	}
+}
+
+func TestProbeTimestampWrap(t *testing.T) {
+	const wrap = 1 << 33
+	fsys := fstest.MapFS{
+		"a.ts": {Data: tsSegment(wrap-180000, 100)},
+		"b.ts": {Data: tsSegment(wrap-180000+360000-wrap, 100)},
+	}
+	timeline := gotio.NewTimeline("", nil, nil)
+	track := gotio.NewTrack("", nil, gotio.TrackKindVideo, nil, nil)
+	for _, name := range []string{"a.ts", "b.ts"} {
+		track.AppendChild(gotio.NewClip(name, gotio.NewExternalReference("", name, nil, nil), nil, nil, nil, nil, "", nil))
+	}
+	timeline.Tracks().AppendChild(track)
+
+	report, err := Probe(context.Background(), fsys, timeline)
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	for _, s := range report.Segments {
+		if s.Duration != 4 || s.UnmarkedDiscontinuity {
+			t.Errorf("Unexpected probe across the wrap: %+v", s)
+		}
+	}
+}
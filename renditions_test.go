@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+const textRenditionsPlaylist = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="subs/en.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English (Forced)",LANGUAGE="en",ASSOC-LANGUAGE="en-US",FORCED=YES,CHARACTERISTICS="public.accessibility.transcribes-spoken-dialog",URI="subs/en_forced.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English CC",LANGUAGE="en",INSTREAM-ID="CC1"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="Spanish CC",LANGUAGE="es",INSTREAM-ID="SERVICE1"
+
+#EXT-X-STREAM-INF:BANDWIDTH=6000000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,AUDIO="aac",SUBTITLES="subs",CLOSED-CAPTIONS="cc"
+v1080/prog_index.m3u8
+
+`
+
+func TestDecodeTextRenditions(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(textRenditionsPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	tracks := timeline.Tracks().Children()
+	if len(tracks) != 6 {
+		t.Fatalf("Expected 6 tracks, got %d", len(tracks))
+	}
+	forced := tracks[3].(*gotio.Track)
+	streamingMD := namespace(forced.Metadata(), streamingMetadataNamespace)
+	if trackMediaType(forced) != MediaTypeSubtitles || streamingMD["forced"] != true || streamingMD["assoc_language"] != "en-US" {
+		t.Errorf("Unexpected forced subtitle track %q: %v", forced.Name(), streamingMD)
+	}
+	cc := tracks[5].(*gotio.Track)
+	if trackMediaType(cc) != MediaTypeClosedCaptions || namespace(cc.Metadata(), streamingMetadataNamespace)["instream_id"] != "SERVICE1" {
+		t.Errorf("Unexpected caption track %q", cc.Name())
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if buf.String() != textRenditionsPlaylist {
+		t.Errorf("Expected the playlist back unchanged, got:\n%s", buf.String())
+	}
+}
+
+func TestEncodeTextRenditions(t *testing.T) {
+	timeline := gotio.NewTimeline("", nil, nil)
+	timeline.Tracks().AppendChild(gotio.NewTrack("main", nil, gotio.TrackKindVideo, gotio.AnyDictionary{
+		streamingMetadataNamespace: map[string]interface{}{"bandwidth": 2000000},
+	}, nil))
+	timeline.Tracks().AppendChild(gotio.NewTrack("Deutsch", nil, gotio.TrackKindVideo, gotio.AnyDictionary{
+		metadataNamespace:          map[string]interface{}{"media_type": "SUBTITLES"},
+		streamingMetadataNamespace: map[string]interface{}{"language": "de"},
+	}, nil))
+	timeline.Tracks().AppendChild(gotio.NewTrack("CC1", nil, gotio.TrackKindVideo, gotio.AnyDictionary{
+		metadataNamespace:          map[string]interface{}{"media_type": "CLOSED-CAPTIONS"},
+		streamingMetadataNamespace: map[string]interface{}{"instream_id": "CC1"},
+	}, nil))
+
+	master := masterPlaylist(t, timeline)
+	if len(master.Variants) != 1 {
+		t.Fatalf("Expected subtitle and caption tracks not to be variants, got %d variants", len(master.Variants))
+	}
+	v := master.Variants[0]
+	if v.Subtitles != "subs" || v.ClosedCaptions != "cc" {
+		t.Errorf("Expected the variant to refer to the only groups, got %q and %q", v.Subtitles, v.ClosedCaptions)
+	}
+
+	out, err := master.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	for _, want := range []string{
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Deutsch",LANGUAGE="de",URI="Deutsch.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="CC1",INSTREAM-ID="CC1"` + "\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+const audioRenditionsPlaylist = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="atmos",NAME="English",LANGUAGE="en",STABLE-RENDITION-ID="en-atmos",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="16/JOC",URI="audio/atmos.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="atmos",NAME="English (AD)",LANGUAGE="en",ASSOC-LANGUAGE="en-GB",AUTOSELECT=YES,CHARACTERISTICS="public.accessibility.describes-video",CHANNELS="2",URI="audio/ad.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="atmos",NAME="Hi-Res",LANGUAGE="en",CHANNELS="2",BIT-DEPTH=24,SAMPLE-RATE=96000,URI="audio/flac.m3u8"
+
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720,AUDIO="aac"
+v720/prog_index.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=8000000,CODECS="avc1.640028,ec-3",RESOLUTION=1920x1080,AUDIO="atmos"
+v1080/prog_index.m3u8
+
+`
+
+func TestAudioRenditionAttributes(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(audioRenditionsPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	audio := make(map[string]map[string]interface{})
+	for _, child := range timeline.Tracks().Children() {
+		track := child.(*gotio.Track)
+		if track.Kind() != gotio.TrackKindAudio {
+			continue
+		}
+		audio[track.Name()] = namespace(track.Metadata(), streamingMetadataNamespace)
+	}
+	if len(audio) != 4 {
+		t.Fatalf("Expected 4 audio tracks, got %d", len(audio))
+	}
+	for name, md := range audio {
+		switch {
+		case md["group_id"] == "aac":
+			if md["muxed"] != true {
+				t.Errorf("Expected %q to be muxed: %v", name, md)
+			}
+		case md["channels"] == "16/JOC":
+			if md["stable_rendition_id"] != "en-atmos" {
+				t.Errorf("Unexpected Atmos track %q: %v", name, md)
+			}
+		case md["characteristics"] != nil:
+			if md["characteristics"] != "public.accessibility.describes-video" || md["assoc_language"] != "en-GB" {
+				t.Errorf("Unexpected described-video track %q: %v", name, md)
+			}
+		default:
+			if md["bit_depth"] != 24 || md["sample_rate"] != 96000 {
+				t.Errorf("Unexpected hi-res track %q: %v", name, md)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if buf.String() != audioRenditionsPlaylist {
+		t.Errorf("Expected the playlist back unchanged, got:\n%s", buf.String())
+	}
+}
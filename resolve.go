@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Resolver reads a playlist and the playlists it refers to from a file
+// system, decoding each with the limits and strictness of a Decoder with
+// the same options. It follows chains of references no longer than
+// Limits.MaxNesting and fails with a LimitError beyond that, so that
+// playlists referring to each other cannot be followed forever.
+type Resolver struct {
+	fsys   fs.FS
+	opts   []DecoderOption
+	limits Limits
+	// depth is the number of references followed to reach each playlist
+	depth map[string]int
+}
+
+// NewResolver returns a Resolver reading from fsys
+func NewResolver(fsys fs.FS, opts ...DecoderOption) *Resolver {
+	return &Resolver{
+		fsys:   fsys,
+		opts:   opts,
+		limits: NewDecoder(nil, opts...).limits,
+		depth:  make(map[string]int),
+	}
+}
+
+// Playlist reads the playlist at name, a slash-separated path in the file
+// system, as the start of a chain of references
+func (r *Resolver) Playlist(ctx context.Context, name string) (Playlist, error) {
+	return r.read(ctx, name, 0)
+}
+
+// Reference reads the playlist that the playlist at from refers to with
+// the relative URI uri, and returns it with its path
+func (r *Resolver) Reference(ctx context.Context, from, uri string) (Playlist, string, error) {
+	name := path.Join(path.Dir(from), uri)
+	depth := r.depth[from] + 1
+	if limit := r.limits.MaxNesting; limit > 0 && depth > limit {
+		return nil, name, &LimitError{Kind: LimitNesting, Max: int64(limit)}
+	}
+	p, err := r.read(ctx, name, depth)
+	return p, name, err
+}
+
+func (r *Resolver) read(ctx context.Context, name string, depth int) (Playlist, error) {
+	f, err := r.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := NewDecoder(f, r.opts...).DecodePlaylist(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	r.depth[name] = depth
+	return p, nil
+}
@@ -0,0 +1,485 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// spliceTolerance is how far in seconds an ad pod may run over the break
+// it fills, or leave of it unfilled, since both are cut at segments
+const spliceTolerance = 0.25
+
+// spliceKey is the clip HLS metadata key recording an inserted clip
+const spliceKey = "splice"
+
+// AdBreak is an ad cue in a content timeline: an EXT-X-CUE-OUT tag, or an
+// EXT-X-DATERANGE with SCTE35-OUT
+type AdBreak struct {
+	// ID is the ID of the date range, or empty for EXT-X-CUE-OUT
+	ID string
+	// Start is where the break begins, in seconds from the start of the
+	// timeline
+	Start float64
+	// Duration is the signalled length of the break in seconds or, when
+	// the cue gives none, the length up to EXT-X-CUE-IN or the end. Zero
+	// marks a cue to insert at rather than content to replace.
+	Duration float64
+}
+
+// adBreak is an AdBreak with the clips it covers
+type adBreak struct {
+	AdBreak
+	// first is the index of the clip carrying the cue and count the number
+	// of clips the break replaces
+	first, count int
+	// cue is the tag line or date range that signals the break, and
+	// dateRange tells which
+	cue       string
+	dateRange bool
+}
+
+// AdBreaks returns the ad breaks of a single-track timeline in order
+func AdBreaks(t *gotio.Timeline) ([]AdBreak, error) {
+	track, err := singleTrack(t)
+	if err != nil {
+		return nil, err
+	}
+	var out []AdBreak
+	for _, b := range findBreaks(trimClips(track)) {
+		out = append(out, b.AdBreak)
+	}
+	return out, nil
+}
+
+// SpliceAds stitches ad pods into a single-track content timeline at its
+// ad breaks, as found by AdBreaks: pods[i] fills break i, and breaks with
+// a nil pod or none keep their content. A break with a duration is
+// replaced by its pod, which is cut at the last whole segment that fits.
+// When the pod falls short, slate fills the rest, repeated as often as it
+// takes, or else the content resumes inside the break at the first
+// segment after the pod. A zero-duration break has its pod inserted in
+// front of the clip carrying the cue.
+//
+// Each run of ads, slate or content starts with a discontinuity, and the
+// cue moves to the first ad clip. Clips that move to another media
+// sequence number keep the implicit IVs of their AES-128 keys as explicit
+// ones. With program date-times, each run
+// carries on from the one before, date ranges move with their clips, and
+// the content after a break shifts by what the break added or removed.
+// Inserted clips record the break they fill as splice metadata: the break
+// index, the kind, ad or slate, and the date range ID. The last slate clip
+// records as out_offset where the break ends inside it when it runs over.
+//
+// The content keeps its header metadata, with the highest target duration
+// and version of all the inputs. Segments without EXT-X-MAP cannot follow
+// segments with one, so splicing fMP4 ads into MPEG-TS content is an
+// error, as is a pod with no ad short enough for its break. The inputs are
+// not changed.
+func SpliceAds(content *gotio.Timeline, pods []*gotio.Timeline, slate *gotio.Timeline) (*gotio.Timeline, error) {
+	track, err := singleTrack(content)
+	if err != nil {
+		return nil, err
+	}
+	clips := trimClips(track)
+	breaks := findBreaks(clips)
+	if len(pods) > len(breaks) {
+		return nil, fmt.Errorf("%d ad pods for %d breaks", len(pods), len(breaks))
+	}
+
+	tracks := []*gotio.Track{track}
+	podTracks := make([]*gotio.Track, len(pods))
+	for i, pod := range pods {
+		if pod == nil {
+			continue
+		}
+		if podTracks[i], err = singleTrack(pod); err != nil {
+			return nil, fmt.Errorf("ad pod %d: %w", i, err)
+		}
+		tracks = append(tracks, podTracks[i])
+	}
+	var slateTrack *gotio.Track
+	var slateClips []trimClip
+	if slate != nil {
+		if slateTrack, err = singleTrack(slate); err != nil {
+			return nil, fmt.Errorf("slate: %w", err)
+		}
+		slateClips = trimClips(slateTrack)
+		var total float64
+		for _, c := range slateClips {
+			total += c.duration
+		}
+		if total <= 0 {
+			return nil, fmt.Errorf("slate has no duration")
+		}
+		tracks = append(tracks, slateTrack)
+	}
+
+	header := copyMetadata(namespace(track.Metadata(), metadataNamespace)).(map[string]interface{})
+	raiseHeader(header, tracks)
+	// The output starts at the content's numbers, even when an ad comes
+	// first
+	s := &splicer{out: derivedTrack(track, header)}
+	s.seq, _ = asInt64(header["discontinuity_sequence"])
+	s.sequence, _ = asInt64(header["media_sequence"])
+	if len(clips) > 0 {
+		s.nextPDT = clips[0].pdt
+	}
+
+	next := 0
+	for n, b := range breaks {
+		if n >= len(podTracks) || podTracks[n] == nil {
+			continue
+		}
+		replaced := clips[b.first : b.first+b.count]
+		var room float64
+		for _, c := range replaced {
+			room += c.duration
+		}
+		ads := trimClips(podTracks[n])
+		if len(ads) == 0 {
+			return nil, fmt.Errorf("ad pod %d has no clips", n)
+		}
+		if b.count > 0 {
+			ads = fitClips(ads, room)
+		}
+		if len(ads) == 0 {
+			return nil, fmt.Errorf("ad pod %d: no ad fits in break %d of %gs", n, n, roundOffset(room))
+		}
+
+		if err := s.run(clips[next:b.first], track, nil); err != nil {
+			return nil, err
+		}
+		next = b.first + b.count
+
+		// The cue moves with the content clock to the first ad
+		var cueShift time.Duration
+		if !s.nextPDT.IsZero() && !clips[b.first].pdt.IsZero() {
+			cueShift = s.nextPDT.Sub(clips[b.first].pdt)
+		}
+		inserted, err := s.insert(ads, podTracks[n], func(i int, hlsMD map[string]interface{}) {
+			hlsMD[spliceKey] = spliceMetadata(n, "ad", b.ID)
+			if i == 0 {
+				addCue(hlsMD, b, cueShift)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		if b.count == 0 {
+			// The clip the pod went in front of no longer carries the cue
+			s.dropCue = &b
+			continue
+		}
+		if inserted >= room-spliceTolerance {
+			continue
+		}
+
+		if slateClips == nil {
+			// Resume the content at the first segment after the pod
+			k, start := 0, 0.0
+			for k < len(replaced) && start < inserted-spliceTolerance {
+				start += replaced[k].duration
+				k++
+			}
+			next = b.first + k
+			continue
+		}
+		for remaining := room - inserted; remaining > spliceTolerance; {
+			var fill []trimClip
+			for _, c := range slateClips {
+				if remaining <= spliceTolerance {
+					break
+				}
+				fill = append(fill, c)
+				remaining -= c.duration
+			}
+			last, over := len(fill)-1, -remaining
+			if _, err := s.insert(fill, slateTrack, func(i int, hlsMD map[string]interface{}) {
+				hlsMD[spliceKey] = spliceMetadata(n, "slate", b.ID)
+				if i == last && over > spliceTolerance {
+					hlsMD[outOffsetKey] = roundOffset(fill[i].duration - over)
+				}
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := s.run(clips[next:], track, nil); err != nil {
+		return nil, err
+	}
+	return derivedTimeline(content, s.out)
+}
+
+// SpliceAdPlaylists is SpliceAds for media playlists; slate may be nil
+func SpliceAdPlaylists(content *MediaPlaylist, pods []*MediaPlaylist, slate *MediaPlaylist) (*gotio.Timeline, error) {
+	d := NewDecoder(nil)
+	podTimelines := make([]*gotio.Timeline, len(pods))
+	for i, pod := range pods {
+		if pod != nil {
+			podTimelines[i] = d.decodeMediaPlaylist(pod)
+		}
+	}
+	var slateTimeline *gotio.Timeline
+	if slate != nil {
+		slateTimeline = d.decodeMediaPlaylist(slate)
+	}
+	return SpliceAds(d.decodeMediaPlaylist(content), podTimelines, slateTimeline)
+}
+
+// findBreaks finds the ad breaks among clips. Cues inside a break are part
+// of it.
+func findBreaks(clips []trimClip) []adBreak {
+	var breaks []adBreak
+	for i := 0; i < len(clips); i++ {
+		b, signalled, ok := clipCue(clips[i].clip)
+		if !ok {
+			continue
+		}
+		b.first = i
+		b.Start = clips[i].start
+		if signalled && b.Duration <= 0 {
+			breaks = append(breaks, b)
+			continue
+		}
+		j, length := i, 0.0
+		for ; j < len(clips); j++ {
+			if j > i && endsBreak(clips[j].clip, b) {
+				break
+			}
+			if signalled && length >= b.Duration-spliceTolerance {
+				break
+			}
+			length += clips[j].duration
+		}
+		b.count = j - i
+		if !signalled {
+			b.Duration = length
+		}
+		breaks = append(breaks, b)
+		i = j - 1
+	}
+	return breaks
+}
+
+// clipCue returns the ad cue a clip carries, and whether it gives the
+// length of the break
+func clipCue(clip *gotio.Clip) (adBreak, bool, bool) {
+	hlsMD := namespace(clip.Metadata(), metadataNamespace)
+	for _, line := range asStrings(hlsMD["tags"]) {
+		name, value, _ := strings.Cut(strings.TrimPrefix(line, "#"), ":")
+		if name != "EXT-X-CUE-OUT" {
+			continue
+		}
+		b := adBreak{cue: line}
+		if value == "" {
+			return b, false, true
+		}
+		if d, err := strconv.ParseFloat(value, 64); err == nil {
+			b.Duration = d
+			return b, true, true
+		}
+		if d, err := strconv.ParseFloat(ParseAttributeList(value)["DURATION"], 64); err == nil {
+			b.Duration = d
+			return b, true, true
+		}
+		return b, false, true
+	}
+	for _, value := range asStrings(hlsMD["EXT-X-DATERANGE"]) {
+		dr, err := parseDateRange(ParseAttributeList(value))
+		if err != nil || dr.SCTE35Out == "" {
+			continue
+		}
+		b := adBreak{AdBreak: AdBreak{ID: dr.ID}, cue: value, dateRange: true}
+		switch {
+		case dr.Duration != nil:
+			b.Duration = *dr.Duration
+		case dr.PlannedDuration != nil:
+			b.Duration = *dr.PlannedDuration
+		case !dr.EndDate.IsZero() && !dr.StartDate.IsZero():
+			b.Duration = dr.EndDate.Sub(dr.StartDate).Seconds()
+		default:
+			return b, false, true
+		}
+		return b, true, true
+	}
+	return adBreak{}, false, false
+}
+
+// endsBreak tells whether a clip carries the end of break b: EXT-X-CUE-IN,
+// or a date range with the ID of b and SCTE35-IN
+func endsBreak(clip *gotio.Clip, b adBreak) bool {
+	hlsMD := namespace(clip.Metadata(), metadataNamespace)
+	if !b.dateRange {
+		for _, line := range asStrings(hlsMD["tags"]) {
+			if name, _, _ := strings.Cut(strings.TrimPrefix(line, "#"), ":"); name == "EXT-X-CUE-IN" {
+				return true
+			}
+		}
+		return false
+	}
+	for _, value := range asStrings(hlsMD["EXT-X-DATERANGE"]) {
+		if dr, err := parseDateRange(ParseAttributeList(value)); err == nil && dr.ID == b.ID && dr.SCTE35In != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fitClips returns the leading clips that fit in room seconds
+func fitClips(clips []trimClip, room float64) []trimClip {
+	var length float64
+	for i, c := range clips {
+		if length+c.duration > room+spliceTolerance {
+			return clips[:i]
+		}
+		length += c.duration
+	}
+	return clips
+}
+
+// addCue adds the cue of break b to clip HLS metadata, moving a date
+// range by shift
+func addCue(hlsMD map[string]interface{}, b adBreak, shift time.Duration) {
+	if !b.dateRange {
+		hlsMD["tags"] = stringList(append(asStrings(hlsMD["tags"]), b.cue))
+		return
+	}
+	cue := map[string]interface{}{"EXT-X-DATERANGE": stringList([]string{b.cue})}
+	shiftDateRanges(cue, shift)
+	hlsMD["EXT-X-DATERANGE"] = stringList(append(asStrings(hlsMD["EXT-X-DATERANGE"]), asStrings(cue["EXT-X-DATERANGE"])...))
+}
+
+// removeCue removes the cue of break b from clip HLS metadata
+func removeCue(hlsMD map[string]interface{}, b adBreak) {
+	key := "tags"
+	if b.dateRange {
+		key = "EXT-X-DATERANGE"
+	}
+	var kept []string
+	for _, value := range asStrings(hlsMD[key]) {
+		if value != b.cue {
+			kept = append(kept, value)
+		}
+	}
+	delete(hlsMD, key)
+	if len(kept) > 0 {
+		hlsMD[key] = stringList(kept)
+	}
+}
+
+func spliceMetadata(index int, kind, id string) map[string]interface{} {
+	md := map[string]interface{}{"break": index, "kind": kind}
+	if id != "" {
+		md["id"] = id
+	}
+	return md
+}
+
+// splicer writes runs of clips from different sources to a track, with a
+// discontinuity between runs
+type splicer struct {
+	out *gotio.Track
+	// seq is the discontinuity sequence number of the last clip written,
+	// and before the first the number the output starts at
+	seq    int64
+	runs   int
+	hasMap bool
+	// nextPDT is the date-time after the last clip written, or zero when
+	// the content has none
+	nextPDT time.Time
+	// dropCue is a cue to remove from the next clip written
+	dropCue *adBreak
+	// sequence is the media sequence number of the first clip written
+	sequence int64
+}
+
+// insert writes a run and returns its length in seconds
+func (s *splicer) insert(clips []trimClip, source *gotio.Track, edit func(i int, hlsMD map[string]interface{})) (float64, error) {
+	var length float64
+	for _, c := range clips {
+		length += c.duration
+	}
+	return length, s.run(clips, source, edit)
+}
+
+// run writes clips of source as one run. Inside it, discontinuities stay
+// where the source has them and date-times keep their spacing.
+func (s *splicer) run(clips []trimClip, source *gotio.Track, edit func(i int, hlsMD map[string]interface{})) error {
+	if len(clips) == 0 {
+		return nil
+	}
+	var shift time.Duration
+	shiftKnown := false
+	var lastSeq int64
+	for i, c := range clips {
+		clipSeq := clipDiscontinuitySequence(c.clip, source)
+		switch {
+		case i == 0 && s.runs == 0:
+		case i == 0 || clipSeq != lastSeq:
+			s.seq++
+		}
+		lastSeq = clipSeq
+
+		_, clipHasMap := namespace(c.clip.Metadata(), streamingMetadataNamespace)["init_uri"].(string)
+		if s.hasMap && !clipHasMap {
+			return fmt.Errorf("clip %q has no initialization section but follows clips with one", c.clip.Name())
+		}
+		s.hasMap = s.hasMap || clipHasMap
+
+		var pdt time.Time
+		if !s.nextPDT.IsZero() {
+			if !c.pdt.IsZero() && !shiftKnown {
+				shift, shiftKnown = s.nextPDT.Sub(c.pdt), true
+			}
+			switch {
+			case c.dated:
+				pdt = c.pdt.Add(shift)
+			case i == 0:
+				pdt = s.nextPDT
+			}
+		}
+
+		seq, drop := s.seq, s.dropCue
+		to := s.sequence + int64(len(s.out.Children()))
+		copied := copyClip(c.clip, func(hlsMD map[string]interface{}) {
+			pinIV(hlsMD, c.sequence, to)
+			delete(hlsMD, "discontinuity_sequence")
+			if seq > 0 {
+				hlsMD["discontinuity_sequence"] = seq
+			}
+			delete(hlsMD, "EXT-X-PROGRAM-DATE-TIME")
+			if !pdt.IsZero() {
+				hlsMD["EXT-X-PROGRAM-DATE-TIME"] = formatDateTime(pdt)
+			}
+			if drop != nil {
+				removeCue(hlsMD, *drop)
+			}
+			if shiftKnown {
+				shiftDateRanges(hlsMD, shift)
+			}
+			if edit != nil {
+				edit(i, hlsMD)
+			}
+		})
+		s.dropCue = nil
+		if err := s.out.AppendChild(copied); err != nil {
+			return err
+		}
+
+		if !pdt.IsZero() {
+			s.nextPDT = pdt
+		}
+		if !s.nextPDT.IsZero() {
+			s.nextPDT = s.nextPDT.Add(seconds(c.duration))
+		}
+	}
+	s.runs++
+	return nil
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+const spliceContent = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-PROGRAM-DATE-TIME:2024-05-01T20:00:00.000Z
+#EXTINF:10.0,
+c0.ts
+#EXTINF:10.0,
+c1.ts
+#EXT-X-CUE-OUT:30
+#EXTINF:10.0,
+c2.ts
+#EXT-X-CUE-OUT-CONT:10/30
+#EXTINF:10.0,
+c3.ts
+#EXT-X-CUE-OUT-CONT:20/30
+#EXTINF:10.0,
+c4.ts
+#EXT-X-CUE-IN
+#EXTINF:10.0,
+c5.ts
+#EXT-X-ENDLIST
+`
+
+const spliceDateRangeContent = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-PROGRAM-DATE-TIME:2024-05-01T20:00:00.000Z
+#EXTINF:10.0,
+c0.ts
+#EXT-X-DATERANGE:ID="mid",START-DATE="2024-05-01T20:00:10.000Z",DURATION=0,SCTE35-OUT=0xFC
+#EXTINF:10.0,
+c1.ts
+#EXTINF:10.0,
+c2.ts
+#EXT-X-ENDLIST
+`
+
+func splicePod(uris ...string) *MediaPlaylist {
+	p := &MediaPlaylist{Version: 3, TargetDuration: 10, EndList: true}
+	for _, uri := range uris {
+		p.Segments = append(p.Segments, &Segment{URI: uri, Duration: 10})
+	}
+	return p
+}
+
+// spliced encodes a spliced timeline and parses it back
+func spliced(t *testing.T, timeline *gotio.Timeline, err error) *MediaPlaylist {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("SpliceAdPlaylists failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return mustUnmarshalMedia(t, buf.String())
+}
+
+func segmentURIs(p *MediaPlaylist) string {
+	var uris []string
+	for _, seg := range p.Segments {
+		if seg.Discontinuity {
+			uris = append(uris, "|")
+		}
+		uris = append(uris, seg.URI)
+	}
+	return strings.Join(uris, " ")
+}
+
+func TestAdBreaks(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(spliceContent)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	breaks, err := AdBreaks(timeline)
+	if err != nil {
+		t.Fatalf("AdBreaks failed: %v", err)
+	}
+	if len(breaks) != 1 || breaks[0].Start != 20 || breaks[0].Duration != 30 {
+		t.Errorf("Expected one 30s break at 20s, got %+v", breaks)
+	}
+}
+
+func TestSpliceAdsReplace(t *testing.T) {
+	content := mustUnmarshalMedia(t, spliceContent)
+	timeline, err := SpliceAdPlaylists(content, []*MediaPlaylist{splicePod("a0.ts", "a1.ts", "a2.ts")}, nil)
+	p := spliced(t, timeline, err)
+
+	if got, want := segmentURIs(p), "c0.ts c1.ts | a0.ts a1.ts a2.ts | c5.ts"; got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+	if len(p.Segments[2].Tags) != 1 || p.Segments[2].Tags[0].Name != "EXT-X-CUE-OUT" {
+		t.Errorf("Expected the cue on the first ad, got %v", p.Segments[2].Tags)
+	}
+	if got := formatDateTime(p.Segments[2].ProgramDateTime); got != "2024-05-01T20:00:20.000Z" {
+		t.Errorf("Expected the pod to start at 20:00:20, got %s", got)
+	}
+	if got := formatDateTime(p.Segments[5].ProgramDateTime); got != "2024-05-01T20:00:50.000Z" {
+		t.Errorf("Expected the content to resume at 20:00:50, got %s", got)
+	}
+
+	clip := firstTrack(t, timeline).Children()[3].(*gotio.Clip)
+	splice, _ := asMap(namespace(clip.Metadata(), metadataNamespace)[spliceKey])
+	if splice["break"] != 0 || splice["kind"] != "ad" {
+		t.Errorf("Expected splice metadata on the ad, got %v", splice)
+	}
+}
+
+func TestSpliceAdsSlate(t *testing.T) {
+	content := mustUnmarshalMedia(t, spliceContent)
+	slate := &MediaPlaylist{Version: 3, TargetDuration: 6, EndList: true,
+		Segments: []*Segment{{URI: "slate.ts", Duration: 6}}}
+	timeline, err := SpliceAdPlaylists(content, []*MediaPlaylist{splicePod("a0.ts")}, slate)
+	p := spliced(t, timeline, err)
+
+	want := "c0.ts c1.ts | a0.ts | slate.ts | slate.ts | slate.ts | slate.ts | c5.ts"
+	if got := segmentURIs(p); got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+	// Four slates fill the remaining 20s with 4s to spare, which the last
+	// records and the content after the break moves on by
+	clip := firstTrack(t, timeline).Children()[6].(*gotio.Clip)
+	hlsMD := namespace(clip.Metadata(), metadataNamespace)
+	if splice, _ := asMap(hlsMD[spliceKey]); splice["kind"] != "slate" || hlsMD[outOffsetKey] != 2.0 {
+		t.Errorf("Expected the last slate cut at 2s, got %v", hlsMD)
+	}
+	if got := formatDateTime(p.Segments[7].ProgramDateTime); got != "2024-05-01T20:00:54.000Z" {
+		t.Errorf("Expected the content to resume at 20:00:54, got %s", got)
+	}
+}
+
+func TestSpliceAdsPartialFill(t *testing.T) {
+	content := mustUnmarshalMedia(t, spliceContent)
+	timeline, err := SpliceAdPlaylists(content, []*MediaPlaylist{splicePod("a0.ts")}, nil)
+	p := spliced(t, timeline, err)
+
+	if got, want := segmentURIs(p), "c0.ts c1.ts | a0.ts | c3.ts c4.ts c5.ts"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestSpliceAdsInsert(t *testing.T) {
+	content := mustUnmarshalMedia(t, spliceDateRangeContent)
+	timeline, err := SpliceAdPlaylists(content, []*MediaPlaylist{splicePod("a0.ts", "a1.ts")}, nil)
+	p := spliced(t, timeline, err)
+
+	if got, want := segmentURIs(p), "c0.ts | a0.ts a1.ts | c1.ts c2.ts"; got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+	if len(p.Segments[1].DateRanges) != 1 || p.Segments[1].DateRanges[0].ID != "mid" {
+		t.Errorf("Expected the cue on the first ad, got %v", p.Segments[1].DateRanges)
+	}
+	if len(p.Segments[3].DateRanges) != 0 {
+		t.Errorf("Expected the cue gone from the content, got %v", p.Segments[3].DateRanges)
+	}
+	// The content after the pod moves on by its 20s
+	if got := formatDateTime(p.Segments[3].ProgramDateTime); got != "2024-05-01T20:00:30.000Z" {
+		t.Errorf("Expected the content to resume at 20:00:30, got %s", got)
+	}
+}
+
+func TestSpliceAdsPreRoll(t *testing.T) {
+	content := mustUnmarshalMedia(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-DISCONTINUITY-SEQUENCE:7
+#EXT-X-PROGRAM-DATE-TIME:2024-05-01T20:00:00.000Z
+#EXT-X-DATERANGE:ID="pre",START-DATE="2024-05-01T20:00:00.000Z",DURATION=20,SCTE35-OUT=0xFC
+#EXTINF:10.0,
+c0.ts
+#EXTINF:10.0,
+c1.ts
+#EXTINF:10.0,
+c2.ts
+#EXT-X-ENDLIST
+`)
+	timeline, err := SpliceAdPlaylists(content, []*MediaPlaylist{splicePod("a0.ts", "a1.ts")}, nil)
+	p := spliced(t, timeline, err)
+
+	if got, want := segmentURIs(p), "a0.ts a1.ts | c2.ts"; got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+	if p.DiscontinuitySequence != 7 {
+		t.Errorf("Expected the content's discontinuity sequence 7, got %d", p.DiscontinuitySequence)
+	}
+}
+
+func TestSpliceAdsPinsIVs(t *testing.T) {
+	content := mustUnmarshalMedia(t, strings.Replace(spliceDateRangeContent,
+		"#EXT-X-TARGETDURATION:10\n", "#EXT-X-TARGETDURATION:10\n#EXT-X-KEY:METHOD=AES-128,URI=\"k.bin\"\n", 1))
+	timeline, err := SpliceAdPlaylists(content, []*MediaPlaylist{splicePod("a0.ts", "a1.ts")}, nil)
+	p := spliced(t, timeline, err)
+
+	// The content after the pod moves from media sequence 1 and 2 to 3
+	// and 4, so it keeps its IVs explicitly
+	for i, want := range map[int]string{0: "", 3: "0x00000000000000000000000000000001", 4: "0x00000000000000000000000000000002"} {
+		if key := p.Segments[i].Key; key == nil || key.IV != want {
+			t.Errorf("Segment %d: expected IV %q, got key %v", i, want, key)
+		}
+	}
+}
+
+func TestSpliceAdsErrors(t *testing.T) {
+	content := mustUnmarshalMedia(t, spliceContent)
+	pods := []*MediaPlaylist{splicePod("a0.ts"), splicePod("a1.ts")}
+	if _, err := SpliceAdPlaylists(content, pods, nil); err == nil {
+		t.Error("Expected error for more pods than breaks")
+	}
+
+	fmp4 := splicePod("a0.m4s")
+	fmp4.Segments[0].Map = &Map{URI: "init.mp4"}
+	if _, err := SpliceAdPlaylists(content, []*MediaPlaylist{fmp4}, nil); err == nil {
+		t.Error("Expected error for MPEG-TS content after fMP4 ads")
+	}
+
+	long := splicePod("a0.ts")
+	long.Segments[0].Duration = 40
+	if _, err := SpliceAdPlaylists(content, []*MediaPlaylist{long}, nil); err == nil {
+		t.Error("Expected error for a pod with no ad that fits its break")
+	}
+	if _, err := SpliceAdPlaylists(content, []*MediaPlaylist{splicePod()}, nil); err == nil {
+		t.Error("Expected error for an empty pod")
+	}
+}
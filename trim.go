@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// Clip HLS metadata keys for the part of a segment that belongs to a cut,
+// in seconds from the start of the segment
+const (
+	inOffsetKey  = "in_offset"
+	outOffsetKey = "out_offset"
+)
+
+// Trim cuts a single-track timeline down to the range from start to end,
+// measured from the beginning of the playlist. It keeps every segment that
+// overlaps the range, so the first and last may run over it: the first
+// clip records where the range begins inside it as in_offset metadata and
+// the last where it ends as out_offset, and the track gets an
+// EXT-X-START:TIME-OFFSET=...,PRECISE=YES so players start on the frame.
+//
+// The media sequence and discontinuity sequence move up to the first
+// segment kept, which keeps implicit AES-128 IVs the same, and the first
+// segment gets a program date-time when the timeline has them. The result
+// is a finished playlist: it has EXT-X-ENDLIST, and an EVENT playlist
+// becomes VOD. The input is not changed.
+func Trim(t *gotio.Timeline, start, end time.Duration) (*gotio.Timeline, error) {
+	track, err := singleTrack(t)
+	if err != nil {
+		return nil, err
+	}
+	return trimTrack(t, track, trimClips(track), start.Seconds(), end.Seconds())
+}
+
+// TrimDates is Trim for the wall-clock window from one time to another,
+// placed by the program date-times of the timeline
+func TrimDates(t *gotio.Timeline, from, to time.Time) (*gotio.Timeline, error) {
+	track, err := singleTrack(t)
+	if err != nil {
+		return nil, err
+	}
+	clips := trimClips(track)
+	if len(clips) == 0 || clips[0].pdt.IsZero() {
+		return nil, fmt.Errorf("timeline has no program date-times")
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("window ends at %s, not after its start %s", formatDateTime(to), formatDateTime(from))
+	}
+
+	// Date-times may jump at discontinuities, so each end of the window is
+	// placed within the clip whose date-times cover it
+	start, end := -1.0, -1.0
+	for _, c := range clips {
+		if start < 0 && c.pdt.Add(seconds(c.duration)).After(from) {
+			start = c.start + max(0, from.Sub(c.pdt).Seconds())
+		}
+		if c.pdt.Before(to) {
+			end = c.start + min(c.duration, to.Sub(c.pdt).Seconds())
+		}
+	}
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("window %s to %s is outside the timeline", formatDateTime(from), formatDateTime(to))
+	}
+	return trimTrack(t, track, clips, start, end)
+}
+
+// trimClip is a clip placed on its track
+type trimClip struct {
+	clip *gotio.Clip
+	// start and duration are in seconds from the start of the track
+	start, duration float64
+	// pdt is zero when the track has no program date-times, and dated
+	// tells whether the clip gives its own rather than one counted
+	pdt   time.Time
+	dated bool
+	// sequence is the media sequence number of the clip
+	sequence int64
+}
+
+// trimClips places the clips of a track, with the date-time of each
+// counted on from the last one given or back from the first
+func trimClips(track *gotio.Track) []trimClip {
+	var clips []trimClip
+	var position float64
+	var next time.Time
+	firstPDT := -1
+	mediaSequence, _ := asInt64(namespace(track.Metadata(), metadataNamespace)["media_sequence"])
+	for _, child := range track.Children() {
+		clip, ok := child.(*gotio.Clip)
+		if !ok {
+			continue
+		}
+		c := trimClip{clip: clip, start: position, sequence: mediaSequence + int64(len(clips))}
+		if d, err := clip.Duration(); err == nil {
+			c.duration = d.ToSeconds()
+		}
+		if value, ok := namespace(clip.Metadata(), metadataNamespace)["EXT-X-PROGRAM-DATE-TIME"].(string); ok {
+			c.pdt, _ = parseDateTime(value)
+			c.dated = !c.pdt.IsZero()
+		}
+		if c.dated && firstPDT < 0 {
+			firstPDT = len(clips)
+		}
+		if c.pdt.IsZero() {
+			c.pdt = next
+		}
+		if !c.pdt.IsZero() {
+			next = c.pdt.Add(seconds(c.duration))
+		}
+		clips = append(clips, c)
+		position += c.duration
+	}
+	for i := firstPDT - 1; i >= 0; i-- {
+		clips[i].pdt = clips[i+1].pdt.Add(-seconds(clips[i].duration))
+	}
+	return clips
+}
+
+// trimTrack copies the clips of track that overlap start to end seconds
+// into a new timeline
+func trimTrack(t *gotio.Timeline, track *gotio.Track, clips []trimClip, start, end float64) (*gotio.Timeline, error) {
+	first, last, err := overlapping(clips, start, end)
+	if err != nil {
+		return nil, err
+	}
+	inOffset := roundOffset(max(0, start-clips[first].start))
+	outOffset := roundOffset(end - clips[last].start)
+
+	header := copyMetadata(namespace(track.Metadata(), metadataNamespace)).(map[string]interface{})
+	mediaSequence, _ := asInt64(header["media_sequence"])
+	header["media_sequence"] = mediaSequence + int64(first)
+	delete(header, "discontinuity_sequence")
+	if seq := clipDiscontinuitySequence(clips[first].clip, track); seq > 0 {
+		header["discontinuity_sequence"] = seq
+	}
+	// Parts after the last segment would follow the end of the trim
+	delete(header, "EXT-X-PART")
+	header["EXT-X-START"] = startString(&Start{TimeOffset: inOffset, Precise: true})
+	header["end_list"] = true
+	if header["playlist_type"] == string(PlaylistTypeEvent) {
+		header["playlist_type"] = string(PlaylistTypeVOD)
+	}
+
+	out := derivedTrack(track, header)
+
+	for i := first; i <= last; i++ {
+		c := clips[i]
+		copied := copyClip(c.clip, func(hlsMD map[string]interface{}) {
+			delete(hlsMD, inOffsetKey)
+			delete(hlsMD, outOffsetKey)
+			if i == first {
+				if !c.pdt.IsZero() {
+					hlsMD["EXT-X-PROGRAM-DATE-TIME"] = formatDateTime(c.pdt)
+				}
+				if inOffset > 0 {
+					hlsMD[inOffsetKey] = inOffset
+				}
+			}
+			if i == last && outOffset < c.duration {
+				hlsMD[outOffsetKey] = outOffset
+			}
+		})
+		if err := out.AppendChild(copied); err != nil {
+			return nil, err
+		}
+	}
+
+	return derivedTimeline(t, out)
+}
+
+// overlapping returns the indexes of the first and last clips that overlap
+// start to end seconds
+func overlapping(clips []trimClip, start, end float64) (int, int, error) {
+	if end <= start {
+		return 0, 0, fmt.Errorf("range ends at %gs, not after its start %gs", end, start)
+	}
+	first, last := -1, -1
+	for i, c := range clips {
+		if c.start+c.duration > start && c.start < end {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return 0, 0, fmt.Errorf("range %gs to %gs is outside the timeline", start, end)
+	}
+	return first, last, nil
+}
+
+// clipDiscontinuitySequence returns the discontinuity sequence number of a
+// clip, which is that of its track when the clip does not set one
+func clipDiscontinuitySequence(clip *gotio.Clip, track *gotio.Track) int64 {
+	if seq, ok := asInt64(namespace(clip.Metadata(), metadataNamespace)["discontinuity_sequence"]); ok {
+		return seq
+	}
+	seq, _ := asInt64(namespace(track.Metadata(), metadataNamespace)["discontinuity_sequence"])
+	return seq
+}
+
+// roundOffset rounds an offset in seconds to microseconds, the precision
+// EXTINF durations are written with
+func roundOffset(s float64) float64 {
+	return math.Round(s*1e6) / 1e6
+}
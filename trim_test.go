@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+const trimTestPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-KEY:METHOD=AES-128,URI="key1.bin"
+#EXT-X-PROGRAM-DATE-TIME:2024-03-01T14:00:00.000Z
+#EXTINF:10.0,
+seg0.ts
+#EXTINF:10.0,
+seg1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=AES-128,URI="key2.bin"
+#EXTINF:10.0,
+seg2.ts
+#EXTINF:10.0,
+seg3.ts
+#EXTINF:10.0,
+seg4.ts
+`
+
+func trimmedPlaylist(t *testing.T, timeline *gotio.Timeline) (*MediaPlaylist, *gotio.Track) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return mustUnmarshalMedia(t, buf.String()), firstTrack(t, timeline)
+}
+
+func TestTrim(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(trimTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	trimmed, err := Trim(timeline, 12500*time.Millisecond, 33*time.Second)
+	if err != nil {
+		t.Fatalf("Trim failed: %v", err)
+	}
+	p, track := trimmedPlaylist(t, trimmed)
+
+	var uris []string
+	for _, seg := range p.Segments {
+		uris = append(uris, seg.URI)
+	}
+	if got := strings.Join(uris, " "); got != "seg1.ts seg2.ts seg3.ts" {
+		t.Fatalf("Expected seg1.ts to seg3.ts, got %s", got)
+	}
+	if p.MediaSequence != 101 || p.DiscontinuitySequence != 0 {
+		t.Errorf("Expected media sequence 101 and discontinuity sequence 0, got %d and %d", p.MediaSequence, p.DiscontinuitySequence)
+	}
+	if p.Start == nil || p.Start.TimeOffset != 2.5 || !p.Start.Precise {
+		t.Errorf("Expected EXT-X-START:TIME-OFFSET=2.5,PRECISE=YES, got %+v", p.Start)
+	}
+	if !p.EndList || p.PlaylistType != PlaylistTypeVOD {
+		t.Errorf("Expected a finished VOD playlist, got type %q, end list %v", p.PlaylistType, p.EndList)
+	}
+	if got := formatDateTime(p.Segments[0].ProgramDateTime); got != "2024-03-01T14:00:10.000Z" {
+		t.Errorf("Expected first date-time 14:00:10, got %s", got)
+	}
+	if p.Segments[0].Key == nil || p.Segments[0].Key.URI != "key1.bin" || p.Segments[1].Key.URI != "key2.bin" {
+		t.Errorf("Expected keys kept with their segments, got %v and %v", p.Segments[0].Key, p.Segments[1].Key)
+	}
+	if !p.Segments[1].Discontinuity {
+		t.Error("Expected the discontinuity before seg2.ts kept")
+	}
+
+	clips := track.Children()
+	in := namespace(clips[0].(*gotio.Clip).Metadata(), metadataNamespace)[inOffsetKey]
+	out := namespace(clips[2].(*gotio.Clip).Metadata(), metadataNamespace)[outOffsetKey]
+	if in != 2.5 || out != 3.0 {
+		t.Errorf("Expected in offset 2.5 and out offset 3, got %v and %v", in, out)
+	}
+}
+
+func TestTrimDates(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(trimTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	from := time.Date(2024, 3, 1, 14, 0, 20, 0, time.UTC)
+	trimmed, err := TrimDates(timeline, from, from.Add(20*time.Second))
+	if err != nil {
+		t.Fatalf("TrimDates failed: %v", err)
+	}
+	p, track := trimmedPlaylist(t, trimmed)
+	if len(p.Segments) != 2 || p.Segments[0].URI != "seg2.ts" || p.Segments[1].URI != "seg3.ts" {
+		t.Fatalf("Expected seg2.ts and seg3.ts, got %v", p.Segments)
+	}
+	if p.DiscontinuitySequence != 1 || p.Segments[0].Discontinuity {
+		t.Errorf("Expected discontinuity sequence 1 and no leading discontinuity, got %d and %v",
+			p.DiscontinuitySequence, p.Segments[0].Discontinuity)
+	}
+	if p.Start == nil || p.Start.TimeOffset != 0 {
+		t.Errorf("Expected a zero start offset, got %+v", p.Start)
+	}
+	for _, child := range track.Children() {
+		md := namespace(child.(*gotio.Clip).Metadata(), metadataNamespace)
+		if _, ok := md[inOffsetKey]; ok {
+			t.Errorf("Expected no in offset on a whole segment, got %v", md)
+		}
+		if _, ok := md[outOffsetKey]; ok {
+			t.Errorf("Expected no out offset on a whole segment, got %v", md)
+		}
+	}
+}
+
+func TestTrimLowLatency(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(`#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-PART-INF:PART-TARGET=1
+#EXT-X-PART:DURATION=1,URI="seg0.0.mp4"
+#EXTINF:4,
+seg0.mp4
+#EXTINF:4,
+seg1.mp4
+#EXT-X-PART:DURATION=1,URI="seg2.0.mp4"
+`)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	trimmed, err := Trim(timeline, 0, 4*time.Second)
+	if err != nil {
+		t.Fatalf("Trim failed: %v", err)
+	}
+	p, _ := trimmedPlaylist(t, trimmed)
+	if len(p.Segments) != 1 || len(p.Parts) != 0 || !p.EndList {
+		t.Errorf("Expected seg0.mp4 without the trailing parts, got %d segments and parts %v", len(p.Segments), p.Parts)
+	}
+	if len(p.Segments[0].Parts) != 1 {
+		t.Errorf("Expected the parts of seg0.mp4 kept, got %v", p.Segments[0].Parts)
+	}
+}
+
+func TestTrimErrors(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(trimTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, err := Trim(timeline, 60*time.Second, 70*time.Second); err == nil {
+		t.Error("Expected error for a range after the end")
+	}
+	if _, err := Trim(timeline, 20*time.Second, 10*time.Second); err == nil {
+		t.Error("Expected error for a reversed range")
+	}
+	from := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	if _, err := TrimDates(timeline, from, from.Add(time.Hour)); err == nil {
+		t.Error("Expected error for a window after the recording")
+	}
+
+	plain, err := NewDecoder(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.0,\nad0.ts\n")).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, err := TrimDates(plain, from, from.Add(time.Hour)); err == nil {
+		t.Error("Expected error for a timeline without date-times")
+	}
+}
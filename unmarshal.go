@@ -0,0 +1,768 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyntaxError reports a malformed playlist line found by a strict decoder
+type SyntaxError struct {
+	Line int
+	Tag  string
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Tag == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: malformed #%s: %v", e.Line, e.Tag, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// readEntries reads and parses all entries from a playlist, enforcing the
+// line length and size limits
+func readEntries(ctx context.Context, r io.Reader, limits Limits) ([]*PlaylistEntry, error) {
+	var entries []*PlaylistEntry
+
+	if limits.MaxBytes > 0 {
+		r = &limitedReader{r: r, max: limits.MaxBytes}
+	}
+	scanner := bufio.NewScanner(r)
+	if limits.MaxLineLength > 0 {
+		// The scanner needs room for the line terminator as well
+		scanner.Buffer(make([]byte, 0, min(limits.MaxLineLength+2, 64*1024)), limits.MaxLineLength+2)
+	}
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if lineNumber%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		line := scanner.Text()
+		if limits.MaxLineLength > 0 && len(line) > limits.MaxLineLength {
+			return nil, &LimitError{Kind: LimitLineLength, Max: int64(limits.MaxLineLength), Line: lineNumber}
+		}
+		entry := ParsePlaylistEntry(line)
+		if entry != nil {
+			entry.Line = lineNumber
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		var limitErr *LimitError
+		switch {
+		case errors.As(err, &limitErr):
+			return nil, limitErr
+		case errors.Is(err, bufio.ErrTooLong):
+			return nil, &LimitError{Kind: LimitLineLength, Max: int64(limits.MaxLineLength), Line: lineNumber + 1}
+		}
+		return nil, fmt.Errorf("error reading playlist: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Validate that it's an HLS playlist
+	if len(entries) == 0 || !entries[0].IsTag("EXTM3U") {
+		return nil, fmt.Errorf("not a valid M3U8 playlist")
+	}
+
+	return entries, nil
+}
+
+// parser turns playlist entries into the typed playlist model
+type parser struct {
+	limits Limits
+	strict bool
+}
+
+func newParser(limits Limits, strict bool) *parser {
+	return &parser{limits: limits, strict: strict}
+}
+
+// isMultivariant reports whether the entries form a multivariant playlist
+func isMultivariant(entries []*PlaylistEntry) bool {
+	for _, entry := range entries {
+		switch {
+		case entry.IsTag("EXTINF"), entry.IsTag("EXT-X-TARGETDURATION"):
+			return false
+		case entry.IsTag("EXT-X-STREAM-INF"), entry.IsTag("EXT-X-I-FRAME-STREAM-INF"), entry.IsTag("EXT-X-MEDIA"):
+			return true
+		}
+	}
+	return false
+}
+
+// parse parses entries into a media or multivariant playlist
+func (p *parser) parse(ctx context.Context, entries []*PlaylistEntry) (Playlist, error) {
+	if isMultivariant(entries) {
+		return p.parseMultivariantPlaylist(ctx, entries)
+	}
+	return p.parseMediaPlaylist(ctx, entries)
+}
+
+// malformed returns a SyntaxError in strict mode and nil otherwise
+func (p *parser) malformed(entry *PlaylistEntry, err error) error {
+	if !p.strict {
+		return nil
+	}
+	return &SyntaxError{Line: entry.Line, Tag: entry.Tag, Err: err}
+}
+
+// attributes parses the entry value as an attribute list
+func (p *parser) attributes(entry *PlaylistEntry) (AttributeList, error) {
+	attrs, err := parseAttributeList(entry.Value, p.limits.MaxAttributes)
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		limitErr.Line = entry.Line
+	}
+	return attrs, err
+}
+
+// parseDateTime parses an ISO 8601 date with time zone
+func parseDateTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		// Some packagers omit the colon in the zone offset
+		if t2, err2 := time.Parse("2006-01-02T15:04:05.999999999Z0700", s); err2 == nil {
+			return t2, nil
+		}
+	}
+	return t, err
+}
+
+// parseByterangeValue parses "n[@o]", reporting whether the offset was given
+func parseByterangeValue(s string) (*Byterange, bool, error) {
+	s = strings.TrimSpace(s)
+	br, err := NewByterangeFromString(s)
+	if err != nil {
+		return nil, false, err
+	}
+	return br, strings.Contains(s, "@"), nil
+}
+
+// takeString removes and returns an attribute
+func takeString(attrs AttributeList, name string) string {
+	value := attrs[name]
+	delete(attrs, name)
+	return value
+}
+
+// takeInt removes and returns an integer attribute. A present but
+// malformed value is reported through bad.
+func takeInt(attrs AttributeList, name string, bad *error) int64 {
+	value, ok := attrs[name]
+	if !ok {
+		return 0
+	}
+	delete(attrs, name)
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil && *bad == nil {
+		*bad = fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n
+}
+
+// takeFloat removes and returns a decimal-floating-point attribute
+func takeFloat(attrs AttributeList, name string, bad *error) float64 {
+	value, ok := attrs[name]
+	if !ok {
+		return 0
+	}
+	delete(attrs, name)
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil && *bad == nil {
+		*bad = fmt.Errorf("invalid %s %q", name, value)
+	}
+	return f
+}
+
+// takeYes removes an enumerated YES/NO attribute and reports whether it
+// was YES
+func takeYes(attrs AttributeList, name string) bool {
+	return takeString(attrs, name) == "YES"
+}
+
+func parseStart(attrs AttributeList) (*Start, error) {
+	var bad error
+	if _, ok := attrs["TIME-OFFSET"]; !ok {
+		return nil, errors.New("missing TIME-OFFSET attribute")
+	}
+	s := &Start{TimeOffset: takeFloat(attrs, "TIME-OFFSET", &bad)}
+	s.Precise = takeYes(attrs, "PRECISE")
+	return s, bad
+}
+
+func parseDefine(attrs AttributeList) (Define, error) {
+	d := Define{
+		Name:       attrs.Get("NAME"),
+		Value:      attrs.Get("VALUE"),
+		Import:     attrs.Get("IMPORT"),
+		QueryParam: attrs.Get("QUERYPARAM"),
+	}
+	if d.Name == "" && d.Import == "" && d.QueryParam == "" {
+		return d, errors.New("missing NAME, IMPORT or QUERYPARAM attribute")
+	}
+	return d, nil
+}
+
+func parseKey(attrs AttributeList) (*Key, error) {
+	k := &Key{
+		Method:            attrs.Get("METHOD"),
+		URI:               attrs.Get("URI"),
+		IV:                attrs.Get("IV"),
+		KeyFormat:         attrs.Get("KEYFORMAT"),
+		KeyFormatVersions: attrs.Get("KEYFORMATVERSIONS"),
+	}
+	if k.Method == "" {
+		return k, errors.New("missing METHOD attribute")
+	}
+	return k, nil
+}
+
+func parseMap(attrs AttributeList) (*Map, error) {
+	m := &Map{URI: attrs.Get("URI")}
+	if m.URI == "" {
+		return m, errors.New("missing URI attribute")
+	}
+	if value := attrs.Get("BYTERANGE"); value != "" {
+		br, err := NewByterangeFromString(value)
+		if err != nil {
+			return m, err
+		}
+		m.Byterange = br
+	}
+	return m, nil
+}
+
+func parseDateRange(attrs AttributeList) (*DateRange, error) {
+	var bad error
+	r := &DateRange{
+		ID:        takeString(attrs, "ID"),
+		Class:     takeString(attrs, "CLASS"),
+		Cue:       takeString(attrs, "CUE"),
+		SCTE35Cmd: takeString(attrs, "SCTE35-CMD"),
+		SCTE35Out: takeString(attrs, "SCTE35-OUT"),
+		SCTE35In:  takeString(attrs, "SCTE35-IN"),
+		EndOnNext: takeYes(attrs, "END-ON-NEXT"),
+	}
+	if r.ID == "" {
+		return r, errors.New("missing ID attribute")
+	}
+	if value := takeString(attrs, "START-DATE"); value != "" {
+		t, err := parseDateTime(value)
+		if err != nil {
+			return r, err
+		}
+		r.StartDate = t
+	}
+	if value := takeString(attrs, "END-DATE"); value != "" {
+		t, err := parseDateTime(value)
+		if err != nil {
+			return r, err
+		}
+		r.EndDate = t
+	}
+	if _, ok := attrs["DURATION"]; ok {
+		d := takeFloat(attrs, "DURATION", &bad)
+		r.Duration = &d
+	}
+	if _, ok := attrs["PLANNED-DURATION"]; ok {
+		d := takeFloat(attrs, "PLANNED-DURATION", &bad)
+		r.PlannedDuration = &d
+	}
+	if len(attrs) > 0 {
+		r.ClientAttributes = map[string]string(attrs)
+	}
+	return r, bad
+}
+
+func parsePartialSegment(attrs AttributeList) (*PartialSegment, error) {
+	var bad error
+	part := &PartialSegment{
+		URI:         takeString(attrs, "URI"),
+		Duration:    takeFloat(attrs, "DURATION", &bad),
+		Independent: takeYes(attrs, "INDEPENDENT"),
+		Gap:         takeYes(attrs, "GAP"),
+	}
+	if part.URI == "" {
+		return part, errors.New("missing URI attribute")
+	}
+	if value := takeString(attrs, "BYTERANGE"); value != "" {
+		br, err := NewByterangeFromString(value)
+		if err != nil {
+			return part, err
+		}
+		part.Byterange = br
+	}
+	return part, bad
+}
+
+// parseMediaPlaylist parses entries as a media playlist
+func (p *parser) parseMediaPlaylist(ctx context.Context, entries []*PlaylistEntry) (*MediaPlaylist, error) {
+	pl := &MediaPlaylist{}
+
+	var (
+		seg         = &Segment{}
+		hasDuration bool
+		started     bool
+		key         *Key
+		segmentMap  *Map
+		bitrate     int64
+		last        *Segment
+		// implicitRange is set when the EXT-X-BYTERANGE of seg has no
+		// offset, which can only be resolved once its URI is known
+		implicitRange bool
+	)
+
+	// keep records an unmodelled entry at playlist or segment level
+	keep := func(tag Tag) {
+		if started {
+			seg.Tags = append(seg.Tags, tag)
+		} else {
+			pl.Tags = append(pl.Tags, tag)
+		}
+	}
+
+	for i, entry := range entries[1:] {
+		if i%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		if entry.Type == EntryTypeComment {
+			keep(Tag{Value: entry.Value})
+			continue
+		}
+
+		if entry.Type == EntryTypeURI {
+			if p.limits.MaxSegments > 0 && len(pl.Segments) >= p.limits.MaxSegments {
+				return nil, &LimitError{Kind: LimitSegments, Max: int64(p.limits.MaxSegments), Line: entry.Line}
+			}
+			if !hasDuration && p.strict {
+				return nil, &SyntaxError{Line: entry.Line, Err: errors.New("segment URI without #EXTINF")}
+			}
+
+			// Without an offset the range follows the previous sub-range
+			// of the same resource, and is an error after any other
+			if implicitRange {
+				if last != nil && last.Byterange != nil && last.URI == entry.URI {
+					seg.Byterange.Offset = last.Byterange.Offset + last.Byterange.Count
+				} else if err := p.malformed(entry, fmt.Errorf("EXT-X-BYTERANGE without offset does not follow a sub-range of %s", entry.URI)); err != nil {
+					return nil, err
+				}
+				implicitRange = false
+			}
+
+			seg.URI = entry.URI
+			seg.Line = entry.Line
+			seg.Key = key
+			seg.Map = segmentMap
+			seg.Bitrate = bitrate
+			pl.Segments = append(pl.Segments, seg)
+
+			last = seg
+			seg = &Segment{}
+			hasDuration = false
+			started = true
+			continue
+		}
+
+		value := strings.TrimSpace(entry.Value)
+		switch entry.Tag {
+		case "EXT-X-VERSION", "EXT-X-TARGETDURATION", "EXT-X-MEDIA-SEQUENCE", "EXT-X-DISCONTINUITY-SEQUENCE":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				if err := p.malformed(entry, err); err != nil {
+					return nil, err
+				}
+			}
+			switch entry.Tag {
+			case "EXT-X-VERSION":
+				pl.Version = n
+			case "EXT-X-TARGETDURATION":
+				pl.TargetDuration = n
+			case "EXT-X-MEDIA-SEQUENCE":
+				pl.MediaSequence = n
+			default:
+				pl.DiscontinuitySequence = n
+			}
+
+		case "EXT-X-PLAYLIST-TYPE":
+			pl.PlaylistType = PlaylistType(value)
+
+		case "EXT-X-I-FRAMES-ONLY":
+			pl.IFramesOnly = true
+
+		case "EXT-X-INDEPENDENT-SEGMENTS":
+			pl.IndependentSegments = true
+
+		case "EXT-X-ENDLIST":
+			pl.EndList = true
+
+		case "EXT-X-PART-INF":
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			var bad error
+			pl.PartTarget = takeFloat(attrs, "PART-TARGET", &bad)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+
+		case "EXT-X-START":
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			start, bad := parseStart(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			pl.Start = start
+
+		case "EXT-X-DEFINE":
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			define, bad := parseDefine(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			pl.Defines = append(pl.Defines, define)
+
+		case "EXT-X-MAP":
+			// Parse MAP tag for initialization data
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			m, bad := parseMap(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			segmentMap = m
+
+		case "EXT-X-KEY":
+			// Store encryption key info for subsequent segments
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			k, bad := parseKey(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			key = k
+			if k.Method == "NONE" {
+				key = nil
+			}
+
+		case "EXTINF":
+			// Parse duration and optional title
+			parts := strings.SplitN(entry.Value, ",", 2)
+			duration, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+			if err != nil || duration < 0 {
+				if err := p.malformed(entry, fmt.Errorf("invalid duration %q", parts[0])); err != nil {
+					return nil, err
+				}
+			}
+			seg.Duration = duration
+			if len(parts) > 1 {
+				seg.Title = strings.TrimSpace(parts[1])
+			}
+			hasDuration = true
+			started = true
+
+		case "EXT-X-BYTERANGE":
+			br, hasOffset, err := parseByterangeValue(value)
+			if err != nil {
+				if err := p.malformed(entry, err); err != nil {
+					return nil, err
+				}
+				break
+			}
+			seg.Byterange = br
+			implicitRange = !hasOffset
+			started = true
+
+		case "EXT-X-PROGRAM-DATE-TIME":
+			t, err := parseDateTime(value)
+			if err != nil {
+				if err := p.malformed(entry, err); err != nil {
+					return nil, err
+				}
+				keep(Tag{Name: entry.Tag, Value: entry.Value})
+				break
+			}
+			seg.ProgramDateTime = t
+			started = true
+
+		case "EXT-X-DATERANGE":
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			dr, bad := parseDateRange(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			seg.DateRanges = append(seg.DateRanges, dr)
+			started = true
+
+		case "EXT-X-DISCONTINUITY":
+			seg.Discontinuity = true
+			started = true
+
+		case "EXT-X-GAP":
+			seg.Gap = true
+			started = true
+
+		case "EXT-X-BITRATE":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				if err := p.malformed(entry, err); err != nil {
+					return nil, err
+				}
+			}
+			bitrate = n
+			started = true
+
+		case "EXT-X-PART":
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			part, bad := parsePartialSegment(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			seg.Parts = append(seg.Parts, part)
+			started = true
+
+		default:
+			keep(Tag{Name: entry.Tag, Value: entry.Value})
+		}
+	}
+
+	// Partial segments and tags after the last URI belong to the segment
+	// still being produced
+	pl.Parts = seg.Parts
+	pl.Tags = append(pl.Tags, seg.Tags...)
+
+	return pl, nil
+}
+
+func parseVariant(attrs AttributeList, iframe bool) (*Variant, error) {
+	var bad error
+	v := &Variant{IFrame: iframe}
+	if _, ok := attrs["BANDWIDTH"]; !ok {
+		bad = errors.New("missing BANDWIDTH attribute")
+	}
+	v.Bandwidth = takeInt(attrs, "BANDWIDTH", &bad)
+	v.AverageBandwidth = takeInt(attrs, "AVERAGE-BANDWIDTH", &bad)
+	v.Codecs = takeString(attrs, "CODECS")
+	if value := takeString(attrs, "RESOLUTION"); value != "" {
+		var res Resolution
+		if _, err := fmt.Sscanf(value, "%dx%d", &res.Width, &res.Height); err != nil && bad == nil {
+			bad = fmt.Errorf("invalid RESOLUTION %q", value)
+		}
+		v.Resolution = &res
+	}
+	v.FrameRate = takeFloat(attrs, "FRAME-RATE", &bad)
+	v.HDCPLevel = takeString(attrs, "HDCP-LEVEL")
+	v.Audio = takeString(attrs, "AUDIO")
+	v.Video = takeString(attrs, "VIDEO")
+	v.Subtitles = takeString(attrs, "SUBTITLES")
+	v.ClosedCaptions = takeString(attrs, "CLOSED-CAPTIONS")
+	v.Score = takeFloat(attrs, "SCORE", &bad)
+	v.SupplementalCodecs = takeString(attrs, "SUPPLEMENTAL-CODECS")
+	v.VideoRange = VideoRange(takeString(attrs, "VIDEO-RANGE"))
+	v.AllowedCPC = takeString(attrs, "ALLOWED-CPC")
+	v.StableVariantID = takeString(attrs, "STABLE-VARIANT-ID")
+	v.PathwayID = takeString(attrs, "PATHWAY-ID")
+	v.ReqVideoLayout = takeString(attrs, "REQ-VIDEO-LAYOUT")
+	if err := checkVariant(v); err != nil && bad == nil {
+		bad = err
+	}
+	if iframe {
+		v.URI = takeString(attrs, "URI")
+		if v.URI == "" && bad == nil {
+			bad = errors.New("missing URI attribute")
+		}
+	}
+	if len(attrs) > 0 {
+		v.Attrs = attrs
+	}
+	return v, bad
+}
+
+func parseRendition(attrs AttributeList) (*Rendition, error) {
+	var bad error
+	r := &Rendition{
+		Type:            MediaType(takeString(attrs, "TYPE")),
+		URI:             takeString(attrs, "URI"),
+		GroupID:         takeString(attrs, "GROUP-ID"),
+		Language:        takeString(attrs, "LANGUAGE"),
+		AssocLanguage:   takeString(attrs, "ASSOC-LANGUAGE"),
+		Name:            takeString(attrs, "NAME"),
+		Default:         takeYes(attrs, "DEFAULT"),
+		Autoselect:      takeYes(attrs, "AUTOSELECT"),
+		Forced:          takeYes(attrs, "FORCED"),
+		InstreamID:      takeString(attrs, "INSTREAM-ID"),
+		Characteristics: takeString(attrs, "CHARACTERISTICS"),
+		Channels:        takeString(attrs, "CHANNELS"),
+
+		StableRenditionID: takeString(attrs, "STABLE-RENDITION-ID"),
+		BitDepth:          int(takeInt(attrs, "BIT-DEPTH", &bad)),
+		SampleRate:        int(takeInt(attrs, "SAMPLE-RATE", &bad)),
+	}
+	if len(attrs) > 0 {
+		r.Attrs = attrs
+	}
+	if r.Type == "" || r.GroupID == "" || r.Name == "" {
+		return r, errors.New("missing TYPE, GROUP-ID or NAME attribute")
+	}
+	return r, bad
+}
+
+// parseMultivariantPlaylist parses entries as a multivariant playlist
+func (p *parser) parseMultivariantPlaylist(ctx context.Context, entries []*PlaylistEntry) (*MultivariantPlaylist, error) {
+	pl := &MultivariantPlaylist{}
+	var pending *Variant
+
+	for i, entry := range entries[1:] {
+		if i%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case entry.Type == EntryTypeComment:
+			pl.Tags = append(pl.Tags, Tag{Value: entry.Value})
+
+		case entry.Type == EntryTypeURI:
+			if pending == nil {
+				if p.strict {
+					return nil, &SyntaxError{Line: entry.Line, Err: errors.New("URI without #EXT-X-STREAM-INF")}
+				}
+				continue
+			}
+			pending.URI = entry.URI
+			pl.Variants = append(pl.Variants, pending)
+			pending = nil
+
+		case entry.IsTag("EXT-X-VERSION"):
+			n, err := strconv.Atoi(strings.TrimSpace(entry.Value))
+			if err != nil {
+				if err := p.malformed(entry, err); err != nil {
+					return nil, err
+				}
+			}
+			pl.Version = n
+
+		case entry.IsTag("EXT-X-INDEPENDENT-SEGMENTS"):
+			pl.IndependentSegments = true
+
+		case entry.IsTag("EXT-X-START"):
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			start, bad := parseStart(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			pl.Start = start
+
+		case entry.IsTag("EXT-X-DEFINE"):
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			define, bad := parseDefine(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			pl.Defines = append(pl.Defines, define)
+
+		case entry.IsTag("EXT-X-MEDIA"):
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			r, bad := parseRendition(attrs)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			r.Line = entry.Line
+			pl.Renditions = append(pl.Renditions, r)
+
+		case entry.IsTag("EXT-X-STREAM-INF"), entry.IsTag("EXT-X-I-FRAME-STREAM-INF"):
+			attrs, err := p.attributes(entry)
+			if err != nil {
+				return nil, err
+			}
+			iframe := entry.Tag == "EXT-X-I-FRAME-STREAM-INF"
+			v, bad := parseVariant(attrs, iframe)
+			if bad != nil {
+				if err := p.malformed(entry, bad); err != nil {
+					return nil, err
+				}
+			}
+			v.Line = entry.Line
+			if iframe {
+				pl.IFrameVariants = append(pl.IFrameVariants, v)
+			} else {
+				pending = v
+			}
+
+		default:
+			pl.Tags = append(pl.Tags, Tag{Name: entry.Tag, Value: entry.Value})
+		}
+	}
+
+	return pl, nil
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package validate
+
+import (
+	"math"
+	"path"
+	"sort"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// Rule IDs of the AppleAuthoring profile
+const (
+	RuleAppleTargetDuration      = "apple-target-duration"
+	RuleAppleIndependentSegments = "apple-independent-segments"
+	RuleAppleKeyframeAlignment   = "apple-keyframe-alignment"
+	RuleAppleIFramePlaylists     = "apple-iframe-playlists"
+	RuleAppleLadder              = "apple-ladder"
+	RuleAppleHDR                 = "apple-hdr"
+	RuleAppleCodecs              = "apple-codecs"
+	RuleAppleStreamInf           = "apple-stream-inf"
+)
+
+var appleRules = []string{
+	RuleAppleTargetDuration, RuleAppleIndependentSegments, RuleAppleKeyframeAlignment,
+	RuleAppleIFramePlaylists, RuleAppleLadder, RuleAppleHDR, RuleAppleCodecs, RuleAppleStreamInf,
+}
+
+const (
+	// appleTargetDuration is the target duration Apple recommends
+	appleTargetDuration = 6
+	// alignmentTolerance is how far apart in seconds segment boundaries
+	// of different variants may be and still count as aligned
+	alignmentTolerance = 0.05
+)
+
+// checkApple applies the HLS Authoring Specification rules to a package
+func checkApple(r *Report, pk *pkg) {
+	m := pk.master
+	var video []*hls.Variant
+	for _, v := range m.Variants {
+		if isVideo(v) {
+			video = append(video, v)
+		}
+	}
+
+	// Video variants sharing a media playlist are checked once
+	var videoURIs []string
+	seen := make(map[string]bool)
+	for _, v := range video {
+		if !seen[v.URI] && pk.media[v.URI] != nil {
+			seen[v.URI] = true
+			videoURIs = append(videoURIs, v.URI)
+		}
+	}
+
+	for _, uri := range pk.order {
+		media := pk.media[uri]
+		if !media.IFramesOnly && media.TargetDuration != appleTargetDuration {
+			pk.add(r, uri, RuleAppleTargetDuration, Warning, "EXT-X-TARGETDURATION is %d, not %d",
+				media.TargetDuration, appleTargetDuration)
+		}
+	}
+
+	if !m.IndependentSegments {
+		for _, uri := range videoURIs {
+			if !pk.media[uri].IndependentSegments {
+				pk.add(r, uri, RuleAppleIndependentSegments, Error,
+					"no EXT-X-INDEPENDENT-SEGMENTS here or in the multivariant playlist")
+			}
+		}
+	}
+
+	checkAlignment(r, pk, videoURIs)
+	checkIFrames(r, m, video)
+	checkLadder(r, video)
+
+	sdr, hdr := false, false
+	for _, v := range video {
+		codecs, _ := hls.ParseCodecs(v.Codecs)
+		switch v.VideoRange {
+		case hls.VideoRangePQ, hls.VideoRangeHLG:
+			hdr = true
+		default:
+			sdr = true
+			for _, c := range codecs {
+				if c.Family() == "Dolby Vision" {
+					r.addLine(v.Line, RuleAppleHDR, Error, "Dolby Vision variant %q has no VIDEO-RANGE=PQ or HLG", v.URI)
+					break
+				}
+			}
+		}
+	}
+	if hdr && !sdr {
+		r.add(RuleAppleHDR, Error, "HDR variants have no SDR fallback")
+	}
+
+	for _, v := range m.Variants {
+		checkAppleVariant(r, v)
+	}
+	for _, v := range m.IFrameVariants {
+		checkAppleVariant(r, v)
+	}
+}
+
+func checkAppleVariant(r *Report, v *hls.Variant) {
+	tag := "EXT-X-STREAM-INF"
+	if v.IFrame {
+		tag = "EXT-X-I-FRAME-STREAM-INF"
+	}
+	if v.Codecs == "" {
+		r.addLine(v.Line, RuleAppleCodecs, Error, "%s %q has no CODECS", tag, v.URI)
+	}
+	codecs, _ := hls.ParseCodecs(v.Codecs)
+	hasAudio := false
+	for _, c := range codecs {
+		if c.MediaType() == hls.MediaTypeAudio {
+			hasAudio = true
+		}
+		if c.FourCC == "hev1" {
+			r.addLine(v.Line, RuleAppleCodecs, Error, "%s %q uses hev1; HEVC must be signalled as hvc1", tag, v.URI)
+		}
+	}
+	if v.Audio != "" && !hasAudio && v.Codecs != "" {
+		r.addLine(v.Line, RuleAppleCodecs, Error, "%s %q refers to audio group %q but CODECS has no audio codec", tag, v.URI, v.Audio)
+	}
+
+	if v.IFrame {
+		return
+	}
+	if v.AverageBandwidth <= 0 {
+		r.addLine(v.Line, RuleAppleStreamInf, Error, "%s %q has no AVERAGE-BANDWIDTH", tag, v.URI)
+	}
+	if isVideo(v) {
+		if v.Resolution == nil {
+			r.addLine(v.Line, RuleAppleStreamInf, Error, "%s %q has no RESOLUTION", tag, v.URI)
+		}
+		if v.FrameRate <= 0 {
+			r.addLine(v.Line, RuleAppleStreamInf, Warning, "%s %q has no FRAME-RATE", tag, v.URI)
+		}
+	}
+}
+
+// checkAlignment compares the segment boundaries of every video media
+// playlist with those of the first, as segments must start on keyframes
+// at the same times in every variant
+func checkAlignment(r *Report, pk *pkg, uris []string) {
+	if len(uris) < 2 {
+		return
+	}
+	first := pk.media[uris[0]]
+	for _, uri := range uris[1:] {
+		media := pk.media[uri]
+		if len(media.Segments) != len(first.Segments) {
+			pk.add(r, uri, RuleAppleKeyframeAlignment, Error, "%d segments, but %q has %d",
+				len(media.Segments), uris[0], len(first.Segments))
+			continue
+		}
+		var end, firstEnd float64
+		for i, seg := range media.Segments {
+			end += seg.Duration
+			firstEnd += first.Segments[i].Duration
+			if math.Abs(end-firstEnd) > alignmentTolerance || seg.Discontinuity != first.Segments[i].Discontinuity {
+				pk.add(r, uri, RuleAppleKeyframeAlignment, Error, "segment %d ends at %.3fs, but in %q at %.3fs",
+					i, end, uris[0], firstEnd)
+				r.Findings[len(r.Findings)-1].Line = seg.Line
+				r.Findings[len(r.Findings)-1].Segment = i
+				break
+			}
+		}
+	}
+}
+
+// checkIFrames requires I-frame playlists, one for every video resolution
+func checkIFrames(r *Report, m *hls.MultivariantPlaylist, video []*hls.Variant) {
+	if len(video) == 0 {
+		return
+	}
+	if len(m.IFrameVariants) == 0 {
+		r.add(RuleAppleIFramePlaylists, Error, "no EXT-X-I-FRAME-STREAM-INF playlists")
+		return
+	}
+	covered := make(map[hls.Resolution]bool)
+	for _, v := range m.IFrameVariants {
+		if v.Resolution != nil {
+			covered[*v.Resolution] = true
+		}
+	}
+	reported := make(map[hls.Resolution]bool)
+	for _, v := range video {
+		if v.Resolution == nil || covered[*v.Resolution] || reported[*v.Resolution] {
+			continue
+		}
+		reported[*v.Resolution] = true
+		r.add(RuleAppleIFramePlaylists, Warning, "no I-frame playlist at %s", v.Resolution)
+	}
+}
+
+// checkLadder reports adjacent variants of the same video range whose
+// BANDWIDTH is not 1.5 to 2 times apart
+func checkLadder(r *Report, video []*hls.Variant) {
+	ladders := make(map[hls.VideoRange][]*hls.Variant)
+	seen := make(map[string]bool)
+	for _, v := range video {
+		if seen[v.URI] || v.Bandwidth <= 0 {
+			continue
+		}
+		seen[v.URI] = true
+		videoRange := v.VideoRange
+		if videoRange == "" {
+			videoRange = hls.VideoRangeSDR
+		}
+		ladders[videoRange] = append(ladders[videoRange], v)
+	}
+	for _, videoRange := range []hls.VideoRange{hls.VideoRangeSDR, hls.VideoRangeHLG, hls.VideoRangePQ} {
+		ladder := ladders[videoRange]
+		sort.SliceStable(ladder, func(a, b int) bool { return ladder[a].Bandwidth < ladder[b].Bandwidth })
+		for i := 1; i < len(ladder); i++ {
+			lo, hi := ladder[i-1], ladder[i]
+			if ratio := float64(hi.Bandwidth) / float64(lo.Bandwidth); ratio < 1.5 || ratio > 2 {
+				r.addLine(hi.Line, RuleAppleLadder, Warning, "%s variants %q and %q are %.2fx apart, not 1.5x to 2x",
+					videoRange, lo.URI, hi.URI, ratio)
+			}
+		}
+	}
+}
+
+// isVideo reports whether a variant carries video
+func isVideo(v *hls.Variant) bool {
+	if v.Resolution != nil {
+		return true
+	}
+	codecs, _ := hls.ParseCodecs(v.Codecs)
+	for _, c := range codecs {
+		if c.MediaType() == hls.MediaTypeVideo {
+			return true
+		}
+	}
+	return false
+}
+
+// add records a finding about the media playlist at uri
+func (pk *pkg) add(r *Report, uri, rule string, severity Severity, format string, args ...interface{}) {
+	r.add(rule, severity, format, args...)
+	r.Findings[len(r.Findings)-1].Playlist = path.Join(pk.dir, uri)
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// mediaPlaylist returns a VOD playlist of fMP4 segments with the given
+// durations
+func mediaPlaylist(durations ...float64) *fstest.MapFile {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:6\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n#EXT-X-MAP:URI=\"init.mp4\"\n")
+	for i, d := range durations {
+		fmt.Fprintf(&b, "#EXTINF:%g,\nseg%d.m4s\n", d, i)
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return &fstest.MapFile{Data: []byte(b.String())}
+}
+
+const appleMaster = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio/index.m3u8"
+
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=200000,CODECS="avc1.640028",RESOLUTION=1920x1080,URI="1080/iframes.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100000,CODECS="avc1.64001f",RESOLUTION=1280x720,URI="720/iframes.m3u8"
+
+#EXT-X-STREAM-INF:BANDWIDTH=6000000,AVERAGE-BANDWIDTH=5000000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=30.000,AUDIO="aac"
+1080/index.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=3500000,AVERAGE-BANDWIDTH=3000000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=30.000,AUDIO="aac"
+720/index.m3u8
+`
+
+func applePackage() fstest.MapFS {
+	iframes := &fstest.MapFile{Data: []byte("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:6\n" +
+		"#EXT-X-I-FRAMES-ONLY\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:6,\n#EXT-X-BYTERANGE:1000@0\nseg0.m4s\n#EXT-X-ENDLIST\n")}
+	return fstest.MapFS{
+		"hls/master.m3u8":       &fstest.MapFile{Data: []byte(appleMaster)},
+		"hls/1080/index.m3u8":   mediaPlaylist(6, 6, 4),
+		"hls/720/index.m3u8":    mediaPlaylist(6, 6, 4),
+		"hls/audio/index.m3u8":  mediaPlaylist(6, 6, 4),
+		"hls/1080/iframes.m3u8": iframes,
+		"hls/720/iframes.m3u8":  iframes,
+	}
+}
+
+func TestApplePackage(t *testing.T) {
+	r, err := Package(applePackage(), "hls/master.m3u8", AppleAuthoring)
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+	for _, result := range r.Results(AppleAuthoring.Rules()) {
+		if !result.Passed() {
+			t.Errorf("Expected rule %s to pass, got %v", result.Rule, result.Findings)
+		}
+	}
+}
+
+func TestApplePackageFindings(t *testing.T) {
+	fsys := applePackage()
+	master := strings.NewReplacer(
+		"#EXT-X-INDEPENDENT-SEGMENTS\n", "",
+		"BANDWIDTH=3500000,AVERAGE-BANDWIDTH=3000000", "BANDWIDTH=1000000",
+		`CODECS="avc1.64001f,mp4a.40.2"`, `CODECS="avc1.64001f"`,
+		"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100000,CODECS=\"avc1.64001f\",RESOLUTION=1280x720,URI=\"720/iframes.m3u8\"\n", "",
+	).Replace(appleMaster)
+	fsys["hls/master.m3u8"] = &fstest.MapFile{Data: []byte(master)}
+	fsys["hls/720/index.m3u8"] = mediaPlaylist(6, 5, 5)
+
+	r, err := Package(fsys, "hls/master.m3u8", AppleAuthoring)
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+	failed := make(map[string]int)
+	for _, result := range r.Results(AppleAuthoring.Rules()) {
+		failed[result.Rule] = len(result.Findings)
+	}
+	want := map[string]int{
+		RuleAppleIndependentSegments: 0, // the media playlists have the tag
+		RuleAppleKeyframeAlignment:   1,
+		RuleAppleIFramePlaylists:     1,
+		RuleAppleLadder:              1,
+		RuleAppleCodecs:              1,
+		RuleAppleStreamInf:           1,
+		RuleAppleHDR:                 0,
+	}
+	for rule, n := range want {
+		if failed[rule] != n {
+			t.Errorf("Expected %d %s findings, got %v", n, rule, r.Rule(rule))
+		}
+	}
+	if f := r.Rule(RuleAppleKeyframeAlignment)[0]; f.Playlist != "hls/720/index.m3u8" || f.Segment != 1 {
+		t.Errorf("Expected misalignment at segment 1 of hls/720/index.m3u8, got %v", f)
+	}
+}
+
+func TestAppleHDRFallback(t *testing.T) {
+	fsys := applePackage()
+	master := strings.ReplaceAll(appleMaster, "FRAME-RATE=30.000,", "FRAME-RATE=30.000,VIDEO-RANGE=PQ,")
+	fsys["hls/master.m3u8"] = &fstest.MapFile{Data: []byte(master)}
+	r, err := Package(fsys, "hls/master.m3u8", AppleAuthoring)
+	if err != nil {
+		t.Fatalf("Package failed: %v", err)
+	}
+	if len(r.Rule(RuleAppleHDR)) != 1 {
+		t.Errorf("Expected a missing SDR fallback, got %v", r.Findings)
+	}
+
+	// The RFC profile does not check authoring rules
+	if r, _ := Package(fsys, "hls/master.m3u8", RFC8216); len(r.Findings) != 0 {
+		t.Errorf("Expected no RFC 8216 findings, got %v", r.Findings)
+	}
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package validate
+
+import (
+	"math"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/Avalanche-io/gotio"
+	hls "github.com/Avalanche-io/otio-hls"
+	"github.com/Avalanche-io/otio-hls/internal/metadata"
+)
+
+// ivPattern is a 128-bit hexadecimal IV
+var ivPattern = regexp.MustCompile(`^0[xX][0-9a-fA-F]{32}$`)
+
+// fmp4Extensions are the segment file types that need an EXT-X-MAP
+var fmp4Extensions = map[string]bool{
+	".m4s": true, ".mp4": true, ".m4v": true, ".m4a": true, ".cmfv": true, ".cmfa": true,
+}
+
+func checkMedia(r *Report, p *hls.MediaPlaylist) {
+	checkVersion(r, p.Version, p)
+
+	if p.TargetDuration <= 0 && len(p.Segments) > 0 {
+		r.add(RuleRequiredTag, Error, "missing EXT-X-TARGETDURATION")
+	}
+	if p.MediaSequence < 0 {
+		r.add(RuleMediaSequence, Error, "EXT-X-MEDIA-SEQUENCE %d is negative", p.MediaSequence)
+	}
+	if p.DiscontinuitySequence < 0 {
+		r.add(RuleMediaSequence, Error, "EXT-X-DISCONTINUITY-SEQUENCE %d is negative", p.DiscontinuitySequence)
+	}
+
+	switch p.PlaylistType {
+	case "", hls.PlaylistTypeVOD, hls.PlaylistTypeEvent:
+	default:
+		r.add(RulePlaylistType, Error, "invalid EXT-X-PLAYLIST-TYPE %q", p.PlaylistType)
+	}
+	if p.PlaylistType == hls.PlaylistTypeVOD && !p.EndList {
+		r.add(RulePlaylistType, Warning, "VOD playlist without EXT-X-ENDLIST")
+	}
+
+	type span struct {
+		seg     *hls.Segment
+		index   int
+		br      hls.Byterange
+		overlap bool
+	}
+	ranges := make(map[string][]span)
+	keys := make(map[*hls.Key]bool)
+	maps := make(map[*hls.Map]bool)
+	hasParts := len(p.Parts) > 0
+	for i, seg := range p.Segments {
+		if seg.URI == "" {
+			r.addSegment(seg, i, RuleRequiredTag, Error, "segment has no URI")
+		}
+		if p.TargetDuration > 0 && int(math.Round(seg.Duration)) > p.TargetDuration {
+			r.addSegment(seg, i, RuleTargetDuration, Error,
+				"EXTINF %g exceeds EXT-X-TARGETDURATION %d", seg.Duration, p.TargetDuration)
+		}
+
+		if br := seg.Byterange; br != nil {
+			if br.Count <= 0 {
+				r.addSegment(seg, i, RuleByterange, Error, "EXT-X-BYTERANGE length %d is not positive", br.Count)
+			} else {
+				ranges[seg.URI] = append(ranges[seg.URI], span{seg: seg, index: i, br: *br})
+			}
+		}
+		if seg.Map == nil && fmp4Extensions[path.Ext(seg.URI)] {
+			r.addSegment(seg, i, RuleMap, Warning, "fragmented MP4 segment %q has no EXT-X-MAP", seg.URI)
+		}
+		if m := seg.Map; m != nil && !maps[m] {
+			maps[m] = true
+			if m.URI == "" {
+				r.addSegment(seg, i, RuleMap, Error, "EXT-X-MAP has no URI")
+			}
+			if m.Byterange != nil && m.Byterange.Count <= 0 {
+				r.addSegment(seg, i, RuleMap, Error, "EXT-X-MAP BYTERANGE length %d is not positive", m.Byterange.Count)
+			}
+		}
+		if k := seg.Key; k != nil && !keys[k] {
+			keys[k] = true
+			checkKey(r, seg, i, k)
+		}
+
+		if len(seg.Parts) > 0 {
+			hasParts = true
+		}
+		for _, part := range seg.Parts {
+			checkPart(r, seg, i, p.PartTarget, part)
+		}
+	}
+	for _, part := range p.Parts {
+		checkPart(r, nil, -1, p.PartTarget, part)
+	}
+	if hasParts && p.PartTarget <= 0 {
+		r.add(RulePartialSegment, Error, "EXT-X-PART without EXT-X-PART-INF")
+	}
+
+	// Byteranges of one resource must not overlap
+	var uris []string
+	for uri := range ranges {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		spans := ranges[uri]
+		sort.SliceStable(spans, func(a, b int) bool { return spans[a].br.Offset < spans[b].br.Offset })
+		for j := 1; j < len(spans); j++ {
+			prev, cur := spans[j-1], spans[j]
+			if cur.br.Offset < prev.br.Offset+prev.br.Count {
+				r.addSegment(cur.seg, cur.index, RuleByterange, Warning,
+					"byterange %s of %q overlaps segment %d", cur.br.String(), uri, prev.index)
+			}
+		}
+	}
+
+	if p.Start != nil {
+		if duration := p.Duration(); p.EndList && math.Abs(p.Start.TimeOffset) > duration {
+			r.add(RuleStart, Warning, "EXT-X-START TIME-OFFSET %g is beyond the playlist duration %g",
+				p.Start.TimeOffset, duration)
+		}
+	}
+}
+
+// checkKey applies the EXT-X-KEY attribute rules
+func checkKey(r *Report, seg *hls.Segment, i int, k *hls.Key) {
+	switch k.Method {
+	case "NONE":
+		if k.URI != "" || k.IV != "" || k.KeyFormat != "" || k.KeyFormatVersions != "" {
+			r.addSegment(seg, i, RuleKey, Error, "EXT-X-KEY METHOD=NONE has other attributes")
+		}
+		return
+	case "AES-128", "SAMPLE-AES", "SAMPLE-AES-CTR":
+	default:
+		r.addSegment(seg, i, RuleKey, Error, "invalid EXT-X-KEY METHOD %q", k.Method)
+		return
+	}
+	if k.URI == "" {
+		r.addSegment(seg, i, RuleKey, Error, "EXT-X-KEY METHOD=%s has no URI", k.Method)
+	}
+	if k.IV != "" && !ivPattern.MatchString(k.IV) {
+		r.addSegment(seg, i, RuleKey, Error, "EXT-X-KEY IV %q is not a 128-bit hexadecimal value", k.IV)
+	}
+}
+
+// checkPart applies the partial segment rules. seg is nil for the parts
+// of the segment not yet complete.
+func checkPart(r *Report, seg *hls.Segment, i int, target float64, part *hls.PartialSegment) {
+	add := r.add
+	if seg != nil {
+		add = func(rule string, severity Severity, format string, args ...interface{}) {
+			r.addSegment(seg, i, rule, severity, format, args...)
+		}
+	}
+	if part.URI == "" {
+		add(RulePartialSegment, Error, "EXT-X-PART has no URI")
+	}
+	if target > 0 && part.Duration > target {
+		add(RulePartialSegment, Error, "EXT-X-PART DURATION %g exceeds PART-TARGET %g", part.Duration, target)
+	}
+}
+
+// Update checks that a reloaded live media playlist continues the one
+// loaded before it: sequence numbers do not go back, segments keep their
+// URIs and the target duration does not change
+func Update(prev, next *hls.MediaPlaylist) *Report {
+	r := &Report{}
+	if next.MediaSequence < prev.MediaSequence {
+		r.add(RuleMediaSequence, Error, "EXT-X-MEDIA-SEQUENCE went back from %d to %d",
+			prev.MediaSequence, next.MediaSequence)
+	}
+	if next.DiscontinuitySequence < prev.DiscontinuitySequence {
+		r.add(RuleMediaSequence, Error, "EXT-X-DISCONTINUITY-SEQUENCE went back from %d to %d",
+			prev.DiscontinuitySequence, next.DiscontinuitySequence)
+	}
+	if next.TargetDuration != prev.TargetDuration {
+		r.add(RuleTargetDuration, Error, "EXT-X-TARGETDURATION changed from %d to %d",
+			prev.TargetDuration, next.TargetDuration)
+	}
+	for i, seg := range next.Segments {
+		j := next.MediaSequence + i - prev.MediaSequence
+		if j < 0 || j >= len(prev.Segments) {
+			continue
+		}
+		if old := prev.Segments[j]; old.URI != seg.URI {
+			r.addSegment(seg, i, RuleMediaSequence, Error, "segment %d changed from %q to %q",
+				next.MediaSequence+i, old.URI, seg.URI)
+		}
+	}
+	return r
+}
+
+// checkClipSequence reports clips whose discontinuity_sequence metadata
+// is lower than that of a clip before them. clips holds the index of each
+// clip among the track's children.
+func checkClipSequence(r *Report, track *gotio.Track, clips []int) {
+	last := int64(-1)
+	children := track.Children()
+	for i, index := range clips {
+		hlsMD, _ := metadata.AsMap(children[index].(*gotio.Clip).Metadata()["HLS"])
+		seq, ok := metadata.AsInt64(hlsMD["discontinuity_sequence"])
+		if !ok {
+			continue
+		}
+		if seq < last {
+			r.add(RuleMediaSequence, Error, "discontinuity_sequence %d follows %d", seq, last)
+			r.Findings[len(r.Findings)-1].Segment = i
+		}
+		last = max(last, seq)
+	}
+}
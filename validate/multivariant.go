@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package validate
+
+import (
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+func checkMultivariant(r *Report, p *hls.MultivariantPlaylist) {
+	checkVersion(r, p.Version, p)
+
+	if len(p.Variants) == 0 {
+		r.add(RuleRequiredTag, Error, "no EXT-X-STREAM-INF variants")
+	}
+	for _, v := range p.IFrameVariants {
+		checkVariant(r, p, v)
+	}
+	for _, v := range p.Variants {
+		checkVariant(r, p, v)
+	}
+	checkRenditions(r, p.Renditions)
+}
+
+func checkVariant(r *Report, p *hls.MultivariantPlaylist, v *hls.Variant) {
+	tag := "EXT-X-STREAM-INF"
+	if v.IFrame {
+		tag = "EXT-X-I-FRAME-STREAM-INF"
+	}
+	if v.URI == "" {
+		r.addLine(v.Line, RuleRequiredTag, Error, "%s has no URI", tag)
+	}
+	if v.Bandwidth <= 0 {
+		r.addLine(v.Line, RuleBandwidth, Error, "%s %q has no BANDWIDTH", tag, v.URI)
+	}
+	if v.AverageBandwidth > v.Bandwidth && v.Bandwidth > 0 {
+		r.addLine(v.Line, RuleBandwidth, Warning, "%s %q has AVERAGE-BANDWIDTH %d above BANDWIDTH %d",
+			tag, v.URI, v.AverageBandwidth, v.Bandwidth)
+	}
+
+	if v.Codecs == "" {
+		r.addLine(v.Line, RuleCodecs, Warning, "%s %q has no CODECS", tag, v.URI)
+	} else if codecs, err := hls.ParseCodecs(v.Codecs); err != nil {
+		r.addLine(v.Line, RuleCodecs, Error, "%s %q: %v", tag, v.URI, err)
+	} else if v.Resolution == nil {
+		for _, c := range codecs {
+			if c.MediaType() == hls.MediaTypeVideo {
+				r.addLine(v.Line, RuleResolution, Warning, "%s %q has video but no RESOLUTION", tag, v.URI)
+				break
+			}
+		}
+	}
+
+	groups := []struct {
+		mediaType hls.MediaType
+		id        string
+	}{
+		{hls.MediaTypeAudio, v.Audio},
+		{hls.MediaTypeVideo, v.Video},
+		{hls.MediaTypeSubtitles, v.Subtitles},
+		{hls.MediaTypeClosedCaptions, v.ClosedCaptions},
+	}
+	for _, g := range groups {
+		if g.id == "" || (g.mediaType == hls.MediaTypeClosedCaptions && g.id == "NONE") {
+			continue
+		}
+		if len(p.Group(g.mediaType, g.id)) == 0 {
+			r.addLine(v.Line, RuleGroup, Error, "%s %q refers to %s group %q, which has no renditions",
+				tag, v.URI, g.mediaType, g.id)
+		}
+	}
+}
+
+// checkRenditions applies the EXT-X-MEDIA rules, including those across
+// the renditions of a group
+func checkRenditions(r *Report, renditions []*hls.Rendition) {
+	type groupKey struct {
+		mediaType hls.MediaType
+		id        string
+	}
+	defaults := make(map[groupKey]int)
+	names := make(map[groupKey]map[string]bool)
+
+	for _, rd := range renditions {
+		switch rd.Type {
+		case hls.MediaTypeAudio, hls.MediaTypeVideo, hls.MediaTypeSubtitles, hls.MediaTypeClosedCaptions:
+		default:
+			r.addLine(rd.Line, RuleRendition, Error, "EXT-X-MEDIA %q has invalid TYPE %q", rd.Name, rd.Type)
+			continue
+		}
+		if rd.GroupID == "" || rd.Name == "" {
+			r.addLine(rd.Line, RuleRequiredTag, Error, "EXT-X-MEDIA %q is missing GROUP-ID or NAME", rd.Name)
+			continue
+		}
+
+		key := groupKey{rd.Type, rd.GroupID}
+		if names[key] == nil {
+			names[key] = make(map[string]bool)
+		}
+		if names[key][rd.Name] {
+			r.addLine(rd.Line, RuleRendition, Error, "%s group %q has two renditions named %q", rd.Type, rd.GroupID, rd.Name)
+		}
+		names[key][rd.Name] = true
+		if rd.Default {
+			defaults[key]++
+			if defaults[key] == 2 {
+				r.addLine(rd.Line, RuleRendition, Error, "%s group %q has more than one DEFAULT=YES rendition", rd.Type, rd.GroupID)
+			}
+			if !rd.Autoselect {
+				r.addLine(rd.Line, RuleRendition, Error, "EXT-X-MEDIA %q has DEFAULT=YES without AUTOSELECT=YES", rd.Name)
+			}
+		}
+
+		switch rd.Type {
+		case hls.MediaTypeClosedCaptions:
+			if rd.URI != "" {
+				r.addLine(rd.Line, RuleRendition, Error, "CLOSED-CAPTIONS rendition %q has a URI", rd.Name)
+			}
+			if !validInstreamID(rd.InstreamID) {
+				r.addLine(rd.Line, RuleRendition, Error, "CLOSED-CAPTIONS rendition %q has invalid INSTREAM-ID %q", rd.Name, rd.InstreamID)
+			}
+		case hls.MediaTypeSubtitles:
+			if rd.URI == "" {
+				r.addLine(rd.Line, RuleRendition, Error, "SUBTITLES rendition %q has no URI", rd.Name)
+			}
+		}
+		if rd.Forced && rd.Type != hls.MediaTypeSubtitles {
+			r.addLine(rd.Line, RuleRendition, Error, "%s rendition %q has FORCED, which only SUBTITLES may have", rd.Type, rd.Name)
+		}
+		if rd.InstreamID != "" && rd.Type != hls.MediaTypeClosedCaptions {
+			r.addLine(rd.Line, RuleRendition, Error, "%s rendition %q has INSTREAM-ID, which only CLOSED-CAPTIONS may have", rd.Type, rd.Name)
+		}
+	}
+}
+
+// validInstreamID reports whether id is CC1 to CC4 or SERVICE1 to
+// SERVICE63
+func validInstreamID(id string) bool {
+	var n int
+	switch {
+	case len(id) == 3 && id[:2] == "CC":
+		n = int(id[2] - '0')
+		return n >= 1 && n <= 4
+	case len(id) > 7 && len(id) <= 9 && id[:7] == "SERVICE":
+		for _, c := range id[7:] {
+			if c < '0' || c > '9' {
+				return false
+			}
+			n = n*10 + int(c-'0')
+		}
+		return id[7] != '0' && n >= 1 && n <= 63
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// Profile selects the rules Package checks
+type Profile string
+
+const (
+	// RFC8216 checks every playlist of a package against the HLS
+	// specification
+	RFC8216 Profile = "rfc8216"
+	// AppleAuthoring adds the stricter rules of Apple's HLS Authoring
+	// Specification for Apple Devices
+	AppleAuthoring Profile = "apple"
+)
+
+// rfc8216Rules are the rules of the RFC8216 profile
+var rfc8216Rules = []string{
+	RulePlaylist, RuleRequiredTag, RuleVersion, RuleTargetDuration, RuleMediaSequence,
+	RulePlaylistType, RuleByterange, RuleMap, RuleKey, RulePartialSegment, RuleStart,
+	RuleBandwidth, RuleCodecs, RuleResolution, RuleGroup, RuleRendition,
+}
+
+// Rules returns the IDs of the rules the profile checks, for
+// Report.Results
+func (p Profile) Rules() []string {
+	rules := append([]string(nil), rfc8216Rules...)
+	if p == AppleAuthoring {
+		rules = append(rules, appleRules...)
+	}
+	return rules
+}
+
+// pkg is a multivariant playlist and the media playlists it refers to
+type pkg struct {
+	master *hls.MultivariantPlaylist
+	// media holds the media playlists by their URI in the master, and
+	// order lists those URIs as they appear
+	media map[string]*hls.MediaPlaylist
+	order []string
+	// dir is the directory of the multivariant playlist
+	dir string
+}
+
+// Package reads the multivariant playlist name from fsys and every media
+// playlist it refers to, and checks them with the rules of the profile.
+// Findings name the playlist file they are about. Playlists at absolute
+// URLs are not checked. Playlists are decoded with the limits of opts.
+func Package(fsys fs.FS, name string, profile Profile, opts ...hls.DecoderOption) (*Report, error) {
+	switch profile {
+	case RFC8216, AppleAuthoring:
+	default:
+		return nil, fmt.Errorf("unknown validation profile %q", profile)
+	}
+
+	ctx := context.Background()
+	resolver := hls.NewResolver(fsys, opts...)
+	p, err := resolver.Playlist(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	master, ok := p.(*hls.MultivariantPlaylist)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a multivariant playlist", name)
+	}
+
+	r := &Report{}
+	pk := &pkg{master: master, media: make(map[string]*hls.MediaPlaylist), dir: path.Dir(name)}
+	checkMultivariant(r, master)
+	for i := range r.Findings {
+		r.Findings[i].Playlist = name
+	}
+
+	var uris []string
+	for _, v := range master.Variants {
+		uris = append(uris, v.URI)
+	}
+	for _, v := range master.IFrameVariants {
+		uris = append(uris, v.URI)
+	}
+	for _, rd := range master.Renditions {
+		uris = append(uris, rd.URI)
+	}
+	for _, uri := range uris {
+		if _, seen := pk.media[uri]; seen || uri == "" {
+			continue
+		}
+		if u, err := url.Parse(uri); err != nil || u.Scheme != "" || u.Host != "" {
+			continue
+		}
+		media, mediaName, err := readMedia(ctx, resolver, name, uri)
+		if err != nil {
+			r.add(RulePlaylist, Error, "%v", err)
+			r.Findings[len(r.Findings)-1].Playlist = mediaName
+			continue
+		}
+		pk.media[uri] = media
+		pk.order = append(pk.order, uri)
+
+		mediaReport := &Report{}
+		checkMedia(mediaReport, media)
+		for _, f := range mediaReport.Findings {
+			f.Playlist = mediaName
+			r.Findings = append(r.Findings, f)
+		}
+	}
+
+	if profile == AppleAuthoring {
+		checkApple(r, pk)
+	}
+	return r, nil
+}
+
+// readMedia reads the media playlist of a package at uri, returning it
+// with its path
+func readMedia(ctx context.Context, resolver *hls.Resolver, master, uri string) (*hls.MediaPlaylist, string, error) {
+	p, name, err := resolver.Reference(ctx, master, uri)
+	if err != nil {
+		return nil, name, err
+	}
+	media, ok := p.(*hls.MediaPlaylist)
+	if !ok {
+		return nil, name, fmt.Errorf("%s is not a media playlist", name)
+	}
+	return media, name, nil
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+// Package validate checks HLS playlists and the OTIO timelines they are
+// encoded from against RFC 8216. Every finding names a stable rule ID, so
+// reports can be filtered and compared across runs.
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Avalanche-io/gotio"
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// Rule IDs
+const (
+	RuleRequiredTag    = "required-tag"
+	RuleTargetDuration = "target-duration"
+	RuleMediaSequence  = "media-sequence"
+	RuleVersion        = "version"
+	RulePlaylistType   = "playlist-type"
+	RuleByterange      = "byterange"
+	RuleMap            = "map"
+	RuleKey            = "key"
+	RulePartialSegment = "partial-segment"
+	RuleStart          = "start"
+	RuleBandwidth      = "bandwidth"
+	RuleCodecs         = "codecs"
+	RuleResolution     = "resolution"
+	RuleGroup          = "group"
+	RuleRendition      = "rendition"
+	RulePlaylist       = "playlist"
+)
+
+// Severity ranks findings
+type Severity int
+
+const (
+	// Info is worth knowing but needs no change
+	Info Severity = iota
+	// Warning breaks a SHOULD of the specification
+	Warning
+	// Error breaks a MUST of the specification
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Finding is one problem found in a playlist or timeline
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Message  string
+	// Playlist names the playlist file of a finding in a package
+	Playlist string
+	// Line is the 1-based playlist line of a segment, variant or
+	// rendition decoded from text, or 0
+	Line int
+	// Segment is the index of the media segment, or -1
+	Segment int
+	// Track names the timeline track a finding belongs to, and Clip is
+	// the index of the clip among the track's children, or -1
+	Track string
+	Clip  int
+}
+
+// String formats the finding as "severity [rule] location: message"
+func (f Finding) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s [%s]", f.Severity, f.Rule)
+	if f.Playlist != "" {
+		fmt.Fprintf(&b, " %s", f.Playlist)
+	}
+	switch {
+	case f.Track != "" && f.Clip >= 0:
+		fmt.Fprintf(&b, " track %q clip %d", f.Track, f.Clip)
+	case f.Track != "":
+		fmt.Fprintf(&b, " track %q", f.Track)
+	case f.Line > 0:
+		fmt.Fprintf(&b, " line %d", f.Line)
+	case f.Segment >= 0:
+		fmt.Fprintf(&b, " segment %d", f.Segment)
+	}
+	return b.String() + ": " + f.Message
+}
+
+// Report is the findings of a check in playlist order
+type Report struct {
+	Findings []Finding
+}
+
+func (r *Report) add(rule string, severity Severity, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+		Segment:  -1,
+		Clip:     -1,
+	})
+}
+
+// addSegment records a finding about the segment at index i
+func (r *Report) addSegment(seg *hls.Segment, i int, rule string, severity Severity, format string, args ...interface{}) {
+	r.add(rule, severity, format, args...)
+	f := &r.Findings[len(r.Findings)-1]
+	f.Line, f.Segment = seg.Line, i
+}
+
+// addLine records a finding about the tag at a playlist line, or 0
+func (r *Report) addLine(line int, rule string, severity Severity, format string, args ...interface{}) {
+	r.add(rule, severity, format, args...)
+	r.Findings[len(r.Findings)-1].Line = line
+}
+
+// Rule returns the findings of one rule
+func (r *Report) Rule(rule string) []Finding {
+	var out []Finding
+	for _, f := range r.Findings {
+		if f.Rule == rule {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// RuleResult is the outcome of one rule of a profile
+type RuleResult struct {
+	Rule     string
+	Findings []Finding
+}
+
+// Passed reports whether the rule found nothing
+func (r RuleResult) Passed() bool {
+	return len(r.Findings) == 0
+}
+
+// Results groups the findings by rule, with one result for every rule
+// given, in that order
+func (r *Report) Results(rules []string) []RuleResult {
+	results := make([]RuleResult, len(rules))
+	for i, rule := range rules {
+		results[i] = RuleResult{Rule: rule, Findings: r.Rule(rule)}
+	}
+	return results
+}
+
+// Err returns a *ValidationError holding the findings of at least the
+// given severity, or nil when there are none
+func (r *Report) Err(min Severity) error {
+	var failed []Finding
+	for _, f := range r.Findings {
+		if f.Severity >= min {
+			failed = append(failed, f)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &ValidationError{Findings: failed}
+}
+
+// ValidationError is returned for a playlist that fails validation
+type ValidationError struct {
+	Findings []Finding
+}
+
+func (e *ValidationError) Error() string {
+	msg := "validate: " + e.Findings[0].String()
+	if n := len(e.Findings) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+// Playlist checks a media or multivariant playlist
+func Playlist(p hls.Playlist) *Report {
+	r := &Report{}
+	switch p := p.(type) {
+	case *hls.MediaPlaylist:
+		checkMedia(r, p)
+	case *hls.MultivariantPlaylist:
+		checkMultivariant(r, p)
+	}
+	return r
+}
+
+// Bytes parses M3U8 data and checks it. Segment findings carry their line.
+func Bytes(data []byte) (*Report, error) {
+	p, err := hls.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return Playlist(p), nil
+}
+
+// Timeline checks the playlists an encoder with opts would write for a
+// timeline: the top-level playlist and the media playlist of every track
+// with clips. Findings about a track name it, and findings about a segment
+// give the index of its clip.
+func Timeline(t *gotio.Timeline, opts ...hls.EncoderOption) (*Report, error) {
+	enc := hls.NewEncoder(nil, opts...)
+	p, err := enc.Playlist(t)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{}
+	_, master := p.(*hls.MultivariantPlaylist)
+	if master {
+		checkMultivariant(r, p.(*hls.MultivariantPlaylist))
+	}
+	for _, child := range t.Tracks().Children() {
+		track, ok := child.(*gotio.Track)
+		if !ok || (master && len(track.Children()) == 0) {
+			continue
+		}
+		media := p
+		if master {
+			if media, err = enc.MediaPlaylist(track); err != nil {
+				return nil, fmt.Errorf("track %q: %w", track.Name(), err)
+			}
+		}
+
+		// Segments are encoded from the clips in order
+		var clips []int
+		for i, item := range track.Children() {
+			if _, ok := item.(*gotio.Clip); ok {
+				clips = append(clips, i)
+			}
+		}
+		trackReport := Playlist(media)
+		checkClipSequence(trackReport, track, clips)
+		for _, f := range trackReport.Findings {
+			f.Track = track.Name()
+			if f.Segment >= 0 && f.Segment < len(clips) {
+				f.Clip = clips[f.Segment]
+			}
+			r.Findings = append(r.Findings, f)
+		}
+		if !master {
+			break
+		}
+	}
+	return r, nil
+}
+
+// Preflight returns a check for hls.WithPreflight that fails on findings
+// of at least the given severity
+func Preflight(min Severity) func(hls.Playlist) error {
+	return func(p hls.Playlist) error {
+		return Playlist(p).Err(min)
+	}
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package validate
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+	"github.com/Avalanche-io/gotio/opentime"
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// rules lists the rule IDs of a report in order
+func rules(r *Report) []string {
+	var out []string
+	for _, f := range r.Findings {
+		out = append(out, f.Rule)
+	}
+	return out
+}
+
+func TestValidMediaPlaylist(t *testing.T) {
+	r, err := Bytes([]byte(`#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:6
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin",IV=0x00000000000000000000000000000001
+#EXTINF:6.006,
+seg0.m4s
+#EXTINF:5.5,
+seg1.m4s
+#EXT-X-ENDLIST
+`))
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	if len(r.Findings) != 0 {
+		t.Errorf("Expected no findings, got %v", r.Findings)
+	}
+}
+
+func TestMediaPlaylistFindings(t *testing.T) {
+	r, err := Bytes([]byte(`#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:4.0,
+#EXT-X-BYTERANGE:1000@0
+main.ts
+#EXT-X-KEY:METHOD=AES-128,IV=0x1234
+#EXTINF:4.6,
+#EXT-X-BYTERANGE:1000@500
+main.ts
+#EXTINF:4.0,
+seg2.m4s
+`))
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+
+	want := []string{RuleVersion, RulePlaylistType, RuleTargetDuration, RuleKey, RuleKey, RuleMap, RuleByterange}
+	if got := rules(r); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Expected rules %v, got:\n%v", want, r.Findings)
+	}
+	if f := r.Rule(RuleTargetDuration)[0]; f.Line != 11 || f.Segment != 1 || f.Severity != Error {
+		t.Errorf("Expected an error at line 11 for segment 1, got %v", f)
+	}
+	if f := r.Rule(RuleVersion)[0]; !strings.Contains(f.Message, "EXT-X-BYTERANGE") {
+		t.Errorf("Expected the version finding to name EXT-X-BYTERANGE, got %q", f.Message)
+	}
+	if f := r.Rule(RuleMap)[0]; f.Severity != Warning || f.Line != 13 {
+		t.Errorf("Expected a warning at line 13, got %v", f)
+	}
+}
+
+func TestMultivariantFindings(t *testing.T) {
+	p := &hls.MultivariantPlaylist{
+		Version: 6,
+		Renditions: []*hls.Rendition{
+			{Type: hls.MediaTypeAudio, GroupID: "aac", Name: "English", Default: true, URI: "en.m3u8"},
+			{Type: hls.MediaTypeAudio, GroupID: "aac", Name: "English", Default: true, Autoselect: true, URI: "en2.m3u8"},
+			{Type: hls.MediaTypeClosedCaptions, GroupID: "cc", Name: "CC", InstreamID: "CC5"},
+		},
+		Variants: []*hls.Variant{
+			{URI: "hi.m3u8", Codecs: "avc1.640028,mp4a.40.2", Audio: "aac", Subtitles: "subs"},
+			{URI: "lo.m3u8", Bandwidth: 1000000, AverageBandwidth: 2000000, Codecs: "avc1.64"},
+		},
+	}
+	r := Playlist(p)
+	want := []string{
+		RuleBandwidth, RuleResolution, RuleGroup, // hi.m3u8
+		RuleBandwidth, RuleCodecs, // lo.m3u8
+		RuleRendition, RuleRendition, RuleRendition, RuleRendition, // renditions
+	}
+	if got := rules(r); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected rules %v, got:\n%v", want, r.Findings)
+	}
+	if err := r.Err(Error); err == nil {
+		t.Error("Expected errors")
+	}
+}
+
+func TestMultivariantFindingLines(t *testing.T) {
+	var p hls.MultivariantPlaylist
+	err := p.Unmarshal([]byte(`#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,URI="en.m3u8"
+
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,AVERAGE-BANDWIDTH=2000000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720,AUDIO="aac"
+lo.m3u8
+`))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	r := Playlist(&p)
+	if f := r.Rule(RuleBandwidth); len(f) != 1 || f[0].Line != 5 {
+		t.Errorf("Expected a bandwidth finding on line 5, got %v", r.Findings)
+	}
+	if f := r.Rule(RuleRendition); len(f) != 1 || f[0].Line != 3 {
+		t.Errorf("Expected a rendition finding on line 3, got %v", r.Findings)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	prev := &hls.MediaPlaylist{TargetDuration: 6, MediaSequence: 10, Segments: []*hls.Segment{
+		{URI: "10.ts"}, {URI: "11.ts"}, {URI: "12.ts"},
+	}}
+	next := &hls.MediaPlaylist{TargetDuration: 6, MediaSequence: 11, Segments: []*hls.Segment{
+		{URI: "11.ts"}, {URI: "12b.ts"}, {URI: "13.ts"},
+	}}
+	r := Update(prev, next)
+	if len(r.Findings) != 1 || r.Findings[0].Segment != 1 {
+		t.Errorf("Expected segment 12 to be reported, got %v", r.Findings)
+	}
+
+	r = Update(next, prev)
+	if got := rules(r); len(got) != 2 || got[0] != RuleMediaSequence {
+		t.Errorf("Expected the sequence going back to be reported, got %v", r.Findings)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	track := gotio.NewTrack("video", nil, gotio.TrackKindVideo, gotio.AnyDictionary{
+		"HLS": map[string]interface{}{"version": 3, "target_duration": 4},
+	}, nil)
+	for i, duration := range []float64{4, 4, 5} {
+		tr := opentime.NewTimeRange(opentime.NewRationalTime(0, 1), opentime.NewRationalTime(duration, 1))
+		md := gotio.AnyDictionary{"HLS": map[string]interface{}{"discontinuity_sequence": 2 - i%2}}
+		ref := gotio.NewExternalReference("", "seg.ts", nil, nil)
+		track.AppendChild(gotio.NewClip("", ref, &tr, md, nil, nil, "", nil))
+	}
+	timeline := gotio.NewTimeline("", nil, nil)
+	timeline.Tracks().AppendChild(track)
+
+	r, err := Timeline(timeline)
+	if err != nil {
+		t.Fatalf("Timeline failed: %v", err)
+	}
+	if got := rules(r); strings.Join(got, ",") != RuleTargetDuration+","+RuleMediaSequence {
+		t.Fatalf("Unexpected findings %v", r.Findings)
+	}
+	for i, clip := range []int{2, 1} {
+		if f := r.Findings[i]; f.Track != "video" || f.Clip != clip {
+			t.Errorf("Expected finding %d on clip %d, got %v", i, clip, f)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	p := &hls.MediaPlaylist{Version: 3, TargetDuration: 4, Segments: []*hls.Segment{{URI: "a.ts", Duration: 9}}}
+	var buf bytes.Buffer
+	err := hls.NewEncoder(&buf, hls.WithPreflight(Preflight(Error))).EncodePlaylist(p)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Findings[0].Rule != RuleTargetDuration {
+		t.Fatalf("Expected a target-duration ValidationError, got: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing written, got:\n%s", buf.String())
+	}
+}
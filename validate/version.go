@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package validate
+
+import (
+	hls "github.com/Avalanche-io/otio-hls"
+)
+
+// checkVersion reports the feature needing the highest version when the
+// playlist declares a lower one. A missing EXT-X-VERSION means version 1.
+func checkVersion(r *Report, version int, p hls.Playlist) {
+	declared := max(version, 1)
+	var need hls.VersionFeature
+	for _, f := range hls.VersionFeatures(p) {
+		if f.Version > need.Version {
+			need = f
+		}
+	}
+	if need.Version > declared {
+		r.add(RuleVersion, Error, "EXT-X-VERSION %d is lower than version %d required by %s",
+			declared, need.Version, need.Name)
+	}
+}
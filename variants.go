@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"maps"
+	"strings"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// defaultAudioGroup is the GROUP-ID of audio tracks without a group_id
+const defaultAudioGroup = "audio1"
+
+// AudioGroup is the audio tracks sharing an EXT-X-MEDIA GROUP-ID
+type AudioGroup struct {
+	ID     string
+	Tracks []*gotio.Track
+}
+
+// Codecs returns the distinct codecs of the group's tracks in order
+func (g *AudioGroup) Codecs() []string {
+	var codecs []string
+	seen := make(map[string]bool)
+	for _, track := range g.Tracks {
+		codec, _ := namespace(track.Metadata(), streamingMetadataNamespace)["codec"].(string)
+		for _, c := range splitCodecs(codec) {
+			if !seen[c] {
+				seen[c] = true
+				codecs = append(codecs, c)
+			}
+		}
+	}
+	return codecs
+}
+
+// VariantRule decides whether a video track is offered with an audio
+// group
+type VariantRule func(video *gotio.Track, group *AudioGroup) bool
+
+// AllAudioGroups offers every video track with every audio group
+func AllAudioGroups() VariantRule {
+	return func(*gotio.Track, *AudioGroup) bool {
+		return true
+	}
+}
+
+// LinkedAudioGroups offers a video track with every group holding a track
+// named in its linked_tracks metadata
+func LinkedAudioGroups() VariantRule {
+	return func(video *gotio.Track, group *AudioGroup) bool {
+		linked := asStrings(video.Metadata()["linked_tracks"])
+		for _, track := range group.Tracks {
+			for _, name := range linked {
+				if track.Name() == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// AudioCodecGroups offers every video track with the groups whose codecs
+// start with one of the given prefixes, such as "mp4a" or "ec-3"
+func AudioCodecGroups(prefixes ...string) VariantRule {
+	return func(_ *gotio.Track, group *AudioGroup) bool {
+		for _, codec := range group.Codecs() {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(codec, prefix) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// audioGroups collects audio tracks by group in order of first appearance
+func (e *Encoder) audioGroups(audioTracks []*gotio.Track) []*AudioGroup {
+	var groups []*AudioGroup
+	byID := make(map[string]*AudioGroup)
+	for _, track := range audioTracks {
+		id := e.getStringOrDefault(e.getStreamingMetadata(track), "group_id", defaultAudioGroup)
+		g := byID[id]
+		if g == nil {
+			g = &AudioGroup{ID: id}
+			byID[id] = g
+			groups = append(groups, g)
+		}
+		g.Tracks = append(g.Tracks, track)
+	}
+	return groups
+}
+
+// variantGroups returns the audio groups a video track is offered with.
+// Without rules that is the group of the first track in linked_tracks.
+func (e *Encoder) variantGroups(video *gotio.Track, audioTracks []*gotio.Track, groups []*AudioGroup) []*AudioGroup {
+	if len(e.variantRules) == 0 {
+		audioTrack := e.linkedAudioTrack(video, audioTracks)
+		if audioTrack == nil {
+			return nil
+		}
+		// Only the linked track counts towards codecs and bandwidth, as
+		// it always has
+		id := e.getStringOrDefault(e.getStreamingMetadata(audioTrack), "group_id", defaultAudioGroup)
+		return []*AudioGroup{{ID: id, Tracks: []*gotio.Track{audioTrack}}}
+	}
+
+	var selected []*AudioGroup
+	for _, g := range groups {
+		for _, rule := range e.variantRules {
+			if rule(video, g) {
+				selected = append(selected, g)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// withAudioGroup returns a copy of v referring to an audio group, with the
+// group's codecs added and its highest bandwidths summed in. The copy
+// shares nothing with v, as each audio group gets its own.
+func (e *Encoder) withAudioGroup(v *Variant, g *AudioGroup) *Variant {
+	out := *v
+	out.Audio = g.ID
+	out.Attrs = maps.Clone(v.Attrs)
+	if v.Resolution != nil {
+		r := *v.Resolution
+		out.Resolution = &r
+	}
+
+	if out.Codecs != "" {
+		out.Codecs = mergeCodecs(out.Codecs, g.Codecs())
+	}
+
+	var peak, average int64
+	for _, track := range g.Tracks {
+		streamingMD := e.getStreamingMetadata(track)
+		bandwidth, _ := asInt64(streamingMD["bandwidth"])
+		avg, ok := asInt64(streamingMD["average_bandwidth"])
+		if !ok {
+			avg = bandwidth
+		}
+		peak = max(peak, bandwidth)
+		average = max(average, avg)
+	}
+	if out.Bandwidth > 0 {
+		out.Bandwidth += peak
+	}
+	if out.AverageBandwidth > 0 {
+		out.AverageBandwidth += average
+	}
+	return &out
+}
+
+// splitCodecs splits a CODECS value into its entries
+func splitCodecs(codecs string) []string {
+	var out []string
+	for _, c := range strings.Split(codecs, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
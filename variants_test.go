@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Avalanche-io/gotio"
+)
+
+// ladderTimeline has two video tracks and audio groups for AAC in two
+// languages and for EC-3
+func ladderTimeline() *gotio.Timeline {
+	timeline := gotio.NewTimeline("Ladder", nil, nil)
+	for _, v := range []struct {
+		name               string
+		bandwidth, average int
+	}{{"1080p", 6000000, 5000000}, {"720p", 3000000, 2500000}} {
+		timeline.Tracks().AppendChild(gotio.NewTrack(v.name, nil, gotio.TrackKindVideo, gotio.AnyDictionary{
+			streamingMetadataNamespace: map[string]interface{}{
+				"bandwidth":         v.bandwidth,
+				"average_bandwidth": v.average,
+				"codec":             "avc1.640028",
+			},
+			"linked_tracks": []interface{}{"English AAC"},
+		}, nil))
+	}
+	for _, a := range []struct {
+		name, group, codec string
+		bandwidth          int
+	}{
+		{"English AAC", "aac", "mp4a.40.2", 128000},
+		{"French AAC", "aac", "mp4a.40.2", 96000},
+		{"English EC-3", "ec3", "ec-3", 384000},
+	} {
+		timeline.Tracks().AppendChild(gotio.NewTrack(a.name, nil, gotio.TrackKindAudio, gotio.AnyDictionary{
+			streamingMetadataNamespace: map[string]interface{}{
+				"group_id":  a.group,
+				"codec":     a.codec,
+				"bandwidth": a.bandwidth,
+			},
+		}, nil))
+	}
+	return timeline
+}
+
+func masterPlaylist(t *testing.T, timeline *gotio.Timeline, opts ...EncoderOption) *MultivariantPlaylist {
+	t.Helper()
+	p, err := NewEncoder(nil, opts...).Playlist(timeline)
+	if err != nil {
+		t.Fatalf("Playlist failed: %v", err)
+	}
+	return p.(*MultivariantPlaylist)
+}
+
+func TestVariantCrossProduct(t *testing.T) {
+	master := masterPlaylist(t, ladderTimeline(), WithVariantRules(AllAudioGroups()))
+	if len(master.Variants) != 4 {
+		t.Fatalf("Expected 4 variants, got %d", len(master.Variants))
+	}
+
+	tests := []struct {
+		uri, audio, codecs          string
+		bandwidth, averageBandwidth int64
+	}{
+		{"1080p.m3u8", "aac", "avc1.640028,mp4a.40.2", 6128000, 5128000},
+		{"1080p.m3u8", "ec3", "avc1.640028,ec-3", 6384000, 5384000},
+		{"720p.m3u8", "aac", "avc1.640028,mp4a.40.2", 3128000, 2628000},
+		{"720p.m3u8", "ec3", "avc1.640028,ec-3", 3384000, 2884000},
+	}
+	for i, tt := range tests {
+		v := master.Variants[i]
+		if v.URI != tt.uri || v.Audio != tt.audio || v.Codecs != tt.codecs ||
+			v.Bandwidth != tt.bandwidth || v.AverageBandwidth != tt.averageBandwidth {
+			t.Errorf("Variant %d: got %s %s %s %d %d", i, v.URI, v.Audio, v.Codecs, v.Bandwidth, v.AverageBandwidth)
+		}
+	}
+	if g := master.Group(MediaTypeAudio, "aac"); len(g) != 2 {
+		t.Errorf("Expected 2 renditions in the aac group, got %d", len(g))
+	}
+}
+
+func TestWithAudioGroupCopies(t *testing.T) {
+	e := NewEncoder(nil)
+	v := &Variant{URI: "1080p.m3u8", Resolution: &Resolution{Width: 1920, Height: 1080}, Attrs: AttributeList{"X-A": "1"}}
+	aac := e.withAudioGroup(v, &AudioGroup{ID: "aac"})
+	ec3 := e.withAudioGroup(v, &AudioGroup{ID: "ec3"})
+	aac.Attrs["X-A"] = "2"
+	aac.Resolution.Height = 720
+	if v.Attrs["X-A"] != "1" || ec3.Attrs["X-A"] != "1" || v.Resolution.Height != 1080 || ec3.Resolution.Height != 1080 {
+		t.Errorf("Expected independent copies, got %+v and %+v", v, ec3)
+	}
+}
+
+func TestVariantRules(t *testing.T) {
+	master := masterPlaylist(t, ladderTimeline(), WithVariantRules(AudioCodecGroups("ec-3")))
+	if len(master.Variants) != 2 || master.Variants[0].Audio != "ec3" || master.Variants[1].Audio != "ec3" {
+		t.Errorf("Expected only EC-3 variants, got %+v", master.Variants)
+	}
+
+	master = masterPlaylist(t, ladderTimeline(), WithVariantRules(LinkedAudioGroups()))
+	if len(master.Variants) != 2 || master.Variants[0].Audio != "aac" {
+		t.Errorf("Expected the linked aac group, got %+v", master.Variants)
+	}
+
+	// Without rules the first linked track alone counts
+	master = masterPlaylist(t, ladderTimeline())
+	if len(master.Variants) != 2 || master.Variants[0].Bandwidth != 6128000 || master.Variants[0].Codecs != "avc1.640028,mp4a.40.2" {
+		t.Errorf("Unexpected default variants: %+v", master.Variants[0])
+	}
+
+	// A rule matching nothing leaves the variant without audio
+	none := func(*gotio.Track, *AudioGroup) bool { return false }
+	master = masterPlaylist(t, ladderTimeline(), WithVariantRules(none))
+	if len(master.Variants) != 2 || master.Variants[0].Audio != "" || master.Variants[0].Bandwidth != 6000000 {
+		t.Errorf("Expected video-only variants, got %+v", master.Variants[0])
+	}
+}
+
+const hdrLadderPlaylist = `#EXTM3U
+#EXT-X-VERSION:12
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="audio/en.m3u8"
+
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=300000,CODECS="hvc1.2.4.L150.B0",SUPPLEMENTAL-CODECS="dvh1.08.07/db4h",RESOLUTION=3840x2160,HDCP-LEVEL=TYPE-1,ALLOWED-CPC="com.apple.streamingkeydelivery:AppleMain/Main",VIDEO-RANGE=HLG,PATHWAY-ID="CDN-A",URI="hdr/iframes.m3u8"
+
+#EXT-X-STREAM-INF:BANDWIDTH=16000000,AVERAGE-BANDWIDTH=12000000,SCORE=2,CODECS="hvc1.2.4.L150.B0,mp4a.40.2",SUPPLEMENTAL-CODECS="dvh1.08.07/db4h",RESOLUTION=3840x2160,FRAME-RATE=23.976,HDCP-LEVEL=TYPE-1,ALLOWED-CPC="com.apple.streamingkeydelivery:AppleMain/Main",VIDEO-RANGE=HLG,STABLE-VARIANT-ID="hdr-2160",AUDIO="aac",PATHWAY-ID="CDN-A"
+hdr/prog_index.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=20000000,SCORE=1.5,CODECS="mvc1.2.4.L150.B0,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=30.000,VIDEO-RANGE=SDR,REQ-VIDEO-LAYOUT="CH-STEREO,CH-MONO",AUDIO="aac"
+spatial/prog_index.m3u8
+
+`
+
+func TestStreamInfAttributes(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(hdrLadderPlaylist), Strict()).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	hdr := namespace(timeline.Tracks().Children()[0].(*gotio.Track).Metadata(), streamingMetadataNamespace)
+	for key, want := range map[string]interface{}{
+		"score":               2.0,
+		"supplemental_codecs": "dvh1.08.07/db4h",
+		"video_range":         "HLG",
+		"hdcp_level":          "TYPE-1",
+		"allowed_cpc":         "com.apple.streamingkeydelivery:AppleMain/Main",
+		"stable_variant_id":   "hdr-2160",
+		"pathway_id":          "CDN-A",
+	} {
+		if hdr[key] != want {
+			t.Errorf("Expected %s %v, got %v", key, want, hdr[key])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if buf.String() != hdrLadderPlaylist {
+		t.Errorf("Expected the playlist back unchanged, got:\n%s", buf.String())
+	}
+}
+
+func TestStreamInfEnumerations(t *testing.T) {
+	for _, attr := range []string{
+		"VIDEO-RANGE=HDR10",
+		"HDCP-LEVEL=TYPE-2",
+		`REQ-VIDEO-LAYOUT="CH-QUAD"`,
+		`ALLOWED-CPC="AppleMain"`,
+	} {
+		playlist := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000," + attr + "\nv/index.m3u8\n"
+		_, err := NewDecoder(strings.NewReader(playlist), Strict()).Decode()
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Expected *SyntaxError for %s, got: %v", attr, err)
+		}
+	}
+
+	timeline := ladderTimeline()
+	video := timeline.Tracks().Children()[0].(*gotio.Track)
+	namespace(video.Metadata(), streamingMetadataNamespace)["video_range"] = "DV"
+	if err := NewEncoder(&bytes.Buffer{}).Encode(timeline); err == nil || !strings.Contains(err.Error(), "VIDEO-RANGE") {
+		t.Errorf("Expected the encoder to reject VIDEO-RANGE=DV, got: %v", err)
+	}
+}
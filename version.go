@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// VersionFeature is a playlist feature and the EXT-X-VERSION it needs
+type VersionFeature struct {
+	Version int
+	Name    string
+}
+
+// VersionFeatures lists the features of a playlist that need a version
+// above 1, each once, in the order they first appear
+func VersionFeatures(p Playlist) []VersionFeature {
+	var features []VersionFeature
+	seen := make(map[string]bool)
+	add := func(version int, name string) {
+		if !seen[name] {
+			seen[name] = true
+			features = append(features, VersionFeature{Version: version, Name: name})
+		}
+	}
+	addDefines := func(defines []Define) {
+		for _, d := range defines {
+			if d.QueryParam != "" {
+				add(11, "EXT-X-DEFINE QUERYPARAM")
+			} else {
+				add(8, "EXT-X-DEFINE")
+			}
+		}
+	}
+	addTags := func(tags []Tag) {
+		for _, tag := range tags {
+			if tag.Name != "EXT-X-SKIP" {
+				continue
+			}
+			if ParseAttributeList(tag.Value).Get("RECENTLY-REMOVED-DATERANGES") != "" {
+				add(10, "EXT-X-SKIP RECENTLY-REMOVED-DATERANGES")
+			} else {
+				add(9, "EXT-X-SKIP")
+			}
+		}
+	}
+
+	switch p := p.(type) {
+	case *MediaPlaylist:
+		addDefines(p.Defines)
+		addTags(p.Tags)
+		if p.IFramesOnly {
+			add(4, "EXT-X-I-FRAMES-ONLY")
+		}
+		for _, seg := range p.Segments {
+			addTags(seg.Tags)
+			if seg.Duration != math.Trunc(seg.Duration) {
+				add(3, "decimal EXTINF durations")
+			}
+			if seg.Byterange != nil {
+				add(4, "EXT-X-BYTERANGE")
+			}
+			if k := seg.Key; k != nil {
+				if k.IV != "" {
+					add(2, "the EXT-X-KEY IV attribute")
+				}
+				if k.KeyFormat != "" || k.KeyFormatVersions != "" {
+					add(5, "EXT-X-KEY KEYFORMAT attributes")
+				}
+			}
+			if seg.Map != nil {
+				if p.IFramesOnly {
+					add(5, "EXT-X-MAP in an I-frame playlist")
+				} else {
+					add(6, "EXT-X-MAP")
+				}
+			}
+		}
+	case *MultivariantPlaylist:
+		addDefines(p.Defines)
+		for _, r := range p.Renditions {
+			if strings.HasPrefix(r.InstreamID, "SERVICE") {
+				add(7, "INSTREAM-ID SERVICE values")
+			}
+		}
+		for _, v := range p.Variants {
+			if v.ReqVideoLayout != "" {
+				add(12, "REQ-VIDEO-LAYOUT")
+			}
+		}
+	}
+	return features
+}
+
+// MinVersion returns the lowest EXT-X-VERSION that expresses every
+// feature of a playlist
+func MinVersion(p Playlist) int {
+	version := 1
+	for _, f := range VersionFeatures(p) {
+		version = max(version, f.Version)
+	}
+	return version
+}
+
+// VersionError is returned when a playlist uses a feature its version
+// cannot express
+type VersionError struct {
+	Version int
+	Feature VersionFeature
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("version %d cannot express %s, which needs version %d",
+		e.Version, e.Feature.Name, e.Feature.Version)
+}
+
+// Downlevel rewrites a playlist in place so that it can be written at the
+// given version, and sets its Version. Below version 3 EXTINF durations are
+// rounded, below version 2 IVs equal to the media sequence number are
+// dropped, below version 5 KEYFORMAT attributes holding their defaults are
+// dropped, and below version 8 EXT-X-DEFINE variables with a value are
+// substituted into URIs. A *VersionError names the first feature that
+// remains out of reach.
+func Downlevel(p Playlist, version int) error {
+	switch p := p.(type) {
+	case *MediaPlaylist:
+		if version < 8 {
+			vars, ok := defineValues(p.Defines)
+			if ok {
+				p.Defines = nil
+				for _, seg := range p.Segments {
+					seg.URI = substitute(seg.URI, vars)
+					if seg.Key != nil {
+						seg.Key.URI = substitute(seg.Key.URI, vars)
+					}
+					if seg.Map != nil {
+						seg.Map.URI = substitute(seg.Map.URI, vars)
+					}
+					for _, part := range seg.Parts {
+						part.URI = substitute(part.URI, vars)
+					}
+				}
+				for _, part := range p.Parts {
+					part.URI = substitute(part.URI, vars)
+				}
+			}
+		}
+		for i, seg := range p.Segments {
+			if version < 3 {
+				seg.Duration = math.Round(seg.Duration)
+			}
+			if seg.Key == nil {
+				continue
+			}
+			// Segments may share a key, so it is changed in a copy
+			k := *seg.Key
+			if version < 2 && isDefaultIV(k.IV, p.MediaSequence+i) {
+				k.IV = ""
+			}
+			if version < 5 && (k.KeyFormat == "" || k.KeyFormat == "identity") &&
+				(k.KeyFormatVersions == "" || k.KeyFormatVersions == "1") {
+				k.KeyFormat, k.KeyFormatVersions = "", ""
+			}
+			if k != *seg.Key {
+				seg.Key = &k
+			}
+		}
+		p.Version = version
+	case *MultivariantPlaylist:
+		if version < 8 {
+			if vars, ok := defineValues(p.Defines); ok {
+				p.Defines = nil
+				for _, v := range p.Variants {
+					v.URI = substitute(v.URI, vars)
+				}
+				for _, v := range p.IFrameVariants {
+					v.URI = substitute(v.URI, vars)
+				}
+				for _, r := range p.Renditions {
+					r.URI = substitute(r.URI, vars)
+				}
+			}
+		}
+		p.Version = version
+	}
+
+	for _, f := range VersionFeatures(p) {
+		if f.Version > version {
+			return &VersionError{Version: version, Feature: f}
+		}
+	}
+	return nil
+}
+
+// defineValues returns the variables of EXT-X-DEFINE tags that all have a
+// value, or false when any is imported or a query parameter
+func defineValues(defines []Define) (map[string]string, bool) {
+	if len(defines) == 0 {
+		return nil, false
+	}
+	vars := make(map[string]string, len(defines))
+	for _, d := range defines {
+		if d.Name == "" {
+			return nil, false
+		}
+		vars[d.Name] = d.Value
+	}
+	return vars, true
+}
+
+// substitute replaces the {$name} variable references of s
+func substitute(s string, vars map[string]string) string {
+	if !strings.Contains(s, "{$") {
+		return s
+	}
+	for name, value := range vars {
+		s = strings.ReplaceAll(s, "{$"+name+"}", value)
+	}
+	return s
+}
+
+// isDefaultIV reports whether an IV equals the media sequence number a
+// client uses when there is none
+func isDefaultIV(iv string, sequence int) bool {
+	hex := strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X")
+	if iv == "" || hex == iv || len(hex) > 32 {
+		return false
+	}
+	return strings.EqualFold(strings.Repeat("0", 32-len(hex))+hex, fmt.Sprintf("%032x", sequence))
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const versionTestPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-DEFINE:NAME="cdn",VALUE="https://cdn.example.com"
+#EXT-X-KEY:METHOD=AES-128,URI="{$cdn}/key.bin",IV=0x00000000000000000000000000000000,KEYFORMAT="identity"
+#EXTINF:5.5,
+{$cdn}/seg0.ts
+#EXTINF:6,
+{$cdn}/seg1.ts
+#EXT-X-ENDLIST
+`
+
+func TestMinVersion(t *testing.T) {
+	p, err := Unmarshal([]byte(versionTestPlaylist))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if v := MinVersion(p); v != 8 {
+		t.Errorf("Expected version 8, got %d: %v", v, VersionFeatures(p))
+	}
+
+	media := &MediaPlaylist{TargetDuration: 6, Segments: []*Segment{{URI: "a.ts", Duration: 6}}}
+	if v := MinVersion(media); v != 1 {
+		t.Errorf("Expected version 1, got %d", v)
+	}
+	media.Segments[0].Map = &Map{URI: "init.mp4"}
+	if v := MinVersion(media); v != 6 {
+		t.Errorf("Expected version 6 for EXT-X-MAP, got %d", v)
+	}
+	media.Tags = []Tag{{Name: "EXT-X-SKIP", Value: "SKIPPED-SEGMENTS=3"}}
+	if v := MinVersion(media); v != 9 {
+		t.Errorf("Expected version 9 for EXT-X-SKIP, got %d", v)
+	}
+}
+
+func TestEncodeComputesVersion(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(versionTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	p, err := NewEncoder(nil).Playlist(timeline)
+	if err != nil {
+		t.Fatalf("Playlist failed: %v", err)
+	}
+	if v := p.(*MediaPlaylist).Version; v != 8 {
+		t.Errorf("Expected the declared version 3 raised to 8, got %d", v)
+	}
+}
+
+func TestEncodeDownlevel(t *testing.T) {
+	timeline, err := NewDecoder(strings.NewReader(versionTestPlaylist)).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithVersion(2)).Encode(timeline); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"#EXT-X-VERSION:2\n",
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"https://cdn.example.com/key.bin\",IV=0x00000000000000000000000000000000\n",
+		"#EXTINF:6,\nhttps://cdn.example.com/seg0.ts\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "EXT-X-DEFINE") {
+		t.Errorf("Expected the variable substituted, got:\n%s", output)
+	}
+
+	// The IV of the second segment is not its sequence number
+	buf.Reset()
+	err = NewEncoder(&buf, WithVersion(1)).Encode(timeline)
+	var verr *VersionError
+	if !errors.As(err, &verr) || verr.Feature.Version != 2 || buf.Len() != 0 {
+		t.Errorf("Expected a VersionError for the IV, got: %v", err)
+	}
+
+	p := &MediaPlaylist{TargetDuration: 6, Segments: []*Segment{
+		{URI: "a.ts", Duration: 6, Byterange: &Byterange{Count: 100}},
+	}}
+	err = Downlevel(p, 3)
+	if !errors.As(err, &verr) || verr.Feature.Name != "EXT-X-BYTERANGE" || verr.Feature.Version != 4 {
+		t.Fatalf("Expected a VersionError for EXT-X-BYTERANGE, got: %v", err)
+	}
+	if want := "version 3 cannot express EXT-X-BYTERANGE, which needs version 4"; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Contributors to the OpenTimelineIO project
+
+package hls
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WritableFS is a file system Package writes to. Names are slash-separated
+// paths in the form accepted by fs.ValidPath.
+type WritableFS interface {
+	// WriteFile writes data to the named file, creating it and any
+	// missing parent directories
+	WriteFile(name string, data []byte) error
+}
+
+// linkFS is implemented by file systems that can link to an existing OS
+// file instead of copying it
+type linkFS interface {
+	Link(oldpath, name string) error
+}
+
+// removeFS is implemented by file systems that can remove a file, which
+// Package uses to clean up after a failure. Exists tells Package which
+// files were there before it wrote them, so that only its own are removed.
+type removeFS interface {
+	Exists(name string) bool
+	Remove(name string) error
+}
+
+// DirFS is a WritableFS rooted at a directory of the OS file system
+type DirFS string
+
+// WriteFile implements WritableFS
+func (d DirFS) WriteFile(name string, data []byte) error {
+	target, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0o644)
+}
+
+// Link hard links the OS file oldpath to name, replacing any file there.
+// Linking a file to itself does nothing, and a failed link leaves the file
+// at name as it was.
+func (d DirFS) Link(oldpath, name string) error {
+	target, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if old, err := os.Stat(oldpath); err == nil {
+		if info, err := os.Stat(target); err == nil && os.SameFile(old, info) {
+			return nil
+		}
+	}
+
+	// Link under a free name next to the target, then rename it over
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+	if err := os.Link(oldpath, tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Exists reports whether the named file exists
+func (d DirFS) Exists(name string) bool {
+	target, err := d.path(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Lstat(target)
+	return err == nil
+}
+
+// Remove removes the named file
+func (d DirFS) Remove(name string) error {
+	target, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(target)
+}
+
+func (d DirFS) path(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(name)), nil
+}
+
+// MemFS is an in-memory WritableFS mapping file names to their contents
+type MemFS map[string][]byte
+
+// WriteFile implements WritableFS
+func (m MemFS) WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// Exists reports whether the named file exists
+func (m MemFS) Exists(name string) bool {
+	_, ok := m[name]
+	return ok
+}
+
+// Remove removes the named file
+func (m MemFS) Remove(name string) error {
+	if _, ok := m[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m, name)
+	return nil
+}